@@ -21,7 +21,9 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -29,17 +31,94 @@ import (
 	"github.com/falcosecurity/plugins/build/registry/pkg/check"
 	"github.com/falcosecurity/plugins/build/registry/pkg/distribution"
 	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
 	"github.com/falcosecurity/plugins/build/registry/pkg/table"
 )
 
 const (
 	defaultTableSubTag = "<!-- REGISTRY -->"
+	outputTable        = "table"
+	outputJSON         = "json"
+	outputMarkdown     = "markdown"
+	outputText         = "text"
+	logFormatText      = "text"
+	logFormatJSON      = "json"
 )
 
 var (
 	out = bufio.NewWriter(os.Stdout)
+	// showProgress enables the progress report of the registry-wide operations.
+	showProgress bool
+	// strictAPIVersion fails the extraction for the plugins requiring an api version not supported by the loader.
+	strictAPIVersion bool
+	// pluginTempDir is the directory where compressed plugins are decompressed before being loaded.
+	pluginTempDir string
+	// allowUnknownRequirements accepts the extracted requirements whose name is not known.
+	allowUnknownRequirements bool
+	// requirementLogger is the logger of the operations, as set by the --log-level and --log-format flags.
+	requirementLogger *slog.Logger
+	// requirementWarnings collects the warnings raised by the operations, if --fail-on-warning is set.
+	requirementWarnings *oci.WarningCollector
 )
 
+// newLogger returns the logger writing on the standard error the logs of the given level, or higher, in the given
+// format.
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unsupported log level %q, expected one of %q, %q, %q, %q", level, "debug", "info", "warn", "error")
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(os.Stderr, handlerOpts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q, expected one of %q, %q", format, logFormatText, logFormatJSON)
+	}
+}
+
+// progressOptions returns the options reporting the progress of the registry-wide operations on the standard error,
+// if enabled with the --progress flag.
+func progressOptions() []oci.BatchOption {
+	if !showProgress {
+		return nil
+	}
+
+	return []oci.BatchOption{oci.WithProgress(func(completed, total int, currentPath string) {
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", completed, total, currentPath)
+	})}
+}
+
+// requirementOptions returns the options the requirements are extracted with, as set by the persistent flags.
+func requirementOptions() []oci.RequirementOption {
+	var reqOpts []oci.RequirementOption
+	if requirementLogger != nil {
+		reqOpts = append(reqOpts, oci.WithLogger(requirementLogger))
+	}
+	if requirementWarnings != nil {
+		reqOpts = append(reqOpts, oci.WithWarningCollector(requirementWarnings))
+	}
+	if strictAPIVersion {
+		reqOpts = append(reqOpts, oci.WithStrictAPIVersion())
+	}
+	if pluginTempDir != "" {
+		reqOpts = append(reqOpts, oci.WithPluginTempDir(pluginTempDir))
+	}
+	if allowUnknownRequirements {
+		reqOpts = append(reqOpts, oci.WithAllowUnknownRequirementNames())
+	}
+
+	return reqOpts
+}
+
+// batchOptions returns the options of the registry-wide operations, as set by the persistent flags.
+func batchOptions() []oci.BatchOption {
+	return append(progressOptions(), oci.WithBatchRequirementOptions(requirementOptions()...))
+}
+
 func main() {
 	defer out.Flush()
 
@@ -82,13 +161,47 @@ func main() {
 		},
 	}
 
+	var dryRun bool
+	var packagesDir string
+	var output string
+	var pushMaxAttempts int
+	var pushTimeout time.Duration
+	var failOnEngineDowngrade bool
+	var signingKey string
 	updateOCIRegistry := &cobra.Command{
-		Use:                   "update-oci-registry <registryFilename>",
-		Short:                 "Update the oci registry starting from the registry file and s3 bucket",
-		Args:                  cobra.ExactArgs(1),
-		DisableFlagsInUseLine: true,
+		Use:   "update-oci-registry <registryFilename>",
+		Short: "Update the oci registry starting from the registry file and s3 bucket",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(c *cobra.Command, args []string) error {
-			status, err := oci.DoUpdateOCIRegistry(opts.Context, args[0])
+			if dryRun {
+				if output != outputTable && output != outputJSON {
+					return fmt.Errorf("unsupported output format %q, expected one of %q, %q", output, outputTable, outputJSON)
+				}
+
+				computed, err := oci.DoDryRunOCIRegistry(args[0], packagesDir, batchOptions()...)
+				if err != nil {
+					return err
+				}
+
+				if output == outputJSON {
+					return oci.PrintDryRunJSON(computed, opts.Output)
+				}
+				return oci.PrintDryRun(computed, opts.Output)
+			}
+
+			pushOpts := []oci.PushOption{
+				oci.WithPushMaxAttempts(pushMaxAttempts),
+				oci.WithPushTimeout(pushTimeout),
+				oci.WithPushRequirementOptions(requirementOptions()...),
+			}
+			if failOnEngineDowngrade {
+				pushOpts = append(pushOpts, oci.WithFailOnEngineDowngrade())
+			}
+			if signingKey != "" {
+				pushOpts = append(pushOpts, oci.WithSigningKey(signingKey))
+			}
+
+			status, err := oci.DoUpdateOCIRegistry(opts.Context, args[0], pushOpts...)
 			if err != nil {
 				return err
 			}
@@ -96,15 +209,280 @@ func main() {
 			return oci.PrintUpdateStatus(status, opts.Output)
 		},
 	}
+	updateOCIRegistryFlags := updateOCIRegistry.Flags()
+	updateOCIRegistryFlags.BoolVar(&dryRun, "dry-run", false, "Print the requirements computed for the artifacts found in the packages directory, without pushing them or contacting any remote service.")
+	updateOCIRegistryFlags.StringVar(&packagesDir, "packages-dir", "output", "The directory containing the plugin and rulesfile archives to be used in dry-run mode.")
+	updateOCIRegistryFlags.IntVar(&pushMaxAttempts, "push-max-attempts", 5, "The maximum number of attempts to push each artifact, transient errors are retried with an exponential backoff.")
+	updateOCIRegistryFlags.DurationVar(&pushTimeout, "push-timeout", 5*time.Minute, "The timeout of each attempt to push an artifact, no timeout if zero.")
+	updateOCIRegistryFlags.BoolVar(&failOnEngineDowngrade, "fail-on-engine-downgrade", false, "Fail if a new rulesfile release requires an older engine version than the previous one, instead of warning.")
+	updateOCIRegistryFlags.StringVar(&signingKey, "signing-key", "", "The file of the unencrypted ECDSA private key, in PEM format, to sign the pushed artifacts with. Artifacts are not signed if empty.")
+	updateOCIRegistryFlags.StringVar(&output, "output", outputTable, "The format of the requirements printed in dry-run mode, either \"table\" or \"json\".")
+
+	var checkPackagesDir string
+	checkRequirementsCmd := &cobra.Command{
+		Use:   "check-requirements <registryFilename>",
+		Short: "Verify that all the packaged plugins and rulesfiles of a plugin registry YAML file declare their requirements",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			missing, err := oci.DoCheckRequirements(args[0], checkPackagesDir, batchOptions()...)
+			if err != nil {
+				return err
+			}
+			if len(missing) == 0 {
+				return nil
+			}
+
+			if err := oci.PrintMissingRequirements(missing, opts.Output); err != nil {
+				return err
+			}
+			// Flush the report before exiting with an error.
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d files are missing their requirements", len(missing))
+		},
+	}
+	checkRequirementsCmd.Flags().StringVar(&checkPackagesDir, "packages-dir", "output", "The directory containing the plugin and rulesfile archives to be checked.")
+
+	verifyCmd := &cobra.Command{
+		Use:                   "verify <ref> <sourceDir>",
+		Short:                 "Verify that the requirements of a published artifact match the ones declared by its source files",
+		Args:                  cobra.ExactArgs(2),
+		DisableFlagsInUseLine: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			changes, err := oci.DoVerify(opts.Context, args[0], args[1], requirementOptions()...)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				return nil
+			}
+
+			if err := oci.PrintRequirementChanges(changes, opts.Output); err != nil {
+				return err
+			}
+			// Flush the report before exiting with an error.
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("the requirements of %q differ from the ones of its source files", args[0])
+		},
+	}
+
+	var regenerateDryRun bool
+	regenerateConfigsCmd := &cobra.Command{
+		Use:   "regenerate-configs <registryFilename>",
+		Short: "Regenerate the config blobs of the published artifacts whose requirements re-derived from their layers changed",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			regenerated, err := oci.DoRegenerateConfigs(opts.Context, args[0], regenerateDryRun,
+				oci.WithPushRequirementOptions(requirementOptions()...))
+			if printErr := oci.PrintRegeneratedConfigs(regenerated, opts.Output); printErr != nil {
+				return printErr
+			}
+			return err
+		},
+	}
+	regenerateConfigsCmd.Flags().BoolVar(&regenerateDryRun, "dry-run", false, "Print the changes of the requirements of the published artifacts, without pushing anything.")
+
+	signCmd := &cobra.Command{
+		Use:                   "sign <ref> <keyFile>",
+		Short:                 "Sign a published artifact with an ECDSA private key, in the format used by cosign",
+		Args:                  cobra.ExactArgs(2),
+		DisableFlagsInUseLine: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			return oci.SignArtifact(opts.Context, args[0], args[1])
+		},
+	}
+
+	verifySignatureCmd := &cobra.Command{
+		Use:                   "verify-signature <ref> <keyFile>",
+		Short:                 "Verify that a published artifact has been signed with the private key of an ECDSA public key",
+		Args:                  cobra.ExactArgs(2),
+		DisableFlagsInUseLine: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			return oci.VerifyArtifactSignature(opts.Context, args[0], args[1])
+		},
+	}
 
+	var summaryPluginsDir string
+	var summaryOutput string
+	summaryCmd := &cobra.Command{
+		Use:   "summary <registryFilename>",
+		Short: "Summarize the requirements of all the plugins and rulesfiles of a plugin registry YAML file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			if summaryOutput != outputMarkdown && summaryOutput != outputJSON {
+				return fmt.Errorf("unsupported output format %q, expected one of %q, %q", summaryOutput, outputMarkdown, outputJSON)
+			}
+
+			reg, err := registry.LoadRegistryFromFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			summary, err := oci.RegistrySummary(reg, summaryPluginsDir, requirementOptions()...)
+			if err != nil {
+				return err
+			}
+
+			if summaryOutput == outputJSON {
+				return oci.PrintSummaryJSON(summary, opts.Output)
+			}
+			return oci.PrintSummaryMarkdown(summary, opts.Output)
+		},
+	}
+	summaryFlags := summaryCmd.Flags()
+	summaryFlags.StringVar(&summaryPluginsDir, "plugins-dir", "plugins", "The directory containing the source tree of the plugins, with the plugins already built.")
+	summaryFlags.StringVar(&summaryOutput, "output", outputMarkdown, "The format of the summary, either \"markdown\" or \"json\".")
+
+	var engineVersionsPluginsDir string
+	var engineVersionsOutput string
+	engineVersionsCmd := &cobra.Command{
+		Use:   "engine-versions <registryFilename>",
+		Short: "List the distinct engine versions required by the rulesfiles of a plugin registry YAML file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			if engineVersionsOutput != outputText && engineVersionsOutput != outputJSON {
+				return fmt.Errorf("unsupported output format %q, expected one of %q, %q", engineVersionsOutput, outputText, outputJSON)
+			}
+
+			reg, err := registry.LoadRegistryFromFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			usages, err := oci.RegistryEngineVersions(reg, engineVersionsPluginsDir, requirementOptions()...)
+			if err != nil {
+				return err
+			}
+
+			if engineVersionsOutput == outputJSON {
+				return oci.PrintEngineVersionsJSON(usages, opts.Output)
+			}
+			return oci.PrintEngineVersions(usages, opts.Output)
+		},
+	}
+	engineVersionsFlags := engineVersionsCmd.Flags()
+	engineVersionsFlags.StringVar(&engineVersionsPluginsDir, "plugins-dir", "plugins", "The directory containing the source tree of the plugins.")
+	engineVersionsFlags.StringVar(&engineVersionsOutput, "output", outputText, "The format of the list, either \"text\" or \"json\".")
+
+	var rulesfilesPluginsDir string
+	var checkMissingRulesfiles bool
+	checkRulesfilesCmd := &cobra.Command{
+		Use:   "check-rulesfiles <registryFilename>",
+		Short: "Verify that all the rulesfiles in the source tree of the plugins are referred to by a plugin registry YAML file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			reg, err := registry.LoadRegistryFromFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			consistency, err := oci.CheckRulesfilesConsistency(reg, rulesfilesPluginsDir, checkMissingRulesfiles)
+			if err != nil {
+				return err
+			}
+			if consistency.Empty() {
+				return nil
+			}
+
+			if err := oci.PrintRulesfilesConsistency(consistency, opts.Output); err != nil {
+				return err
+			}
+			// Flush the report before exiting with an error.
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d orphaned rulesfiles and %d registry entries with missing rulesfiles", len(consistency.Orphaned), len(consistency.Missing))
+		},
+	}
+	checkRulesfilesFlags := checkRulesfilesCmd.Flags()
+	checkRulesfilesFlags.StringVar(&rulesfilesPluginsDir, "plugins-dir", "plugins", "The directory containing the source tree of the plugins.")
+	checkRulesfilesFlags.BoolVar(&checkMissingRulesfiles, "missing", false, "Also report the registry entries declaring rulesfiles that are not found in the source tree.")
+
+	var lintStrict bool
+	lintCmd := &cobra.Command{
+		Use:   "lint <rulesfile>...",
+		Short: "Warn about the rulesfiles declaring their engine requirement as a bare number, instead of a full semver string",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			findings, err := oci.LintRulesfiles(args, requirementOptions()...)
+			if err != nil {
+				return err
+			}
+			if err := oci.PrintLintFindings(findings, opts.Output); err != nil {
+				return err
+			}
+
+			if !lintStrict || len(findings) == 0 {
+				return nil
+			}
+			// Flush the report before exiting with an error.
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d engine requirements are not full semver strings", len(findings))
+		},
+	}
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "Fail if any rulesfile does not pass the lint, instead of only warning.")
+
+	var logLevel string
+	var logFormat string
+	var failOnWarning bool
 	rootCmd := &cobra.Command{
 		Use:     "registry",
 		Version: "0.2.0",
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			logger, err := newLogger(logLevel, logFormat)
+			if err != nil {
+				return err
+			}
+			requirementLogger = logger
+			if failOnWarning {
+				requirementWarnings = &oci.WarningCollector{}
+			}
+			return nil
+		},
+		PersistentPostRunE: func(c *cobra.Command, args []string) error {
+			if !failOnWarning {
+				return nil
+			}
+			collected := requirementWarnings.Warnings()
+			if len(collected) == 0 {
+				return nil
+			}
+
+			if err := oci.PrintWarnings(collected, opts.Output); err != nil {
+				return err
+			}
+			// Flush the report before exiting with an error.
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d warnings raised, failing as requested by --fail-on-warning", len(collected))
+		},
 	}
+	rootCmd.PersistentFlags().BoolVar(&failOnWarning, "fail-on-warning", false, "Fail if any warning is raised while extracting or publishing the requirements, e.g. for bare numeric engine versions, listing all of them.")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "The minimum level of the logs, one of \"debug\", \"info\", \"warn\", \"error\".")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "The format of the logs, written on the standard error, either \"text\" or \"json\".")
+	rootCmd.PersistentFlags().BoolVar(&strictAPIVersion, "strict-api-version", false, "Fail instead of warning when a plugin requires an api version not supported by the plugin loader of this tool.")
+	rootCmd.PersistentFlags().BoolVar(&allowUnknownRequirements, "allow-unknown-requirements", false, "Accept the extracted requirements whose name is not known, instead of failing.")
+	rootCmd.PersistentFlags().BoolVar(&showProgress, "progress", false, "Report the progress of the registry-wide operations on the standard error.")
+	rootCmd.PersistentFlags().StringVar(&pluginTempDir, "plugin-temp-dir", "", "Directory where compressed plugins are decompressed before being loaded, the default directory for temporary files if empty.")
 	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(tableCmd)
 	rootCmd.AddCommand(updateIndexCmd)
 	rootCmd.AddCommand(updateOCIRegistry)
+	rootCmd.AddCommand(checkRequirementsCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(regenerateConfigsCmd)
+	rootCmd.AddCommand(signCmd)
+	rootCmd.AddCommand(verifySignatureCmd)
+	rootCmd.AddCommand(summaryCmd)
+	rootCmd.AddCommand(engineVersionsCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(checkRulesfilesCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Printf("error: %s\n", err)
@@ -25,4 +25,6 @@ const (
 	// PluginAPIVersion is the name givet to the plugin api version requirements.
 	// The same name used by Falco when outputting the plugin api version
 	PluginAPIVersion = "plugin_api_version"
+	// PluginAPIFeature is the name given to the requirements of the optional features of the plugin api.
+	PluginAPIFeature = "plugin_api_feature"
 )
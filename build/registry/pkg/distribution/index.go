@@ -116,6 +116,11 @@ func DoUpdateIndex(registryFile, indexFile string) error {
 		return fmt.Errorf("environment variable with key %q not found, please set it before running this tool", oci.RegistryOCI)
 	}
 
+	// Reject duplicate entries before doing anything else.
+	if err := registry.CheckDuplicatePluginNames(registryFile); err != nil {
+		return err
+	}
+
 	registryEntries, err := registry.LoadRegistryFromFile(registryFile)
 	if err != nil {
 		return err
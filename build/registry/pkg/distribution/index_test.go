@@ -24,6 +24,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/falcosecurity/plugins/build/registry/pkg/distribution"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
 )
 
 const (
@@ -31,6 +32,7 @@ const (
 	wrongIndexFile    = "testdata/wrong-index.yaml"
 	registryFile      = "testdata/registry.yaml"
 	wrongRegistryFile = "testdata/wrong-registry.yaml"
+	dupRegistryFile   = "testdata/duplicate-registry.yaml"
 	registryUser      = "falcosecurity"
 	registryName      = "ghcr.io"
 )
@@ -83,4 +85,16 @@ var _ = Describe("Update index", func() {
 			})
 		})
 	})
+	Context("with duplicate plugin names in registry file", func() {
+		BeforeEach(func() {
+			os.Setenv("REGISTRY_USER", registryUser)
+			os.Setenv("REGISTRY", registryName)
+			err = distribution.DoUpdateIndex(dupRegistryFile, indexFile)
+		})
+		It("Should fail listing the conflicting entries", func() {
+			Expect(err).To(MatchError(registry.ErrDuplicatePluginName))
+			Expect(err.Error()).To(ContainSubstring(dupRegistryFile + ":22:"))
+			Expect(err.Error()).To(ContainSubstring(dupRegistryFile + ":33:"))
+		})
+	})
 })
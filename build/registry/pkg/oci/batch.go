@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+// BatchRequirements extracts the requirements of many plugins and rulesfiles concurrently, using the given number
+// of workers. Each file is handled by the registered extractor handling it, the other ones as rulesfiles, see
+// RegisterRequirementExtractor. An error wrapping ErrMultipleRequirements is reported for the files having more than
+// one requirement. The extraction does not
+// stop at the first failure: the requirements are returned keyed by file path, together with the errors occurred
+// for the other files, in the same order as the given paths. The progress can be reported with WithProgress, and the
+// extraction customized with WithBatchRequirementOptions.
+func BatchRequirements(paths []string, workers int, opts ...BatchOption) (map[string]oci.ArtifactRequirement, []error) {
+	return BatchRequirementsContext(context.Background(), paths, workers, opts...)
+}
+
+// BatchRequirementsContext is the same as BatchRequirements, but it stops when the context is canceled: the files
+// not processed yet are reported as failed with the context error, and the reads in progress are aborted.
+func BatchRequirementsContext(ctx context.Context, paths []string, workers int, opts ...BatchOption) (map[string]oci.ArtifactRequirement, []error) {
+	reqs, errs := batchRequirements(ctx, paths, workers, opts)
+
+	results := make(map[string]oci.ArtifactRequirement)
+	var failures []error
+	for i, p := range paths {
+		if errs[i] != nil {
+			failures = append(failures, errs[i])
+			continue
+		}
+		results[p] = *reqs[i]
+	}
+
+	return results, failures
+}
+
+// BatchRequirementsPartial is the same as BatchRequirementsContext, but the failures are aggregated in a single
+// *BatchError, nil if the requirements of all the files have been extracted.
+func BatchRequirementsPartial(ctx context.Context, paths []string, workers int, opts ...BatchOption) (map[string]oci.ArtifactRequirement, error) {
+	reqs, errs := batchRequirements(ctx, paths, workers, opts)
+
+	results := make(map[string]oci.ArtifactRequirement)
+	batchErr := &BatchError{failures: make(map[string]error)}
+	for i, p := range paths {
+		if errs[i] != nil {
+			if _, ok := batchErr.failures[p]; !ok {
+				batchErr.paths = append(batchErr.paths, p)
+			}
+			batchErr.failures[p] = errs[i]
+			continue
+		}
+		results[p] = *reqs[i]
+	}
+
+	if len(batchErr.paths) == 0 {
+		return results, nil
+	}
+
+	return results, batchErr
+}
+
+// batchRequirements extracts the requirements of the given files concurrently, returning the requirement and the
+// error of each file in the same order as the given paths.
+func batchRequirements(ctx context.Context, paths []string, workers int, opts []BatchOption) ([]*oci.ArtifactRequirement, []error) {
+	if workers < 1 {
+		workers = 1
+	}
+	progress := newProgressTracker(opts, len(paths))
+	reqOpts := newBatchOptions(opts).reqOpts
+
+	reqs := make([]*oci.ArtifactRequirement, len(paths))
+	errs := make([]error, len(paths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each job writes only its own slot, no need to synchronize the results.
+			for i := range jobs {
+				reqs[i], errs[i] = fileRequirementContext(ctx, paths[i], reqOpts...)
+				progress.done(paths[i])
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return reqs, errs
+}
+
+// BatchError is the error returned by BatchRequirementsPartial, listing each file whose requirements could not be
+// extracted together with the cause.
+type BatchError struct {
+	// paths are the failed files, in the order they have been given.
+	paths    []string
+	failures map[string]error
+}
+
+// Failures returns the cause of the failure of each file, keyed by file path.
+func (e *BatchError) Failures() map[string]error {
+	failures := make(map[string]error, len(e.failures))
+	for p, err := range e.failures {
+		failures[p] = err
+	}
+
+	return failures
+}
+
+// Error implements the error interface, listing a failure per line.
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "unable to extract the requirements of %d files:", len(e.paths))
+	for _, p := range e.paths {
+		fmt.Fprintf(&b, "\n%s: %v", p, e.failures[p])
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the failures, so that errors.Is and errors.As match any of them.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.paths))
+	for _, p := range e.paths {
+		errs = append(errs, e.failures[p])
+	}
+
+	return errs
+}
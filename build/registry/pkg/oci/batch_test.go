@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Batch requirements", func() {
+	It("should extract the requirements of all the files, reporting the failures", func() {
+		var paths []string
+		for i := 0; i < 20; i++ {
+			paths = append(paths, writeRulesfile("- required_engine_version: 10\n"))
+		}
+		// Missing plugins are loaded concurrently too, and must be reported without aborting the batch.
+		dir := GinkgoT().TempDir()
+		missing := []string{filepath.Join(dir, "a.so"), filepath.Join(dir, "b.so")}
+		paths = append(paths, missing...)
+
+		reqs, errs := oci.BatchRequirements(paths, 4)
+		Expect(reqs).To(HaveLen(20))
+		Expect(errs).To(HaveLen(len(missing)))
+		for i, err := range errs {
+			var reqErr *oci.RequirementError
+			Expect(errors.As(err, &reqErr)).To(BeTrue())
+			Expect(reqErr.FilePath).To(Equal(missing[i]))
+		}
+	})
+
+	It("should report the progress sequentially", func() {
+		var paths []string
+		for i := 0; i < 20; i++ {
+			paths = append(paths, writeRulesfile("- required_engine_version: 10\n"))
+		}
+
+		var inFlight atomic.Int32
+		var concurrent bool
+		var totals, completed []int
+		seen := make(map[string]bool)
+		progress := func(c, total int, currentPath string) {
+			if inFlight.Add(1) != 1 {
+				concurrent = true
+			}
+			defer inFlight.Add(-1)
+
+			totals = append(totals, total)
+			completed = append(completed, c)
+			seen[currentPath] = true
+		}
+
+		_, errs := oci.BatchRequirements(paths, 4, oci.WithProgress(progress))
+		Expect(errs).To(BeEmpty())
+		Expect(concurrent).To(BeFalse(), "progress function called concurrently")
+		Expect(completed).To(HaveLen(len(paths)))
+		for i, c := range completed {
+			Expect(c).To(Equal(i + 1))
+			Expect(totals[i]).To(Equal(len(paths)))
+		}
+		for _, p := range paths {
+			Expect(seen).To(HaveKey(p))
+		}
+	})
+
+	It("should fail all the files with a canceled context", func() {
+		filePath := writeRulesfile("- required_engine_version: 15\n")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		reqs, errs := oci.BatchRequirementsContext(ctx, []string{filePath, filePath + ".so"}, 2)
+		Expect(reqs).To(BeEmpty())
+		Expect(errs).To(HaveLen(2))
+		for _, err := range errs {
+			Expect(err).To(MatchError(context.Canceled))
+		}
+	})
+
+	It("should return the partial requirements with a batch error", func() {
+		valid := writeRulesfile("- required_engine_version: 0.31.0\n")
+		noReq := writeRulesfile("- rule: open\n  condition: evt.type = open\n")
+		missing := filepath.Join(GinkgoT().TempDir(), "missing.yaml")
+
+		reqs, err := oci.BatchRequirementsPartial(context.Background(), []string{valid, noReq, missing}, 2)
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[valid].Version).To(Equal("0.31.0"))
+
+		var batchErr *oci.BatchError
+		Expect(errors.As(err, &batchErr)).To(BeTrue())
+		failures := batchErr.Failures()
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[noReq]).To(MatchError(oci.ErrReqNotFound))
+		Expect(failures[missing]).To(MatchError(oci.ErrOpenFailed))
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		Expect(err.Error()).To(ContainSubstring(missing))
+
+		_, err = oci.BatchRequirementsPartial(context.Background(), []string{valid}, 2)
+		Expect(err).To(BeNil())
+	})
+
+	It("should apply the sidecar overriding the requirements of a file", func() {
+		rulesfile := writeRulesfile("- required_engine_version: 0.31.0\n")
+		Expect(os.WriteFile(rulesfile+oci.RequirementsOverrideSuffix, []byte(`requirements:
+  - name: engine_version_semver
+    version: 0.35.0
+`), 0o600)).To(Succeed())
+
+		reqs, errs := oci.BatchRequirements([]string{rulesfile}, 2)
+		Expect(errs).To(BeEmpty())
+		Expect(reqs).To(Equal(map[string]falcoctloci.ArtifactRequirement{
+			rulesfile: {Name: "engine_version_semver", Version: "0.35.0"},
+		}))
+	})
+})
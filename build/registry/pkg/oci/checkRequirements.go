@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// MissingRequirement is a file, contained in the archive of an artifact, that does not declare its requirement.
+type MissingRequirement struct {
+	Artifact string
+	Version  string
+	// Archive is the archive the file has been extracted from.
+	Archive string
+	// File is the name of the file inside the archive, empty if the archive contains no plugin nor rulesfile.
+	File string
+	Err  error
+}
+
+// DoCheckRequirements extracts the requirements of all the plugins and rulesfiles found in the packagesDir for
+// the entries of the registry, same as DoDryRunOCIRegistry does. Instead of failing on the first file that does
+// not declare its requirement, all of them are collected and returned so that they can be fixed in one go. Any
+// other error aborts the check, including rulesfiles requiring plugins that are not in the registry, see
+// ValidateRulesfileDependencies. The progress can be reported for each archive with WithProgress, and the extraction
+// customized with WithBatchRequirementOptions.
+func DoCheckRequirements(registryFile, packagesDir string, opts ...BatchOption) ([]MissingRequirement, error) {
+	reg, err := registry.LoadRegistryFromFile(registryFile)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred while loading registry entries from file %q: %v", registryFile, err)
+	}
+
+	reqOpts := newBatchOptions(opts).reqOpts
+	artifacts, err := packagedArtifacts(reg, packagesDir, newRequirementOptions(reqOpts).log())
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []MissingRequirement
+	progress := newProgressTracker(opts, len(artifacts))
+	for _, a := range artifacts {
+		m, err := checkArchiveRequirements(reg, a, reqOpts)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, m...)
+		progress.done(a.FilePath)
+	}
+
+	return missing, nil
+}
+
+// checkArchiveRequirements extracts the archive of an artifact and returns the files it contains that do not
+// declare their requirement, extracted with the given options.
+func checkArchiveRequirements(reg *registry.Registry, a packagedArtifact, opts []RequirementOption) ([]MissingRequirement, error) {
+	// Create temp dir.
+	tmpDir, err := os.MkdirTemp("", "registry-oci-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary dir while preparing to extract archive %q: %v", a.FilePath, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files, err := common.ExtractTarGz(a.FilePath, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []MissingRequirement
+	var rulesfiles []string
+	var checked int
+
+	for _, file := range files {
+		// Skip the files no extractor can handle, such as README files.
+		if extractorFor(file) == nil {
+			continue
+		}
+		checked++
+		if isRulesfileName(file) {
+			rulesfiles = append(rulesfiles, file)
+		}
+
+		_, err := fileRequirement(file, opts...)
+		// Overlays, and rulesfiles opting out of the extraction, are not required to declare their requirements.
+		if errors.Is(err, ErrOverlay) || errors.Is(err, ErrSkipped) {
+			continue
+		}
+		if errors.Is(err, ErrReqNotFound) {
+			missing = append(missing, MissingRequirement{
+				Artifact: a.Artifact,
+				Version:  a.Version,
+				Archive:  a.FilePath,
+				File:     filepath.Base(file),
+				Err:      err,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ValidateRulesfileDependencies(reg, rulesfiles); err != nil {
+		return nil, fmt.Errorf("archive %q: %w", a.FilePath, err)
+	}
+
+	if checked == 0 {
+		missing = append(missing, MissingRequirement{
+			Artifact: a.Artifact,
+			Version:  a.Version,
+			Archive:  a.FilePath,
+			Err:      fmt.Errorf("no plugin or rulesfile found in archive %q: %w", a.FilePath, ErrReqNotFound),
+		})
+	}
+
+	return missing, nil
+}
+
+// PrintMissingRequirements writes the files missing their requirement as a table with a row for each file.
+func PrintMissingRequirements(missing []MissingRequirement, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARTIFACT\tVERSION\tARCHIVE\tFILE\tERROR")
+	for _, m := range missing {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Artifact, m.Version, filepath.Base(m.Archive), m.File, m.Err)
+	}
+
+	return w.Flush()
+}
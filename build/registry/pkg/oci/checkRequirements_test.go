@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Check requirements", func() {
+	It("should report the packaged rulesfiles without requirements", func() {
+		dir := GinkgoT().TempDir()
+		registryFile := filepath.Join(dir, "registry.yaml")
+		Expect(os.WriteFile(registryFile, []byte(`plugins:
+  - name: first
+    authors: The Falco Authors
+  - name: second
+    authors: The Falco Authors
+`), 0o600)).To(Succeed())
+
+		writeTarGz(filepath.Join(dir, "first-rules-0.1.0.tar.gz"), map[string]string{
+			"first_rules.yaml": "- required_engine_version: 10\n",
+			"README.md":        "readme",
+		})
+		writeTarGz(filepath.Join(dir, "second-rules-0.1.0.tar.gz"), map[string]string{
+			"second_rules.yaml": "- rule: second\n",
+		})
+		writeTarGz(filepath.Join(dir, "second-rules-0.2.0.tar.gz"), map[string]string{
+			"README.md": "readme",
+		})
+
+		missing, err := oci.DoCheckRequirements(registryFile, dir)
+		Expect(err).To(BeNil())
+		Expect(missing).To(HaveLen(2))
+		for _, m := range missing {
+			Expect(m.Artifact).To(Equal("second-rules"))
+			Expect(m.Err).To(MatchError(oci.ErrReqNotFound))
+		}
+		Expect(missing[0].File).To(Equal("second_rules.yaml"))
+		Expect(missing[1].File).To(BeEmpty())
+	})
+})
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	ocipusher "github.com/falcosecurity/falcoctl/pkg/oci/pusher"
+	"github.com/falcosecurity/falcoctl/pkg/oci/repository"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// Registry is the OCI registry the artifacts are published to. It hides the falcoctl client behind the only
+// operations needed to publish the artifacts, so that the publication can be pointed at any registry, such as
+// a local one in tests, see WithRegistry.
+type Registry interface {
+	// Repository returns the repository pointed by the given reference.
+	Repository(ref string) (*repository.Repository, error)
+	// Pusher returns the pusher of the artifacts to the registry.
+	Pusher() ArtifactPusher
+}
+
+// ArtifactPusher pushes plugins and rulesfiles to an OCI registry, as the falcoctl pusher does.
+type ArtifactPusher interface {
+	Push(ctx context.Context, artifactType oci.ArtifactType, ref string, options ...ocipusher.Option) (*oci.RegistryResult, error)
+}
+
+// remoteRegistry is the Registry reached through a falcoctl client.
+type remoteRegistry struct {
+	client    remote.Client
+	plainHTTP bool
+}
+
+// NewRegistry returns the Registry reached through the given client. If plainHTTP is true, the registry is
+// reached over plain http rather than https, e.g. for registries listening on localhost.
+func NewRegistry(client remote.Client, plainHTTP bool) Registry {
+	return &remoteRegistry{
+		client:    client,
+		plainHTTP: plainHTTP,
+	}
+}
+
+// Repository implements the Registry interface.
+func (r *remoteRegistry) Repository(ref string) (*repository.Repository, error) {
+	repo, err := repository.NewRepository(ref, repository.WithClient(r.client), repository.WithPlainHTTP(r.plainHTTP))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create repository for ref %q: %w", ref, err)
+	}
+
+	return repo, nil
+}
+
+// Pusher implements the Registry interface.
+func (r *remoteRegistry) Pusher() ArtifactPusher {
+	return ocipusher.NewPusher(r.client, r.plainHTTP, nil)
+}
@@ -18,18 +18,20 @@ limitations under the License.
 package oci
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"strings"
+
+	"github.com/blang/semver"
 
 	"github.com/falcosecurity/falcoctl/pkg/oci"
 	"github.com/falcosecurity/plugins/build/registry/pkg/common"
 )
 
 // rulesFileConfig generates the artifact configuration for a rulesfile starting form the tar.gz archive,
-// its name and version.
-func rulesfileConfig(name, version, filePath string) (*oci.ArtifactConfig, error) {
+// its name and version. The requirements are extracted with the given options.
+func rulesfileConfig(name, version, filePath string, opts ...RequirementOption) (*oci.ArtifactConfig, error) {
 	// Create temp dir.
 	tmpDir, err := os.MkdirTemp("", "registry-oci-")
 	if err != nil {
@@ -48,17 +50,18 @@ func rulesfileConfig(name, version, filePath string) (*oci.ArtifactConfig, error
 		Requirements: nil,
 	}
 
-	for _, file := range files {
-		// Get the requirements for the given file.
-		req, err := rulesfileRequirement(file)
-		if err != nil && !errors.Is(err, ErrReqNotFound) {
-			return nil, err
-		}
-		// If found add it to the requirements list.
-		if err == nil {
-			_ = cfg.SetRequirement(req.Name, req.Version)
-		}
+	// Get the requirements for the extracted files.
+	reqs, err := ArtifactRequirements(tmpDir, opts...)
+	if err != nil && !errors.Is(err, ErrReqNotFound) {
+		return nil, err
+	}
+	// If found add them to the requirements list.
+	for _, req := range reqs {
+		_ = cfg.SetRequirement(req.Name, req.Version)
+	}
+	SortRequirements(cfg.Requirements)
 
+	for _, file := range files {
 		deps, err := rulesfileDependencies(file)
 		if err != nil && !errors.Is(err, ErrDepNotFound) {
 			return nil, err
@@ -78,15 +81,14 @@ func rulesfileConfig(name, version, filePath string) (*oci.ArtifactConfig, error
 	return cfg, nil
 }
 
-func pluginConfig(name, version, filePath string) (*oci.ArtifactConfig, error) {
+func pluginConfig(name, version, filePath string, opts ...RequirementOption) (*oci.ArtifactConfig, error) {
 	// Create temp dir.
 	tmpDir, err := os.MkdirTemp("", "registry-oci-")
 	if err != nil {
 		return nil, fmt.Errorf("unable to create temporary dir while preparing to extract plugin %q: %v", filePath, err)
 	}
 	defer os.RemoveAll(tmpDir)
-	files, err := common.ExtractTarGz(filePath, tmpDir)
-	if err != nil {
+	if _, err := common.ExtractTarGz(filePath, tmpDir); err != nil {
 		return nil, err
 	}
 
@@ -97,21 +99,16 @@ func pluginConfig(name, version, filePath string) (*oci.ArtifactConfig, error) {
 		Requirements: nil,
 	}
 
-	for _, file := range files {
-		// skip files that are not a shared library such as README files.
-		if !strings.HasSuffix(file, ".so") {
-			continue
-		}
-		// Get the requirement for the given file.
-		req, err := pluginRequirement(file)
-		if err != nil && !errors.Is(err, ErrReqNotFound) {
-			return nil, err
-		}
-		// If found add it to the requirements list.
-		if err == nil {
-			_ = cfg.SetRequirement(req.Name, req.Version)
-		}
+	// Get the requirements for the extracted files.
+	reqs, err := ArtifactRequirements(tmpDir, opts...)
+	if err != nil && !errors.Is(err, ErrReqNotFound) {
+		return nil, err
+	}
+	// If found add them to the requirements list.
+	for _, req := range reqs {
+		_ = cfg.SetRequirement(req.Name, req.Version)
 	}
+	SortRequirements(cfg.Requirements)
 
 	if cfg.Requirements == nil {
 		return nil, fmt.Errorf("no requirements found for plugin %q", filePath)
@@ -119,3 +116,70 @@ func pluginConfig(name, version, filePath string) (*oci.ArtifactConfig, error) {
 
 	return cfg, nil
 }
+
+// ConfigMediaType is the media type of the config blob of a plugin or rulesfile artifact.
+type ConfigMediaType string
+
+const (
+	// PluginConfigMediaType is the media type of the config blob of plugin artifacts.
+	PluginConfigMediaType ConfigMediaType = oci.FalcoPluginConfigMediaType
+	// RulesfileConfigMediaType is the media type of the config blob of rulesfile artifacts.
+	RulesfileConfigMediaType ConfigMediaType = oci.FalcoRulesfileConfigMediaType
+)
+
+// ErrInvalidConfig error when the config blob of an artifact does not match the expected schema.
+var ErrInvalidConfig = errors.New("invalid artifact config")
+
+// ValidateConfigOption is a functional option for ValidateArtifactConfig.
+type ValidateConfigOption func(*validateConfigOptions)
+
+type validateConfigOptions struct {
+	allowUnknown bool
+}
+
+// WithAllowUnknownRequirements accepts requirements other than the known ones, see RegisterRequirementName.
+func WithAllowUnknownRequirements() ValidateConfigOption {
+	return func(o *validateConfigOptions) {
+		o.allowUnknown = true
+	}
+}
+
+// ValidateArtifactConfig given the config blob of a pulled artifact it unmarshals it and checks its requirements,
+// which are returned. Each requirement must have a version, must not be declared twice and, unless allowed,
+// must be known, see RegisterRequirementName.
+func ValidateArtifactConfig(data []byte, opts ...ValidateConfigOption) ([]oci.ArtifactRequirement, error) {
+	o := &validateConfigOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	var cfg oci.ArtifactConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal artifact config: %w: %w", ErrInvalidConfig, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, req := range cfg.Requirements {
+		switch {
+		case req.Name == "":
+			return nil, fmt.Errorf("requirement with version %q has no name: %w", req.Version, ErrInvalidConfig)
+		case seen[req.Name]:
+			return nil, fmt.Errorf("requirement %q declared more than once: %w", req.Name, ErrInvalidConfig)
+		case !o.allowUnknown && !isKnownRequirement(req.Name):
+			return nil, fmt.Errorf("requirement %q: %w: %w", req.Name, ErrUnknownRequirement, ErrInvalidConfig)
+		}
+		seen[req.Name] = true
+
+		if isVersionRange(req.Version) {
+			if _, err := semver.ParseRange(req.Version); err != nil {
+				return nil, fmt.Errorf("unable to parse range %q of requirement %q: %w: %w", req.Version, req.Name, ErrInvalidConfig, err)
+			}
+			continue
+		}
+		if _, err := semver.ParseTolerant(req.Version); err != nil {
+			return nil, fmt.Errorf("unable to parse version %q of requirement %q: %w: %w", req.Version, req.Name, ErrInvalidConfig, err)
+		}
+	}
+
+	return cfg.Requirements, nil
+}
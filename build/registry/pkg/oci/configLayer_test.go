@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/blang/semver"
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Rulesfile config", func() {
+	It("should take the dependencies from the decoded rulesfile", func() {
+		archive := filepath.Join(GinkgoT().TempDir(), "k8saudit-rules-0.7.0.tar.gz")
+		writeTarGz(archive, map[string]string{
+			"k8s_audit_rules.yaml": `- required_engine_version: 0.31.0
+
+- required_plugin_version: &k8saudit
+    - name: k8saudit
+      version: 0.7.0
+      alternatives:
+        - name: k8saudit-eks
+          version: 0.4.0
+- required_plugin_versions: *k8saudit
+---
+- required_plugin_versions:
+    - name: json
+      version: 0.7.0
+`,
+		})
+
+		release, err := oci.PrepareRelease(&registry.Plugin{Name: "k8saudit", RulesURL: "https://github.com/falcosecurity/plugins"},
+			&semver.Version{Minor: 7}, archive, true)
+		Expect(err).To(BeNil())
+		Expect(release.Config.Dependencies).To(Equal([]falcoctloci.ArtifactDependency{
+			{Name: "json", Version: "0.7.0"},
+			{Name: "k8saudit", Version: "0.7.0", Alternatives: []falcoctloci.Dependency{{Name: "k8saudit-eks", Version: "0.4.0"}}},
+		}))
+	})
+})
+
+var _ = Describe("Validate artifact config", func() {
+	It("should return the requirements of a valid config", func() {
+		reqs, err := oci.ValidateArtifactConfig([]byte(`{"name":"k8saudit","version":"0.1.0","requirements":[` +
+			`{"name":"plugin_api_version","version":"3.0.0"},{"name":"engine_version_semver","version":">=0.31.0"}]}`))
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[0].Name).To(Equal("plugin_api_version"))
+		Expect(reqs[0].Version).To(Equal("3.0.0"))
+	})
+
+	DescribeTable("should reject invalid configs",
+		func(data string) {
+			_, err := oci.ValidateArtifactConfig([]byte(data))
+			Expect(errors.Is(err, oci.ErrInvalidConfig)).To(BeTrue(), "unexpected error: %v", err)
+		},
+		Entry("not json", `requirements`),
+		Entry("missing name", `{"requirements":[{"version":"3.0.0"}]}`),
+		Entry("missing version", `{"requirements":[{"name":"plugin_api_version"}]}`),
+		Entry("invalid version", `{"requirements":[{"name":"plugin_api_version","version":"three"}]}`),
+		Entry("duplicate", `{"requirements":[{"name":"plugin_api_version","version":"3.0.0"},{"name":"plugin_api_version","version":"3.1.0"}]}`),
+		Entry("unknown name", `{"requirements":[{"name":"kernel_version","version":"5.0.0"}]}`),
+	)
+
+	It("should accept the plugin api feature requirements", func() {
+		reqs, err := oci.ValidateArtifactConfig([]byte(`{"requirements":[{"name":"plugin_api_feature","version":"1.0.0"}]}`))
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(1))
+	})
+
+	It("should reject unknown requirements with a dedicated error", func() {
+		_, err := oci.ValidateArtifactConfig([]byte(`{"requirements":[{"name":"kernel_version","version":"5.0.0"}]}`))
+		Expect(errors.Is(err, oci.ErrUnknownRequirement)).To(BeTrue())
+	})
+
+	It("should accept unknown requirements if allowed", func() {
+		reqs, err := oci.ValidateArtifactConfig([]byte(`{"requirements":[{"name":"kernel_version","version":"5.0.0"}]}`),
+			oci.WithAllowUnknownRequirements())
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(1))
+	})
+})
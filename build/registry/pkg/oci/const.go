@@ -51,4 +51,7 @@ const (
 	amd64OCI       = "amd64"
 	arm64OCI       = "arm64"
 	archive_suffix = ".tar.gz"
+	// Plugin API version supported by the plugin loader of the plugin-sdk-go version this tool is built with. It must
+	// be kept in sync with PLUGIN_API_VERSION in pkg/sdk/plugin_api.h of plugin-sdk-go when bumping it.
+	loaderAPIVersion = "3.0.0"
 )
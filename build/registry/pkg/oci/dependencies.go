@@ -18,66 +18,204 @@ limitations under the License.
 package oci
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"os"
-	"strings"
 
+	"github.com/blang/semver"
 	"github.com/falcosecurity/falcoctl/pkg/oci"
-	"gopkg.in/yaml.v3"
-)
 
-const depsKey = "- required_plugin_versions"
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
 
-// ErrDepNotFound error when the dependencies are not found in the rulesfile.
-var ErrDepNotFound = errors.New("dependencies not found")
+var (
+	// ErrDepNotFound error when the dependencies are not found in the rulesfile.
+	ErrDepNotFound = errors.New("dependencies not found")
+	// ErrUnknownPlugin error when a rulesfile requires a plugin that is not in the registry.
+	ErrUnknownPlugin = errors.New("plugin not found in registry")
+	// ErrInconsistentRequirements error when a rulesfile allows an older engine than the one required by the plugins
+	// it depends on.
+	ErrInconsistentRequirements = errors.New("inconsistent requirements")
+)
 
-// rulesfileDependencies given a rulesfile in yaml format it scans it nad extracts its dependencies.
+// rulesfileDependencies given a rulesfile in yaml format it returns its dependencies, as declared in its
+// "required_plugin_versions" sections, or in the legacy "required_plugin_version" ones. They are built from the same
+// decoded groups used to validate them, see RulesfilePluginRequirementGroups, so that the published config matches
+// what is validated, including anchors and multi-document rulesfiles.
 func rulesfileDependencies(fileName string) ([]oci.ArtifactDependency, error) {
-	var start bool
-	var buf []byte
-	var deps []oci.ArtifactDependency
-
-	// Open the file.
-	file, err := os.Open(fileName)
+	groups, err := RulesfilePluginRequirementGroups(fileName)
+	if errors.Is(err, ErrReqNotFound) {
+		return nil, fmt.Errorf("dependencies for rulesfile %q: %w", fileName, ErrDepNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("unable to open file %q: %v", fileName, file)
+		return nil, err
 	}
 
-	// Prepare the file to be read line by line.
-	fileScanner := bufio.NewScanner(file)
-	fileScanner.Split(bufio.ScanLines)
+	deps := make([]oci.ArtifactDependency, 0, len(groups))
+	for _, g := range groups {
+		dep := oci.ArtifactDependency{Name: g.Options[0].Name, Version: g.Options[0].Version}
+		for _, alt := range g.Options[1:] {
+			dep.SetAlternative(alt.Name, alt.Version)
+		}
+		deps = append(deps, dep)
+	}
+
+	return deps, nil
+}
+
+// ValidateRulesfileDependencies given the registry and a list of rulesfiles it checks that each plugin required by
+// the rulesfiles, as declared in their "required_plugin_versions" sections, is an entry of the registry. This catches
+// rulesfiles requiring a plugin that has been renamed or removed. All the unknown plugins are reported in the returned
+// error. Alternatives are not checked, since they are not required to exist. Rulesfiles not requiring any plugin are valid.
+func ValidateRulesfileDependencies(reg *registry.Registry, rulesfiles []string) error {
+	names := make(map[string]bool, len(reg.Plugins))
+	for _, p := range reg.Plugins {
+		names[p.Name] = true
+	}
+
+	var errs []error
+	for _, file := range rulesfiles {
+		reqs, err := rulesfilePluginRequirements(file)
+		if errors.Is(err, ErrReqNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, req := range reqs {
+			if !names[req.Name] {
+				errs = append(errs, fmt.Errorf("rulesfile %q requires plugin %q: %w", file, req.Name, ErrUnknownPlugin))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PluginRelease is a released version of a plugin together with its requirements, as in the config of its artifact.
+type PluginRelease struct {
+	Name         string
+	Version      string
+	Requirements []oci.ArtifactRequirement
+}
+
+// EngineForPluginAPI returns the oldest engine version supporting the given plugin api version, false if unknown.
+type EngineForPluginAPI func(apiVersion string) (string, bool)
+
+// CheckRulesfileConsistency checks that the engine version required by a rulesfile is not older than the one required
+// by the plugins it depends on, as declared in its "required_plugin_versions" sections. For each required plugin the
+// oldest of the given releases satisfying the required version is considered, since it is the oldest one the rulesfile
+// can be loaded with. Its engine requirement is the declared one, if any, or the one supporting its plugin api version
+// as returned by engineForAPI, if not nil. A plugin declared with alternatives is consistent if any of them is, since
+// the rulesfile can be loaded with any of them, see PluginRequirementGroup. All the inconsistencies are reported in
+// the returned error, wrapping ErrInconsistentRequirements. Rulesfiles requiring a range of engine versions, or no
+// engine version at all, and the plugins without releases are not checked. The engine requirement is extracted with
+// the given options.
+func CheckRulesfileConsistency(filePath string, releases []PluginRelease, engineForAPI EngineForPluginAPI, opts ...RequirementOption) error {
+	engineReq, engineVer, err := rulesfileRequirementVersion(filePath, opts...)
+	if errors.Is(err, ErrReqNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if engineVer == nil {
+		return nil
+	}
 
-	// Is appended to each line when inserted in the buffer.
-	newLine := []byte("\n")
+	groups, err := RulesfilePluginRequirementGroups(filePath)
+	if errors.Is(err, ErrReqNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-	// Falco rulesfiles are a list of dictionaries. We only want the "required plugin versions" by the ruleset. We do
-	// not want to load all the file in memory, so we scan it line by line. When we reach the interested section we save
-	// each line in a buffer, and after that we unmarshal it to a proper data structure.
-	for fileScanner.Scan() {
-		// If we have already found the section of interest, and we get a new item of the list then we stop.
-		if start {
-			if strings.HasPrefix(fileScanner.Text(), "-") {
+	var errs []error
+	for _, group := range groups {
+		var groupErrs []error
+		for _, dep := range group.Options {
+			release, err := oldestPluginRelease(releases, dep)
+			if err != nil {
+				return err
+			}
+			if release == nil {
 				break
-			} else {
-				buf = append(buf, fileScanner.Bytes()...)
-				buf = append(buf, newLine...)
 			}
-		} else {
-			if strings.HasPrefix(fileScanner.Text(), depsKey) {
-				start = true
+
+			pluginEngine, err := pluginEngineRequirement(release, engineForAPI)
+			if err != nil {
+				return err
+			}
+			if pluginEngine == nil || !pluginEngine.GT(*engineVer) {
+				break
 			}
+
+			groupErrs = append(groupErrs, fmt.Errorf("rulesfile %q requires engine version %q, but plugin %q version %q, the oldest "+
+				"satisfying the required version %q, requires engine version %q: %w", filePath, engineReq.Version,
+				release.Name, release.Version, dep.Version, pluginEngine.String(), ErrInconsistentRequirements))
+		}
+
+		// The group is consistent as soon as one of its options is.
+		if len(groupErrs) == len(group.Options) {
+			errs = append(errs, groupErrs...)
 		}
 	}
 
-	if !start {
-		return nil, fmt.Errorf("dependencies for rulesfile %q: %w", fileName, ErrDepNotFound)
+	return errors.Join(errs...)
+}
+
+// oldestPluginRelease returns the oldest of the releases of the plugin with the name of the given requirement whose
+// version satisfies it, nil if there is none.
+func oldestPluginRelease(releases []PluginRelease, req oci.ArtifactRequirement) (*PluginRelease, error) {
+	minVer, err := semver.ParseTolerant(req.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse version %q for plugin %q: %w: %w", req.Version, req.Name, ErrParseFailed, err)
 	}
 
-	if err := yaml.Unmarshal(buf, &deps); err != nil {
-		return nil, fmt.Errorf("unable to unmarshal the required plugins versions: %w", err)
+	var oldest *PluginRelease
+	var oldestVer semver.Version
+	for i := range releases {
+		if releases[i].Name != req.Name {
+			continue
+		}
+		v, err := semver.ParseTolerant(releases[i].Version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse version %q of plugin %q: %w: %w", releases[i].Version, req.Name, ErrParseFailed, err)
+		}
+		if v.LT(minVer) || (oldest != nil && !v.LT(oldestVer)) {
+			continue
+		}
+		oldest, oldestVer = &releases[i], v
 	}
 
-	return deps, nil
+	return oldest, nil
+}
+
+// pluginEngineRequirement returns the engine version required by the given plugin release, nil if unknown.
+func pluginEngineRequirement(release *PluginRelease, engineForAPI EngineForPluginAPI) (*semver.Version, error) {
+	var version string
+	for _, req := range release.Requirements {
+		if req.Name == common.EngineVersionKey {
+			version = req.Version
+			break
+		}
+		if req.Name == common.PluginAPIVersion && engineForAPI != nil {
+			if v, ok := engineForAPI(req.Version); ok {
+				version = v
+			}
+		}
+	}
+	if version == "" {
+		return nil, nil
+	}
+
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse engine version %q of plugin %q version %q: %w: %w",
+			version, release.Name, release.Version, ErrParseFailed, err)
+	}
+
+	return &v, nil
 }
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Validate rulesfile dependencies", func() {
+	reg := &registry.Registry{Plugins: []registry.Plugin{{Name: "k8saudit"}, {Name: "json"}}}
+
+	It("should accept the plugins in the registry", func() {
+		valid := writeRulesfile(`- required_plugin_versions:
+  - name: k8saudit
+    version: 0.7.0
+    alternatives:
+      - name: k8saudit-eks
+        version: 0.4.0
+  - name: json
+    version: 0.7.0
+`)
+		noPlugins := writeRulesfile("- required_engine_version: 15\n")
+		Expect(oci.ValidateRulesfileDependencies(reg, []string{valid, noPlugins})).To(Succeed())
+	})
+
+	It("should report all the unknown plugins", func() {
+		unknown := writeRulesfile(`- required_plugin_versions:
+  - name: k8saudit-old
+    version: 0.1.0
+  - name: cloudtrail
+    version: 0.1.0
+`)
+		err := oci.ValidateRulesfileDependencies(reg, []string{unknown})
+		Expect(err).To(MatchError(oci.ErrUnknownPlugin))
+		Expect(err.Error()).To(ContainSubstring(`"k8saudit-old"`))
+		Expect(err.Error()).To(ContainSubstring(`"cloudtrail"`))
+	})
+})
+
+var _ = Describe("Check rulesfile consistency", func() {
+	releases := []oci.PluginRelease{
+		{Name: "json", Version: "0.6.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "1.0.0"}}},
+		{Name: "json", Version: "0.8.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "3.0.0"}}},
+		{Name: "json", Version: "0.7.1", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "2.0.0"}}},
+		{Name: "k8saudit", Version: "0.5.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.15.0"}}},
+	}
+	engineForAPI := func(apiVersion string) (string, bool) {
+		engines := map[string]string{"1.0.0": "0.10.0", "2.0.0": "0.25.0", "3.0.0": "0.30.0"}
+		v, ok := engines[apiVersion]
+		return v, ok
+	}
+	rulesfile := func(engine string) string {
+		return writeRulesfile(`- required_engine_version: ` + engine + `
+- required_plugin_versions:
+  - name: json
+    version: 0.7.0
+  - name: k8saudit
+    version: 0.4.0
+  - name: cloudtrail
+    version: 0.1.0
+`)
+	}
+
+	It("should report the plugins requiring a newer engine", func() {
+		// The oldest json release satisfying 0.7.0 is 0.7.1, requiring the engine 0.25.0.
+		err := oci.CheckRulesfileConsistency(rulesfile("20"), releases, engineForAPI)
+		Expect(err).To(MatchError(oci.ErrInconsistentRequirements))
+		Expect(err.Error()).To(ContainSubstring(`plugin "json" version "0.7.1"`))
+		Expect(err.Error()).ToNot(ContainSubstring("k8saudit"))
+
+		err = oci.CheckRulesfileConsistency(rulesfile("10"), releases, engineForAPI)
+		Expect(err.Error()).To(ContainSubstring(`"json"`))
+		Expect(err.Error()).To(ContainSubstring(`"k8saudit"`))
+	})
+
+	It("should accept the rulesfiles requiring a recent enough engine", func() {
+		for _, engine := range []string{"25", "0.31.0", `">=0.10.0"`} {
+			Expect(oci.CheckRulesfileConsistency(rulesfile(engine), releases, engineForAPI)).To(Succeed(), "engine %s", engine)
+		}
+
+		// The engine version of plugins is unknown without the api versions supported by each engine.
+		Expect(oci.CheckRulesfileConsistency(rulesfile("20"), releases, nil)).To(Succeed())
+	})
+
+	It("should be consistent with any of the alternatives", func() {
+		filePath := writeRulesfile(`- required_engine_version: 0.20.0
+- required_plugin_versions:
+  - name: k8saudit
+    version: 0.7.0
+    alternatives:
+      - name: k8saudit-eks
+        version: 0.2.0
+  - name: json
+    version: 0.7.0
+`)
+		// The rulesfile is consistent with the alternative, even if not with the required plugin.
+		releases := []oci.PluginRelease{
+			{Name: "k8saudit", Version: "0.7.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.30.0"}}},
+			{Name: "k8saudit-eks", Version: "0.2.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.15.0"}}},
+		}
+		Expect(oci.CheckRulesfileConsistency(filePath, releases, nil)).To(Succeed())
+		releases[1].Requirements[0].Version = "0.25.0"
+		Expect(oci.CheckRulesfileConsistency(filePath, releases, nil)).To(MatchError(oci.ErrInconsistentRequirements))
+	})
+})
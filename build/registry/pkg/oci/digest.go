@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrDigestMismatch error when the sha256 digest of a plugin does not match the expected one, see WithExpectedDigest.
+// It always comes together with ErrOpenFailed.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// WithExpectedDigest sets the expected sha256 digest of the plugin, hex encoded and optionally prefixed by "sha256:",
+// e.g. as published along with a release. The plugin is copied to a private temporary file, see WithPluginTempDir,
+// computing the digest of the copy, and only the verified copy is loaded: the plugin is not loaded at all if the
+// digest does not match, and a file swapped after the verification is never loaded. For plugins compressed with gzip
+// the digest is the one of the compressed file. It has no effect on rulesfiles.
+func WithExpectedDigest(digest string) RequirementOption {
+	return func(o *requirementOptions) {
+		o.expectedDigest = digest
+	}
+}
+
+// fileDigest returns the hex encoded sha256 digest of the given file, streaming it to avoid loading it in memory.
+func fileDigest(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open file %q: %w", filePath, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("unable to compute digest of file %q: %w", filePath, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyDigest checks the sha256 digest of the given file against the expected one, returning an error wrapping both
+// ErrOpenFailed and ErrDigestMismatch on mismatch. It is only suitable for files that are not loaded afterwards,
+// use digestReader to verify the content being loaded.
+func verifyDigest(filePath, expected string) error {
+	actual, err := fileDigest(filePath)
+	if err != nil {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to verify digest of plugin %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+
+	return checkDigest(filePath, actual, expected)
+}
+
+// checkDigest compares the actual digest of the given plugin with the expected one, see verifyDigest.
+func checkDigest(filePath, actual, expected string) error {
+	expected = normalizeDigest(expected)
+	if actual != expected {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("plugin %q has digest %q, expected %q: %w: %w",
+			filePath, actual, expected, ErrOpenFailed, ErrDigestMismatch))
+	}
+
+	return nil
+}
+
+// normalizeDigest returns the given sha256 digest hex encoded in lowercase, without the "sha256:" prefix.
+func normalizeDigest(digest string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(digest), "sha256:"))
+}
+
+// digestReader computes the sha256 digest of the content read through it, so that the content is verified as it is
+// consumed, e.g. while copying a plugin to the file it is loaded from, with no window for it to change in between.
+type digestReader struct {
+	name string
+	r    io.Reader
+	hash hash.Hash
+}
+
+func newDigestReader(name string, r io.Reader) *digestReader {
+	return &digestReader{name: name, r: r, hash: sha256.New()}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+	return n, err
+}
+
+// digest returns the hex encoded digest of the content read so far.
+func (d *digestReader) digest() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// check reads what is left of the content and compares its digest with the expected one, see verifyDigest.
+func (d *digestReader) check(expected string) error {
+	if _, err := io.Copy(io.Discard, d); err != nil {
+		return newRequirementError(d.name, StageOpen, fmt.Errorf("unable to verify digest of plugin %q: %w: %w", d.name, ErrOpenFailed, err))
+	}
+
+	return checkDigest(d.name, d.digest(), expected)
+}
+
+// eofCheckReader runs check when the given reader reaches its end, returning its error, if any, instead of io.EOF.
+// This way the consumer of the reader, e.g. a copy to a temporary file, fails before using what it has read.
+type eofCheckReader struct {
+	r     io.Reader
+	check func() error
+}
+
+func (e *eofCheckReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		if checkErr := e.check(); checkErr != nil {
+			return n, checkErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Expected digest", func() {
+	var content []byte
+	var filePath, digest string
+
+	BeforeEach(func() {
+		content = []byte("not a shared library")
+		filePath = filepath.Join(GinkgoT().TempDir(), "libfake.so")
+		Expect(os.WriteFile(filePath, content, 0o600)).To(Succeed())
+		sum := sha256.Sum256(content)
+		digest = hex.EncodeToString(sum[:])
+	})
+
+	It("should fail for the plugins not matching the digest", func() {
+		_, err := oci.PluginRequirement(filePath, oci.WithExpectedDigest(strings.Repeat("0", len(digest))))
+		Expect(err).To(MatchError(oci.ErrDigestMismatch))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+
+		// A matching digest lets the plugin be loaded, which fails since the file is not a shared library.
+		for _, expected := range []string{digest, "sha256:" + strings.ToUpper(digest)} {
+			_, err := oci.PluginRequirement(filePath, oci.WithExpectedDigest(expected))
+			Expect(err).ToNot(MatchError(oci.ErrDigestMismatch), "digest %q", expected)
+			Expect(err).To(MatchError(oci.ErrOpenFailed), "digest %q", expected)
+		}
+	})
+
+	It("should verify the info of the plugins, removing the rejected copy", func() {
+		tmpDir := GinkgoT().TempDir()
+		_, err := oci.LoadPluginInfo(filePath, oci.WithExpectedDigest(strings.Repeat("0", len(digest))), oci.WithPluginTempDir(tmpDir))
+		Expect(err).To(MatchError(oci.ErrDigestMismatch))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+	})
+
+	It("should verify the compressed plugins with the digest of the compressed file", func() {
+		compressed := gzipData(content)
+		gzipPath := filepath.Join(GinkgoT().TempDir(), "libfake.so.gz")
+		Expect(os.WriteFile(gzipPath, compressed, 0o600)).To(Succeed())
+
+		_, err := oci.PluginRequirement(gzipPath, oci.WithExpectedDigest(digest))
+		Expect(err).To(MatchError(oci.ErrDigestMismatch))
+
+		gzipSum := sha256.Sum256(compressed)
+		_, err = oci.PluginRequirement(gzipPath, oci.WithExpectedDigest(hex.EncodeToString(gzipSum[:])))
+		Expect(err).ToNot(MatchError(oci.ErrDigestMismatch))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+	})
+})
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// ComputedRequirements holds the requirements computed for a given version of an artifact.
+type ComputedRequirements struct {
+	Artifact     string
+	Version      string
+	Requirements []oci.ArtifactRequirement
+}
+
+// packagedArtifact is a plugin or rulesfile archive found in the packages directory.
+type packagedArtifact struct {
+	Artifact  string
+	Version   string
+	FilePath  string
+	Rulesfile bool
+}
+
+// packagedArtifacts given the registry entries it looks for the archives of the plugins and rulesfiles that would be
+// published by DoUpdateOCIRegistry in the packagesDir, as produced by the "packages" target of the main Makefile.
+// Plugin archives not built for the current platform are skipped, since they can not be loaded, logging it to log.
+func packagedArtifacts(reg *registry.Registry, packagesDir string, log *slog.Logger) ([]packagedArtifact, error) {
+	entries, err := os.ReadDir(packagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read packages directory %q: %w", packagesDir, err)
+	}
+
+	var artifacts []packagedArtifact
+	platform := currentPlatform()
+
+	for _, plugin := range reg.Plugins {
+		// Only the plugins maintained by falcosecurity are published.
+		if plugin.Authors != falcoAuthors {
+			continue
+		}
+
+		pluginRgx := regexp.MustCompile(fmt.Sprintf(`^%s-([0-9].*)-linux-(?:%s|%s)%s$`,
+			regexp.QuoteMeta(plugin.Name), x86_arch_s3, arm_aarch64_s3, regexp.QuoteMeta(archive_suffix)))
+		rulesRgx := regexp.MustCompile(fmt.Sprintf(`^%s-([0-9].*)%s$`,
+			regexp.QuoteMeta(rulesfileNameFromPlugin(plugin.Name)), regexp.QuoteMeta(archive_suffix)))
+
+		for _, entry := range entries {
+			filePath := filepath.Join(packagesDir, entry.Name())
+
+			if m := pluginRgx.FindStringSubmatch(entry.Name()); m != nil {
+				// We can only load the plugins built for the platform where we are running.
+				if platformFromS3Key(entry.Name()) != platform {
+					log.Info("skipping archive not built for the current platform", "archive", entry.Name(), "platform", platform)
+					continue
+				}
+
+				artifacts = append(artifacts, packagedArtifact{
+					Artifact: plugin.Name,
+					Version:  m[1],
+					FilePath: filePath,
+				})
+			} else if m := rulesRgx.FindStringSubmatch(entry.Name()); m != nil {
+				artifacts = append(artifacts, packagedArtifact{
+					Artifact:  rulesfileNameFromPlugin(plugin.Name),
+					Version:   m[1],
+					FilePath:  filePath,
+					Rulesfile: true,
+				})
+			}
+		}
+	}
+
+	return artifacts, nil
+}
+
+// DoDryRunOCIRegistry computes the requirements of the plugins and rulesfiles that would be published by
+// DoUpdateOCIRegistry, without contacting any remote service. Instead of downloading the archives from the s3
+// bucket, it looks for them in the packagesDir, as produced by the "packages" target of the main Makefile. The progress
+// can be reported for each archive with WithProgress, and the extraction customized with WithBatchRequirementOptions.
+func DoDryRunOCIRegistry(registryFile, packagesDir string, opts ...BatchOption) ([]ComputedRequirements, error) {
+	reg, err := registry.LoadRegistryFromFile(registryFile)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred while loading registry entries from file %q: %v", registryFile, err)
+	}
+
+	reqOpts := newBatchOptions(opts).reqOpts
+	artifacts, err := packagedArtifacts(reg, packagesDir, newRequirementOptions(reqOpts).log())
+	if err != nil {
+		return nil, err
+	}
+
+	computed := []ComputedRequirements{}
+	progress := newProgressTracker(opts, len(artifacts))
+
+	for _, a := range artifacts {
+		var cfg *oci.ArtifactConfig
+		if a.Rulesfile {
+			cfg, err = rulesfileConfig(a.Artifact, a.Version, a.FilePath, reqOpts...)
+		} else {
+			cfg, err = pluginConfig(a.Artifact, a.Version, a.FilePath, reqOpts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		computed = append(computed, ComputedRequirements{
+			Artifact:     a.Artifact,
+			Version:      a.Version,
+			Requirements: cfg.Requirements,
+		})
+		progress.done(a.FilePath)
+	}
+
+	return computed, nil
+}
+
+// PrintDryRun writes the computed requirements as a table with a row for each requirement of an artifact.
+func PrintDryRun(computed []ComputedRequirements, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARTIFACT\tVERSION\tREQUIREMENT\tREQUIRED VERSION")
+	for _, c := range computed {
+		for _, req := range c.Requirements {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Artifact, c.Version, req.Name, req.Version)
+		}
+	}
+
+	return w.Flush()
+}
+
+// RequirementsJSON given a list of requirements it returns them as a json array of objects with the "name" and
+// "version" keys. Requirements are sorted so that the output is deterministic, see SortRequirements.
+func RequirementsJSON(reqs []oci.ArtifactRequirement) ([]byte, error) {
+	// Always produce an array, even when there are no requirements.
+	sorted := append([]oci.ArtifactRequirement{}, reqs...)
+	SortRequirements(sorted)
+
+	return json.Marshal(sorted)
+}
+
+// dryRunJSONEntry is the json representation of the requirements computed for a given version of an artifact.
+type dryRunJSONEntry struct {
+	Artifact     string          `json:"artifact"`
+	Version      string          `json:"version"`
+	Requirements json.RawMessage `json:"requirements"`
+}
+
+// PrintDryRunJSON writes the computed requirements as a json array with an object for each version of an artifact,
+// see RequirementsJSON. Artifacts are sorted by name, and then by version, so that the output is deterministic.
+func PrintDryRunJSON(computed []ComputedRequirements, output io.Writer) error {
+	sorted := slices.Clone(computed)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Artifact != sorted[j].Artifact {
+			return sorted[i].Artifact < sorted[j].Artifact
+		}
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	entries := make([]dryRunJSONEntry, 0, len(sorted))
+	for _, c := range sorted {
+		reqs, err := RequirementsJSON(c.Requirements)
+		if err != nil {
+			return fmt.Errorf("unable to marshal requirements of artifact %q: %w", c.Artifact, err)
+		}
+		entries = append(entries, dryRunJSONEntry{
+			Artifact:     c.Artifact,
+			Version:      c.Version,
+			Requirements: reqs,
+		})
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
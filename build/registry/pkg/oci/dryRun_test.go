@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"bytes"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Requirements JSON", func() {
+	It("should sort the requirements by name", func() {
+		data, err := oci.RequirementsJSON([]falcoctloci.ArtifactRequirement{
+			{Name: "plugin_api_version", Version: "3.0.0"},
+			{Name: "engine_version_semver", Version: "0.31.0"},
+		})
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(`[{"name":"engine_version_semver","version":"0.31.0"},{"name":"plugin_api_version","version":"3.0.0"}]`))
+	})
+
+	It("should return an empty array for no requirements", func() {
+		data, err := oci.RequirementsJSON(nil)
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(`[]`))
+	})
+
+	It("should print the dry run sorted by artifact and version", func() {
+		var buf bytes.Buffer
+		Expect(oci.PrintDryRunJSON([]oci.ComputedRequirements{
+			{Artifact: "json", Version: "0.2.0"},
+			{Artifact: "json", Version: "0.1.0", Requirements: []falcoctloci.ArtifactRequirement{{Name: "plugin_api_version", Version: "3.0.0"}}},
+		}, &buf)).To(Succeed())
+		Expect(buf.String()).To(MatchJSON(`[
+			{"artifact":"json","version":"0.1.0","requirements":[{"name":"plugin_api_version","version":"3.0.0"}]},
+			{"artifact":"json","version":"0.2.0","requirements":[]}
+		]`))
+	})
+})
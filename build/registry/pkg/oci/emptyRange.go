@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// ErrUnsatisfiableRange error when a rulesfile requires a range of engine versions no version is in, e.g.
+// ">=0.40.0 <=0.31.0", hence no consumer could ever load it. It always comes together with ErrParseFailed.
+var ErrUnsatisfiableRange = errors.New("unsatisfiable engine version range")
+
+// rangeBound is the lower or upper bound of the versions satisfying the constraints of a range.
+type rangeBound struct {
+	// constraint is the constraint setting the bound, e.g. ">=0.31.0", reported in errors.
+	constraint string
+	version    semver.Version
+	inclusive  bool
+}
+
+// checkSatisfiableRange returns an error wrapping both ErrUnsatisfiableRange and ErrParseFailed if no version
+// satisfies the given range, already validated with semver.ParseRange, reporting the min and max bounds of each of
+// its alternatives. Only the alternatives made of comparisons are checked, the other ones, e.g. the ones excluding a
+// version with "!=", are considered satisfiable.
+func checkSatisfiableRange(value string) error {
+	var empty []string
+	for _, alternative := range strings.Split(value, "||") {
+		lower, upper, ok := rangeBounds(alternative)
+		if !ok || lower == nil || upper == nil {
+			return nil
+		}
+
+		cmp := lower.version.Compare(upper.version)
+		if cmp < 0 || (cmp == 0 && lower.inclusive && upper.inclusive) {
+			return nil
+		}
+		empty = append(empty, fmt.Sprintf("min %q, max %q", lower.constraint, upper.constraint))
+	}
+
+	return fmt.Errorf("engine version range %q matches no version (%s): %w: %w", value, strings.Join(empty, "; "), ErrUnsatisfiableRange, ErrParseFailed)
+}
+
+// rangeBounds returns the tightest lower and upper bounds set by the constraints of the given alternative of a range,
+// nil if not bounded, and false if it has constraints other than comparisons.
+func rangeBounds(alternative string) (lower, upper *rangeBound, ok bool) {
+	for _, constraint := range strings.Fields(alternative) {
+		op, version := splitConstraint(constraint)
+		if op == "!" || op == "!=" {
+			return nil, nil, false
+		}
+		v, err := semver.ParseTolerant(version)
+		if err != nil {
+			return nil, nil, false
+		}
+
+		bound := &rangeBound{constraint: constraint, version: v, inclusive: op != ">" && op != "<"}
+		if op != "<" && op != "<=" && tighterLower(bound, lower) {
+			lower = bound
+		}
+		if op != ">" && op != ">=" && tighterUpper(bound, upper) {
+			upper = bound
+		}
+	}
+
+	return lower, upper, true
+}
+
+// splitConstraint splits a constraint of a range, e.g. ">=0.31.0", into its operator and version. The operator is
+// empty for bare versions, which are required to be equal.
+func splitConstraint(constraint string) (op, version string) {
+	i := strings.IndexFunc(constraint, func(r rune) bool { return !strings.ContainsRune("<>=!", r) })
+	if i < 0 {
+		return constraint, ""
+	}
+
+	return constraint[:i], constraint[i:]
+}
+
+// tighterLower reports whether bound is a tighter lower bound than current, nil if not set.
+func tighterLower(bound, current *rangeBound) bool {
+	if current == nil {
+		return true
+	}
+	cmp := bound.version.Compare(current.version)
+	return cmp > 0 || (cmp == 0 && !bound.inclusive)
+}
+
+// tighterUpper reports whether bound is a tighter upper bound than current, nil if not set.
+func tighterUpper(bound, current *rangeBound) bool {
+	if current == nil {
+		return true
+	}
+	cmp := bound.version.Compare(current.version)
+	return cmp < 0 || (cmp == 0 && !bound.inclusive)
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Unsatisfiable ranges", func() {
+	It("should reject the unsatisfiable ranges", func() {
+		// Reversed bounds, both as a range and as min and max keys.
+		for _, content := range []string{
+			"- required_engine_version: \">=0.40.0 <=0.31.0\"\n",
+			"- required_engine_version_min: 0.40.0\n- required_engine_version_max: 0.31.0\n",
+		} {
+			_, err := oci.RulesfileRequirement(writeRulesfile(content))
+			Expect(err).To(MatchError(oci.ErrUnsatisfiableRange))
+			Expect(err).To(MatchError(oci.ErrParseFailed))
+			Expect(err.Error()).To(ContainSubstring(`min ">=0.40.0", max "<=0.31.0"`))
+		}
+
+		// Equal bounds are satisfied by that version only.
+		req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version_min: 0.31.0\n- required_engine_version_max: 0.31.0\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal(">=0.31.0 <=0.31.0"))
+	})
+
+	DescribeTable("should check that the ranges are satisfiable",
+		func(value string, unsatisfiable bool) {
+			_, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: \"" + value + "\"\n"))
+			if !unsatisfiable {
+				Expect(err).To(BeNil())
+				return
+			}
+			Expect(err).To(MatchError(oci.ErrUnsatisfiableRange))
+			Expect(err).To(MatchError(oci.ErrParseFailed))
+		},
+		Entry(nil, ">=0.31.0 <0.40.0", false),
+		Entry(nil, ">=0.40.0 <=0.31.0", true),
+		Entry(nil, ">=0.31.0 <=0.31.0", false),
+		Entry(nil, ">=0.31.0 <0.31.0", true),
+		Entry(nil, ">0.31.0 <=0.31.0", true),
+		Entry(nil, "0.31.0 >0.31.0", true),
+		Entry(nil, ">=0.31.0 >=0.40.0 <0.35.0", true),
+		Entry(nil, ">=0.40.0 <=0.31.0 || >=0.35.0", false),
+		Entry(nil, ">=0.40.0 <=0.31.0 || >0.35.0 <0.35.0", true),
+		Entry(nil, ">=0.31.0 !=0.31.0 <=0.31.0", false),
+	)
+})
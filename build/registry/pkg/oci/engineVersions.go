@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blang/semver"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// EngineVersionUsage is an engine version required by the rulesfiles of the registry, together with the rulesfiles
+// requiring it.
+type EngineVersionUsage struct {
+	Version string `json:"version"`
+	// Count is the number of rulesfiles requiring the version.
+	Count int `json:"count"`
+	// Files are the rulesfiles requiring the version, relative to the plugins directory and sorted.
+	Files []string `json:"files"`
+}
+
+// RegistryEngineVersions given the registry and the directory containing the source tree of the plugins, as the
+// "plugins" directory of this repository, it returns the distinct engine versions required by the rulesfiles of the
+// registry, with the rulesfiles requiring each of them, e.g. to tell whether an old engine is still required. As for
+// RegistrySummary, the rulesfiles of an entry are expected in "<pluginsDir>/<name>/rules", and reserved entries are
+// skipped, as are the rulesfiles not declaring an engine requirement. The versions are sorted from the oldest, the
+// ranges being sorted after them, lexically. The requirements are extracted with the given options.
+func RegistryEngineVersions(reg *registry.Registry, pluginsDir string, opts ...RequirementOption) ([]EngineVersionUsage, error) {
+	files := make(map[string][]string)
+	for _, p := range reg.Plugins {
+		if p.Reserved || p.RulesURL == "" {
+			continue
+		}
+
+		dir := filepath.Join(pluginsDir, p.Name, "rules")
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		reqs, err := WalkRequirements(dir, WithWalkRequirementOptions(opts...))
+		if err != nil {
+			return nil, err
+		}
+		for relPath, req := range reqs {
+			if req.Name != common.EngineVersionKey {
+				continue
+			}
+			files[req.Version] = append(files[req.Version], path.Join(p.Name, "rules", relPath))
+		}
+	}
+
+	usages := make([]EngineVersionUsage, 0, len(files))
+	for version, f := range files {
+		sort.Strings(f)
+		usages = append(usages, EngineVersionUsage{Version: version, Count: len(f), Files: f})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		vi, erri := semver.ParseTolerant(usages[i].Version)
+		vj, errj := semver.ParseTolerant(usages[j].Version)
+		switch {
+		case erri == nil && errj == nil:
+			return vi.LT(vj)
+		case erri == nil || errj == nil:
+			return erri == nil
+		default:
+			return usages[i].Version < usages[j].Version
+		}
+	})
+
+	return usages, nil
+}
+
+// PrintEngineVersions writes a line for each engine version, with the number of rulesfiles requiring it and their
+// paths.
+func PrintEngineVersions(usages []EngineVersionUsage, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCOUNT\tFILES")
+	for _, u := range usages {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", u.Version, u.Count, strings.Join(u.Files, ", "))
+	}
+
+	return w.Flush()
+}
+
+// PrintEngineVersionsJSON writes the engine versions as an indented json list.
+func PrintEngineVersionsJSON(usages []EngineVersionUsage, output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(usages)
+}
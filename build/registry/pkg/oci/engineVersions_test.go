@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Registry engine versions", func() {
+	It("should count the rulesfiles requiring each engine version", func() {
+		pluginsDir := GinkgoT().TempDir()
+		writeFiles(pluginsDir, map[string]string{
+			"k8saudit/rules/k8s_audit_rules.yaml": "- required_engine_version: 0.15.0\n",
+			"okta/rules/okta_rules.yaml":          "- required_engine_version: 11\n",
+			"github/rules/github.yaml":            "- required_engine_version: 0.15.0\n",
+			"json/rules/unreferenced.yaml":        "- required_engine_version: 0.31.0\n",
+			"gcpaudit/rules/macros.yaml":          "- macro: gcp\n  condition: gcp.user exists\n",
+		})
+		rulesURL := "https://github.com/falcosecurity/plugins/tree/main/plugins"
+		reg := &registry.Registry{Plugins: []registry.Plugin{
+			{Name: "k8saudit", RulesURL: rulesURL},
+			{Name: "okta", RulesURL: rulesURL},
+			{Name: "github", RulesURL: rulesURL},
+			{Name: "gcpaudit", RulesURL: rulesURL},
+			{Name: "cloudtrail", RulesURL: rulesURL},
+			// Only the entries declaring rulesfiles are considered.
+			{Name: "json"},
+		}}
+
+		usages, err := oci.RegistryEngineVersions(reg, pluginsDir)
+		Expect(err).To(BeNil())
+		Expect(usages).To(Equal([]oci.EngineVersionUsage{
+			{Version: "0.11.0", Count: 1, Files: []string{"okta/rules/okta_rules.yaml"}},
+			{Version: "0.15.0", Count: 2, Files: []string{"github/rules/github.yaml", "k8saudit/rules/k8s_audit_rules.yaml"}},
+		}))
+
+		var b strings.Builder
+		Expect(oci.PrintEngineVersions(usages, &b)).To(Succeed())
+		Expect(b.String()).To(ContainSubstring("0.15.0   2      github/rules/github.yaml, k8saudit/rules/k8s_audit_rules.yaml\n"))
+	})
+})
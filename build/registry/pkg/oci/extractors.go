@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+)
+
+// ErrNoExtractor error when no registered extractor can handle a file.
+var ErrNoExtractor = errors.New("no requirement extractor for file")
+
+// ErrMultipleRequirements error when the requirement of a file is to be returned, e.g. by BatchRequirements, but the
+// extractor handling it returns more than one. Use ExtractRequirements to get them all.
+var ErrMultipleRequirements = errors.New("multiple requirements for file")
+
+// ErrUnknownRequirement error when a requirement has a name that is not known, see RegisterRequirementName.
+var ErrUnknownRequirement = errors.New("unknown requirement")
+
+// RequirementExtractor extracts the requirements of a kind of file bundled in the artifacts, such as plugins as
+// shared libraries or rulesfiles. New kinds of artifacts are supported by registering an extractor for them, see
+// RegisterRequirementExtractor.
+type RequirementExtractor interface {
+	// CanHandle returns true if the extractor can extract the requirements of the given file. It is expected to
+	// only look at the path, e.g. at the extension, without reading the file.
+	CanHandle(path string) bool
+	// Extract returns the requirements of the given file, or an error wrapping ErrReqNotFound if it does not
+	// declare any.
+	Extract(path string) ([]oci.ArtifactRequirement, error)
+}
+
+// ContextRequirementExtractor is a RequirementExtractor whose extraction can be aborted by canceling a context, and
+// customized with the options the extraction has been asked for. The extractions use ExtractContext if implemented,
+// and otherwise only check the context before calling Extract, the options being ignored.
+type ContextRequirementExtractor interface {
+	RequirementExtractor
+	// ExtractContext is the same as Extract, but the extraction is aborted if the context is canceled. The options
+	// can be passed on to e.g. RulesfileRequirement or PluginRequirement.
+	ExtractContext(ctx context.Context, path string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error)
+}
+
+// extensionExtractor is a RequirementExtractor handling the files with the given extensions, but the sidecars
+// overriding the requirements, see RequirementsOverrideSuffix.
+type extensionExtractor struct {
+	extensions []string
+	extract    func(ctx context.Context, path string, opts ...RequirementOption) (*oci.ArtifactRequirement, error)
+}
+
+// CanHandle implements the RequirementExtractor interface.
+func (e *extensionExtractor) CanHandle(path string) bool {
+	if isRequirementsOverride(path) {
+		return false
+	}
+
+	for _, ext := range e.extensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Extract implements the RequirementExtractor interface.
+func (e *extensionExtractor) Extract(path string) ([]oci.ArtifactRequirement, error) {
+	return e.ExtractContext(context.Background(), path)
+}
+
+// ExtractContext implements the ContextRequirementExtractor interface.
+func (e *extensionExtractor) ExtractContext(ctx context.Context, path string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error) {
+	req, err := e.extract(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []oci.ArtifactRequirement{*req}, nil
+}
+
+var (
+	extractorsMu sync.RWMutex
+	// extractors are the registered extractors, the first one that can handle a file is used.
+	extractors = []RequirementExtractor{
+		&extensionExtractor{extensions: []string{".so", ".so.gz"}, extract: pluginRequirementContext},
+		&extensionExtractor{extensions: []string{".yaml", ".yml"}, extract: rulesfileRequirementContext},
+	}
+)
+
+var (
+	requirementNamesMu sync.RWMutex
+	// requirementNames are the names of the known requirements. This is the canonical set of names the extractors
+	// produce, any other name being most likely a bug.
+	requirementNames = map[string]bool{
+		common.EngineVersionKey: true,
+		common.PluginAPIVersion: true,
+		common.PluginAPIFeature: true,
+	}
+)
+
+// RegisterRequirementName adds a name to the ones of the known requirements, which by default are the engine version,
+// the plugin api version and the plugin api feature. Extractors registered with RegisterRequirementExtractor for new
+// kinds of requirements are expected to register their names too.
+func RegisterRequirementName(name string) {
+	requirementNamesMu.Lock()
+	defer requirementNamesMu.Unlock()
+
+	requirementNames[name] = true
+}
+
+// isKnownRequirement returns true if the given requirement name has been registered.
+func isKnownRequirement(name string) bool {
+	requirementNamesMu.RLock()
+	defer requirementNamesMu.RUnlock()
+
+	return requirementNames[name]
+}
+
+// ValidateRequirementName returns an error wrapping ErrUnknownRequirement if the given name is not the one of a known
+// requirement: common.EngineVersionKey, common.PluginAPIVersion, common.PluginAPIFeature, or one registered with
+// RegisterRequirementName.
+func ValidateRequirementName(name string) error {
+	if !isKnownRequirement(name) {
+		return fmt.Errorf("requirement %q: %w", name, ErrUnknownRequirement)
+	}
+
+	return nil
+}
+
+// WithStrictRequirementNames makes the extraction fail with a *RequirementError wrapping ErrUnknownRequirement if the
+// name of the extracted requirement is not known, see ValidateRequirementName, regardless of
+// WithAllowUnknownRequirementNames.
+// It is meant to catch a bug producing a wrong name, since the extractors only produce known names.
+func WithStrictRequirementNames() RequirementOption {
+	return func(o *requirementOptions) {
+		o.strictNames = true
+	}
+}
+
+// strictRequirementName is deferred by the functions all the extractions of plugins and rulesfiles end in, to check
+// the name of the requirement extracted from the given file when WithStrictRequirementNames is set, replacing the
+// returned error if not known.
+func strictRequirementName(filePath string, opts []RequirementOption, req **oci.ArtifactRequirement, err *error) {
+	if *err != nil || *req == nil || !newRequirementOptions(opts).strictNames {
+		return
+	}
+
+	if nameErr := ValidateRequirementName((*req).Name); nameErr != nil {
+		*req = nil
+		*err = newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for file %q: %w", filePath, nameErr))
+	}
+}
+
+// WithAllowUnknownRequirementNames makes the extraction accept the requirements whose name is not known, instead of
+// failing with an error wrapping ErrUnknownRequirement, e.g. for the ones read from the sidecars of the files, see
+// RequirementsOverrideSuffix, or returned by the registered extractors.
+func WithAllowUnknownRequirementNames() RequirementOption {
+	return func(o *requirementOptions) {
+		o.allowUnknownNames = true
+	}
+}
+
+// checkRequirementNames returns an error wrapping ErrUnknownRequirement if any of the requirements extracted from
+// the given file is not known, unless WithAllowUnknownRequirementNames is set.
+func checkRequirementNames(path string, reqs []oci.ArtifactRequirement, opts []RequirementOption) error {
+	if newRequirementOptions(opts).allowUnknownNames {
+		return nil
+	}
+
+	for _, req := range reqs {
+		if err := ValidateRequirementName(req.Name); err != nil {
+			return fmt.Errorf("file %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// RegisterRequirementExtractor registers an extractor for a new kind of file. Extractors are tried in reverse order
+// of registration, hence the ones registered later take precedence, even over the default ones for plugins and
+// rulesfiles.
+func RegisterRequirementExtractor(extractor RequirementExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	extractors = append([]RequirementExtractor{extractor}, extractors...)
+}
+
+// extractorFor returns the extractor handling the given file, nil if none of the registered ones can.
+func extractorFor(path string) RequirementExtractor {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	for _, e := range extractors {
+		if e.CanHandle(path) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// ExtractRequirements given a file bundled in an artifact it extracts its requirements with the registered extractor
+// handling it, overridden by its sidecar if any, see RequirementsOverrideSuffix. An error wrapping ErrNoExtractor is
+// returned if there is none, and one wrapping ErrUnknownRequirement if the extractor, or the sidecar, returns a
+// requirement that is not known, see RegisterRequirementName.
+// The options are passed on to the extractor, see ContextRequirementExtractor.
+func ExtractRequirements(path string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error) {
+	return fileRequirements(context.Background(), path, opts)
+}
+
+// fileRequirements extracts the requirements of the given file as extractFileRequirements does, applies the sidecar
+// overriding them, if any, and checks their names. It is shared by every extraction path, so that the requirements
+// reported for a file are always the ones published for it.
+func fileRequirements(ctx context.Context, path string, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	reqs, err := extractFileRequirements(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return overriddenRequirements(path, reqs, opts)
+}
+
+// overriddenRequirements returns the given requirements, extracted from a file, with its sidecar applied, if any,
+// after checking their names.
+func overriddenRequirements(path string, reqs []oci.ArtifactRequirement, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	reqs, err := applyRequirementsOverride(path, reqs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRequirementNames(path, reqs, opts); err != nil {
+		return nil, err
+	}
+
+	return reqs, nil
+}
+
+// extractFileRequirements extracts the requirements of the given file with the registered extractor handling it, see
+// ContextRequirementExtractor for how the context and the options are honored. An error wrapping ErrNoExtractor is
+// returned if there is none. The names of the requirements are not checked, nor the sidecar overriding them applied.
+func extractFileRequirements(ctx context.Context, path string, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	extractor := extractorFor(path)
+	if extractor == nil {
+		return nil, fmt.Errorf("requirements for file %q: %w", path, ErrNoExtractor)
+	}
+
+	if e, ok := extractor.(ContextRequirementExtractor); ok {
+		return e.ExtractContext(ctx, path, opts...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, newRequirementError(path, StageOpen, fmt.Errorf("requirements for file %q: %w", path, err))
+	}
+
+	return extractor.Extract(path)
+}
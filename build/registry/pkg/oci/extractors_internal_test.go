@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+func TestStrictRequirementName(t *testing.T) {
+	t.Parallel()
+
+	// A wrong name produced by an extractor is caught in strict mode only.
+	req := &oci.ArtifactRequirement{Name: "engine_version", Version: "0.31.0"}
+	var err error
+	strictRequirementName("assets.bundle", nil, &req, &err)
+	if err != nil || req == nil {
+		t.Fatalf("expected the requirement to be kept, got %v and %v", req, err)
+	}
+	strictRequirementName("assets.bundle", []RequirementOption{WithStrictRequirementNames()}, &req, &err)
+	var reqErr *RequirementError
+	if req != nil || !errors.As(err, &reqErr) || !errors.Is(err, ErrUnknownRequirement) {
+		t.Fatalf("expected a requirement error wrapping %v, got %v", ErrUnknownRequirement, err)
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+// bundleExtractor is a RequirementExtractor for the asset bundles, returning a requirement whose name is not known
+// until registered.
+type bundleExtractor struct{}
+
+func (bundleExtractor) CanHandle(path string) bool {
+	return filepath.Ext(path) == ".bundle"
+}
+
+func (bundleExtractor) Extract(string) ([]falcoctloci.ArtifactRequirement, error) {
+	return []falcoctloci.ArtifactRequirement{{Name: "asset_bundle_version", Version: "1.0.0"}}, nil
+}
+
+// multiBundleExtractor is a RequirementExtractor returning more than one requirement for each file.
+type multiBundleExtractor struct{}
+
+func (multiBundleExtractor) CanHandle(path string) bool {
+	return filepath.Ext(path) == ".multibundle"
+}
+
+func (multiBundleExtractor) Extract(string) ([]falcoctloci.ArtifactRequirement, error) {
+	return []falcoctloci.ArtifactRequirement{
+		{Name: "asset_bundle_version", Version: "1.0.0"},
+		{Name: common.PluginAPIFeature, Version: "1.0.0"},
+	}, nil
+}
+
+var _ = Describe("Requirement extractors", func() {
+	It("should extract the requirements with the extractor handling each file", func() {
+		rulesfile := writeRulesfile("- required_engine_version: 0.31.0\n")
+		reqs, err := oci.ExtractRequirements(rulesfile)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].Version).To(Equal("0.31.0"))
+
+		_, err = oci.ExtractRequirements(filepath.Join(GinkgoT().TempDir(), "README.md"))
+		Expect(err).To(MatchError(oci.ErrNoExtractor))
+
+		// Registered extractors are used when extracting the requirements of an artifact, as long as the names of
+		// the requirements they return are known.
+		oci.RegisterRequirementExtractor(bundleExtractor{})
+		dir := filepath.Dir(rulesfile)
+		bundle := filepath.Join(dir, "assets.bundle")
+		Expect(os.WriteFile(bundle, nil, 0o600)).To(Succeed())
+		_, err = oci.ArtifactRequirements(dir)
+		Expect(err).To(MatchError(oci.ErrUnknownRequirement))
+		reqs, err = oci.ArtifactRequirements(dir, oci.WithAllowUnknownRequirementNames())
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(2))
+		_, _, err = oci.RequirementWithDigest(bundle)
+		Expect(err).To(MatchError(oci.ErrUnknownRequirement))
+		oci.RegisterRequirementName("asset_bundle_version")
+		reqs, err = oci.ArtifactRequirements(dir)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[0].Name).To(Equal("asset_bundle_version"))
+		Expect(reqs[1].Name).To(Equal("engine_version_semver"))
+
+		// They are used by all the other extractions too.
+		walked, err := oci.WalkRequirements(dir)
+		Expect(err).To(BeNil())
+		Expect(walked["assets.bundle"].Name).To(Equal("asset_bundle_version"))
+		batched, errs := oci.BatchRequirements([]string{bundle, rulesfile}, 2)
+		Expect(errs).To(BeEmpty())
+		Expect(batched[bundle].Name).To(Equal("asset_bundle_version"))
+		Expect(batched[rulesfile].Version).To(Equal("0.31.0"))
+		req, _, err := oci.RequirementWithDigest(bundle)
+		Expect(err).To(BeNil())
+		Expect(req.Name).To(Equal("asset_bundle_version"))
+
+		// The extractions returning a single requirement fail for the files having more than one.
+		oci.RegisterRequirementExtractor(multiBundleExtractor{})
+		multi := filepath.Join(GinkgoT().TempDir(), "assets.multibundle")
+		Expect(os.WriteFile(multi, nil, 0o600)).To(Succeed())
+		_, _, err = oci.RequirementWithDigest(multi)
+		Expect(err).To(MatchError(oci.ErrMultipleRequirements))
+	})
+})
+
+var _ = Describe("Validate requirement name", func() {
+	It("should accept the known requirements only", func() {
+		for _, name := range []string{common.EngineVersionKey, common.PluginAPIVersion, common.PluginAPIFeature} {
+			Expect(oci.ValidateRequirementName(name)).To(Succeed())
+		}
+		Expect(oci.ValidateRequirementName("engine_version")).To(MatchError(oci.ErrUnknownRequirement))
+	})
+
+	It("should accept the known requirements in strict mode", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 0.31.0\n"), oci.WithStrictRequirementNames())
+		Expect(err).To(BeNil())
+	})
+})
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+// procSelfFD is the directory exposing the file descriptors of the process as paths, only available on Linux.
+var procSelfFD = "/proc/self/fd"
+
+// pluginRequirementFromFile is the same as pluginRequirement, but the plugin is read from the given open file, e.g.
+// a memfd, that may have no path on the filesystem. Where supported, the plugin is loaded from the path of its file
+// descriptor in procSelfFD, otherwise, or if a digest is expected, it is copied to a temporary file as compressed
+// plugins are, see WithPluginTempDir. The plugin is always read from its beginning, regardless of the offset of the
+// file, which is not changed. The name of the file is only used for error reporting.
+func pluginRequirementFromFile(file *os.File, opts ...RequirementOption) (req *oci.ArtifactRequirement, err error) {
+	name := file.Name()
+	defer observeRequirement(name, time.Now(), &err)
+
+	// If a digest is expected the plugin is always copied, so that the verified copy is the one being loaded.
+	o := newRequirementOptions(opts)
+	fdPath, ok := fileDescriptorPath(file)
+	if !ok || o.expectedDigest != "" {
+		dr := newDigestReader(name, io.NewSectionReader(file, 0, math.MaxInt64))
+		var r io.Reader = dr
+		if o.expectedDigest != "" {
+			r = &eofCheckReader{r: dr, check: func() error { return dr.check(o.expectedDigest) }}
+		}
+		return pluginRequirementFromReader(name, r, o.pluginTempDir, opts...)
+	}
+
+	// The plugin is not cached since the path of the file descriptor can be reused by another file once closed.
+	plugin, err := newPlugin(fdPath)
+	if err != nil {
+		return nil, newRequirementError(name, StageOpen, fmt.Errorf("unable to open plugin %q: %w: %w", name, ErrOpenFailed, err))
+	}
+	defer plugin.Unload()
+
+	return pluginInfoRequirement(name, &PluginInfo{RequiredAPIVersion: plugin.Info().RequiredAPIVersion}, opts...)
+}
+
+// fileDescriptorPath returns the path of the file descriptor of the given file in procSelfFD, false if not
+// available on this platform.
+func fileDescriptorPath(file *os.File) (string, bool) {
+	fdPath := filepath.Join(procSelfFD, strconv.FormatUint(uint64(file.Fd()), 10))
+	if _, err := os.Stat(fdPath); err != nil {
+		return "", false
+	}
+
+	return fdPath, true
+}
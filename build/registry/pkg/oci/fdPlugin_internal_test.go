@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// TestPluginRequirementFromFile is not parallel since it sets procSelfFD.
+func TestPluginRequirementFromFile(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "plugin-*.so")
+	if err != nil {
+		t.Fatalf("unable to create file: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString("not a shared library"); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	if runtime.GOOS == "linux" {
+		fdPath, ok := fileDescriptorPath(file)
+		if !ok || fdPath != filepath.Join("/proc/self/fd", strconv.Itoa(int(file.Fd()))) {
+			t.Fatalf("expected the path of the file descriptor, got %q", fdPath)
+		}
+	}
+	_, err = PluginRequirementFromFile(file)
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.FilePath != file.Name() || !errors.Is(err, ErrOpenFailed) {
+		t.Fatalf("expected an open error for %q, got %v", file.Name(), err)
+	}
+
+	// Without the file descriptors as paths, the plugin is copied from its beginning, leaving the offset as is.
+	defer func(dir string) { procSelfFD = dir }(procSelfFD)
+	procSelfFD = filepath.Join(t.TempDir(), "missing")
+	if _, ok := fileDescriptorPath(file); ok {
+		t.Fatalf("expected no path for the file descriptor")
+	}
+	_, err = pluginRequirementFromFile(file)
+	if !errors.As(err, &reqErr) || reqErr.FilePath != file.Name() || !errors.Is(err, ErrOpenFailed) {
+		t.Fatalf("expected an open error for %q, got %v", file.Name(), err)
+	}
+	if offset, err := file.Seek(0, io.SeekCurrent); err != nil || offset != int64(len("not a shared library")) {
+		t.Fatalf("expected the offset to be unchanged, got %d and %v", offset, err)
+	}
+}
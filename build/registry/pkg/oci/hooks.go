@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RequirementHook is notified after each extraction of the requirements of a plugin or a rulesfile, e.g. to collect
+// metrics or log timings. Extractions can run concurrently, see BatchRequirements, hence hooks must be safe for
+// concurrent use.
+type RequirementHook interface {
+	// RequirementExtracted is called with the file the requirement has been extracted from, the duration of the
+	// extraction and the error it returned, if any.
+	RequirementExtracted(filePath string, duration time.Duration, err error)
+}
+
+// RequirementHookFunc is an adapter to use ordinary functions as a RequirementHook.
+type RequirementHookFunc func(filePath string, duration time.Duration, err error)
+
+// RequirementExtracted implements the RequirementHook interface.
+func (f RequirementHookFunc) RequirementExtracted(filePath string, duration time.Duration, err error) {
+	f(filePath, duration, err)
+}
+
+// noopHook is the default hook, doing nothing.
+type noopHook struct{}
+
+func (noopHook) RequirementExtracted(string, time.Duration, error) {}
+
+// hookHolder wraps the hook, since atomic.Value requires all the stored values to have the same concrete type.
+type hookHolder struct {
+	hook RequirementHook
+}
+
+var requirementHook atomic.Value
+
+func init() {
+	requirementHook.Store(hookHolder{hook: noopHook{}})
+}
+
+// SetRequirementHook sets the hook notified after each extraction of requirements. A nil hook restores the default
+// one, which does nothing.
+func SetRequirementHook(hook RequirementHook) {
+	if hook == nil {
+		hook = noopHook{}
+	}
+	requirementHook.Store(hookHolder{hook: hook})
+}
+
+// observeRequirement notifies the hook of an extraction started at the given time. It is meant to be deferred by
+// the extraction functions, hence the error is given by pointer to be read once the function returned.
+func observeRequirement(filePath string, start time.Time, err *error) {
+	requirementHook.Load().(hookHolder).hook.RequirementExtracted(filePath, time.Since(start), *err)
+}
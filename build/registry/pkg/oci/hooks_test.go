@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Requirement hook", func() {
+	It("should be notified of each extraction", func() {
+		var mu sync.Mutex
+		var calls []string
+		var errs []error
+		var durations []time.Duration
+		oci.SetRequirementHook(oci.RequirementHookFunc(func(filePath string, duration time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, filePath)
+			errs = append(errs, err)
+			durations = append(durations, duration)
+		}))
+		DeferCleanup(func() { oci.SetRequirementHook(nil) })
+
+		valid := writeRulesfile("- required_engine_version: 0.31.0\n")
+		missing := writeRulesfile("- rule: open\n  condition: evt.type = open\n")
+		_, err := oci.RulesfileRequirement(valid)
+		Expect(err).To(BeNil())
+		_, err = oci.RulesfileRequirement(missing)
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		// The extractions of a batch are reported too.
+		_, batchErrs := oci.BatchRequirements([]string{valid}, 1)
+		Expect(batchErrs).To(BeEmpty())
+
+		Expect(calls).To(Equal([]string{valid, missing, valid}))
+		Expect(errs[0]).To(BeNil())
+		Expect(errs[1]).To(MatchError(oci.ErrReqNotFound))
+		Expect(errs[2]).To(BeNil())
+		for _, d := range durations {
+			Expect(d).To(BeNumerically(">=", 0))
+		}
+	})
+})
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LintFinding is an engine requirement declared by a rulesfile in a legacy form, such as a bare number, that is
+// still supported but discouraged.
+type LintFinding struct {
+	File string
+	Line int
+	// Key is the key the version is declared with, see WithRulesEngineKey.
+	Key string
+	// Declared is the version as written in the rulesfile.
+	Declared string
+	// Suggested is the full semver string the declared version is coerced to, to be used instead.
+	Suggested string
+}
+
+// LintRulesfiles given some rulesfiles it returns the engine requirements they declare as bare numbers, which are
+// coerced to semver, e.g. "10" to "0.10.0". New rulesfiles are expected to declare the full semver string instead.
+// The rulesfiles that do not declare the engine requirement are skipped, any other error aborts the lint. The
+// requirements are extracted with the given options.
+func LintRulesfiles(files []string, opts ...RequirementOption) ([]LintFinding, error) {
+	key := newRequirementOptions(opts).engineKey
+	var findings []LintFinding
+	for _, file := range files {
+		requirements, err := rulesfileRequirements(file, opts...)
+		if errors.Is(err, ErrReqNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range requirements {
+			if !isBareEngineRequirement(req.Declared) {
+				continue
+			}
+			findings = append(findings, LintFinding{
+				File:      file,
+				Line:      req.Line,
+				Key:       key,
+				Declared:  req.Declared,
+				Suggested: req.Version,
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// PrintLintFindings writes a warning line for each finding, suggesting the full semver string to be used.
+func PrintLintFindings(findings []LintFinding, output io.Writer) error {
+	for _, f := range findings {
+		key := f.Key
+		if key == "" {
+			key = RulesEngineKey
+		}
+		if _, err := fmt.Fprintf(output, "warning: %s:%d: %s %q is not a full semver string, use %q instead\n",
+			f.File, f.Line, key, f.Declared, f.Suggested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
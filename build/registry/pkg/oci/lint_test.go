@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Lint rulesfiles", func() {
+	It("should report the bare engine versions with their suggested replacement", func() {
+		bare := writeRulesfile("- required_engine_version: 10\n- rule: open\n- required_engine_version: \"0.31.0\"\n- required_engine_version: '12'\n")
+		files := []string{
+			bare,
+			writeRulesfile("- required_engine_version: 0.31.0\n"),
+			writeRulesfile("- required_engine_version: \">=0.31.0\"\n"),
+			writeRulesfile("- rule: open\n"),
+			// Short versions are not bare numbers.
+			writeRulesfile("- required_engine_version: 0.31\n- required_engine_version: '0.32'\n"),
+		}
+
+		findings, err := oci.LintRulesfiles(files)
+		Expect(err).To(BeNil())
+		Expect(findings).To(Equal([]oci.LintFinding{
+			{File: bare, Line: 1, Key: oci.RulesEngineKey, Declared: "10", Suggested: "0.10.0"},
+			{File: bare, Line: 4, Key: oci.RulesEngineKey, Declared: "12", Suggested: "0.12.0"},
+		}))
+
+		var buf bytes.Buffer
+		Expect(oci.PrintLintFindings(findings, &buf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring(bare + `:1: required_engine_version "10" is not a full semver string, use "0.10.0" instead`))
+	})
+})
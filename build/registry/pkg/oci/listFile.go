@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+// ListFileRequirements given a list file, such as a release manifest, naming a rulesfile per line, it extracts the
+// requirements of each rulesfile and returns them keyed by the path as listed. Blank lines are ignored, and so is
+// anything following a "#". Relative paths are resolved against the directory of the list file, and may be
+// slash-separated on every platform. The options are
+// applied to each rulesfile, see RulesfileRequirement.
+func ListFileRequirements(listFile string, opts ...RequirementOption) (map[string]oci.ArtifactRequirement, error) {
+	file, err := os.Open(listFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open list file %q: %w", listFile, err)
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(listFile)
+	reqs := make(map[string]oci.ArtifactRequirement)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := scanner.Text()
+		if i := strings.IndexByte(entry, '#'); i >= 0 {
+			entry = entry[:i]
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		filePath := filepath.FromSlash(entry)
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(dir, filePath)
+		}
+
+		req, err := rulesfileRequirement(filePath, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("list file %q, line %d: %w", listFile, line, err)
+		}
+		reqs[entry] = *req
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read list file %q: %w", listFile, err)
+	}
+
+	return reqs, nil
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("List file requirements", func() {
+	var dir, relative, listFile string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		relative = filepath.Join("rules", "network.yaml")
+		writeFiles(dir, map[string]string{"rules/network.yaml": "- required_engine_version: 0.31.0\n"})
+		listFile = filepath.Join(dir, "release.txt")
+	})
+
+	It("should extract the requirements of the listed files", func() {
+		absolute := writeRulesfile("- required_engine_version: 0.26.0\n")
+		content := "# Rulesfiles of the release.\n\n" + relative + "\n  " + absolute + " # shared rules\n"
+		Expect(os.WriteFile(listFile, []byte(content), 0o600)).To(Succeed())
+
+		reqs, err := oci.ListFileRequirements(listFile)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[relative].Version).To(Equal("0.31.0"))
+		Expect(reqs[absolute].Version).To(Equal("0.26.0"))
+	})
+
+	It("should report the line of the list file on failure", func() {
+		Expect(os.WriteFile(listFile, []byte(relative+"\nmissing.yaml\n"), 0o600)).To(Succeed())
+
+		_, err := oci.ListFileRequirements(listFile)
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		Expect(err.Error()).To(ContainSubstring("line 2"))
+	})
+})
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import "log/slog"
+
+// Logger is the default logger of the requirements extraction and of the publication of the artifacts, used unless
+// another one is set with WithLogger. Messages carry structured attributes, such as "file", "artifact", "ref" and
+// "digest", so that they can be filtered, and the verbose ones are logged at the debug level. The default logger of
+// the slog package is used if nil, hence the logs can be redirected, filtered by level or emitted as json either here
+// or with slog.SetDefault.
+var Logger *slog.Logger
+
+// WithLogger sets the logger of a single extraction, instead of the package one, see Logger, so that concurrent
+// callers can log independently. The other operations take it with WithBatchRequirementOptions,
+// WithPushRequirementOptions and the like.
+func WithLogger(l *slog.Logger) RequirementOption {
+	return func(o *requirementOptions) {
+		o.logger = l
+	}
+}
+
+// logger returns the default logger, see Logger.
+func logger() *slog.Logger {
+	if Logger != nil {
+		return Logger
+	}
+
+	return slog.Default()
+}
+
+// log returns the logger set with WithLogger, the default one if none.
+func (o *requirementOptions) log() *slog.Logger {
+	if o.logger != nil {
+		return o.logger
+	}
+
+	return logger()
+}
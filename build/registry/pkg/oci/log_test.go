@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Logger", func() {
+	It("should log the skipped rulesfiles with their file and error", func() {
+		var buf bytes.Buffer
+		log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		missing := writeRulesfile("- rule: first\n")
+		_, err := oci.PackEngineRequirement([]string{missing, writeRulesfile("- required_engine_version: 10\n")}, oci.WithSkipMissing(),
+			oci.WithPackRequirementOptions(oci.WithLogger(log)))
+		Expect(err).To(BeNil())
+
+		var found bool
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &record)).To(Succeed())
+			if record["msg"] == "skipping rulesfile without requirements" {
+				found = true
+				Expect(record).To(HaveKeyWithValue("level", "INFO"))
+				Expect(record).To(HaveKeyWithValue("file", missing))
+				Expect(record).To(HaveKey("error"))
+			}
+		}
+		Expect(found).To(BeTrue(), "expected the skipped rulesfile to be logged, got %q", buf.String())
+	})
+})
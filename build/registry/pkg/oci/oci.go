@@ -19,9 +19,12 @@ package oci
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -35,10 +38,7 @@ import (
 	"github.com/falcosecurity/falcoctl/pkg/oci"
 	"github.com/falcosecurity/falcoctl/pkg/oci/authn"
 	ocipusher "github.com/falcosecurity/falcoctl/pkg/oci/pusher"
-	"github.com/falcosecurity/falcoctl/pkg/oci/repository"
 	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
-	"k8s.io/klog/v2"
-	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
@@ -55,6 +55,10 @@ type config struct {
 	registryHost string
 	// pluginsRepo the Ref of the git repository associated with the OCI artifacts.
 	pluginsRepo string
+	// push options used when pushing the OCI artifacts.
+	push *pushOptions
+	// signingKey the pushed OCI artifacts are signed with, nil if they are not signed.
+	signingKey *ecdsa.PrivateKey
 }
 
 func lookupConfig() (*config, error) {
@@ -91,8 +95,8 @@ func refFromPluginEntry(cfg *config, plugin *registry.Plugin, rulesFile bool) st
 		namespace = PluginNamespace
 	}
 
-	// Build and return the artifact reference.
-	return filepath.Join(cfg.registryHost, cfg.registryUser, namespace, plugin.Name)
+	// Build and return the artifact reference, which is slash-separated whatever the platform.
+	return path.Join(cfg.registryHost, cfg.registryUser, namespace, plugin.Name)
 }
 
 // s3ArtifactName returns the prefix name of the archive uploaded in the s3 bucket.
@@ -124,21 +128,20 @@ func currentPlatform() string {
 }
 
 // latestVersionArtifact returns the latest version of the artifact that exists in the remote repository pointed by the reference.
-func latestVersionArtifact(ctx context.Context, ref string, ociClient remote.Client) (string, error) {
+func latestVersionArtifact(ctx context.Context, log *slog.Logger, ref string, ociRegistry Registry) (string, error) {
 	var versions []semver.Version
-	var repo *repository.Repository
-	var err error
 
 	// Create the repository object for the ref.
-	if repo, err = repository.NewRepository(ref, repository.WithClient(ociClient)); err != nil {
-		return "", fmt.Errorf("unable to create repository for ref %q: %w", ref, err)
+	repo, err := ociRegistry.Repository(ref)
+	if err != nil {
+		return "", err
 	}
 
 	// Get all the tags for the given artifact in the remote repository.
 	remoteTags, err := repo.Tags(ctx)
 	// Only way to know if the repo does not exist is to check the content of the error.
 	if err != nil && !strings.Contains(err.Error(), "unexpected status code 404") {
-		klog.Warningf("unable to get latest version from remote repository for %q: %v", ref, err)
+		log.Warn("unable to get latest version from remote repository", "ref", ref, "error", err)
 		return "", nil
 	}
 
@@ -153,19 +156,19 @@ func latestVersionArtifact(ctx context.Context, ref string, ociClient remote.Cli
 		parsedVersion, err := semver.ParseTolerant(tag)
 		if err != nil {
 			// Ignore any non-semver tags (like latest or signature tags)
-			klog.Infof("Tag %s is not semver, ignoring", tag)
+			log.Info("ignoring tag not in semver format", "ref", ref, "tag", tag)
 			continue
 		}
 
 		versions = append(versions, parsedVersion)
 	}
 
-	klog.Infof("remote versions before sorting: %q", versions)
+	log.Debug("remote versions before sorting", "ref", ref, "versions", versions)
 
 	// Sort the versions.
 	semver.Sort(versions)
 
-	klog.Infof("remote versions after sorting: %q", versions)
+	log.Debug("remote versions after sorting", "ref", ref, "versions", versions)
 
 	// Return the latest version.
 	// It should never happen that versions is empty. Since the artifacts are pushed by the CI if
@@ -214,7 +217,7 @@ func localLatestVersion(artifactName string) (*semver.Version, error) {
 // newReleases returns the new released versions since the latest version fetched from the remote repository.
 // It could happen that the artifact does not exist in the remote repository, in that case we return the latest
 // version found in the local git repository.
-func newReleases(artifactName, remoteVersion string) ([]semver.Version, error) {
+func newReleases(log *slog.Logger, artifactName, remoteVersion string) ([]semver.Version, error) {
 	var versions []semver.Version
 
 	// List only the tags that have a prefix "artifactname-[0-9].*"
@@ -236,7 +239,7 @@ func newReleases(artifactName, remoteVersion string) ([]semver.Version, error) {
 		return nil, err
 	}
 
-	klog.Infof("newReleases tagList: %q", tagList)
+	log.Debug("git tags of new releases", "artifact", artifactName, "tags", tagList)
 
 	tags := make(map[string]struct{})
 
@@ -268,7 +271,7 @@ func newReleases(artifactName, remoteVersion string) ([]semver.Version, error) {
 		versions = append(versions, parsedVersion)
 	}
 
-	klog.Infof("versions before sorting: %q", versions)
+	log.Debug("new versions before sorting", "artifact", artifactName, "versions", versions)
 	// Sort and return the versions.
 	semver.Sort(versions)
 	return versions, nil
@@ -278,8 +281,8 @@ func newReleases(artifactName, remoteVersion string) ([]semver.Version, error) {
 // For each plugin in the registry index, it looks for new versions, since the latest version fetched from the remote OCI
 // repository, as tags on the local Git repository.
 // For each new version, it downloads the related plugin and rule set from the Falco distribution and updates the OCI
-// repository accordingly.
-func DoUpdateOCIRegistry(ctx context.Context, registryFile string) ([]registry.ArtifactPushMetadata, error) {
+// repository accordingly. Pushes failing with transient errors are retried, see PushOption.
+func DoUpdateOCIRegistry(ctx context.Context, registryFile string, opts ...PushOption) ([]registry.ArtifactPushMetadata, error) {
 	var (
 		cfg *config
 		err error
@@ -289,6 +292,12 @@ func DoUpdateOCIRegistry(ctx context.Context, registryFile string) ([]registry.A
 	if cfg, err = lookupConfig(); err != nil {
 		return nil, err
 	}
+	cfg.push = newPushOptions(opts)
+	if cfg.push.signingKey != "" {
+		if cfg.signingKey, err = loadSigningKey(cfg.push.signingKey); err != nil {
+			return nil, err
+		}
+	}
 
 	s3Client := s3.NewFromConfig(aws.Config{
 		Region:      region,
@@ -300,7 +309,15 @@ func DoUpdateOCIRegistry(ctx context.Context, registryFile string) ([]registry.A
 		Password: cfg.registryToken,
 	}
 
-	ociClient := authn.NewClient(authn.WithCredentials(cred))
+	ociRegistry := cfg.push.registry
+	if ociRegistry == nil {
+		ociRegistry = NewRegistry(authn.NewClient(authn.WithCredentials(cred)), false)
+	}
+
+	// Reject duplicate entries before pushing any artifact.
+	if err := registry.CheckDuplicatePluginNames(registryFile); err != nil {
+		return nil, err
+	}
 
 	reg, err := registry.LoadRegistryFromFile(registryFile)
 	if err != nil {
@@ -311,7 +328,7 @@ func DoUpdateOCIRegistry(ctx context.Context, registryFile string) ([]registry.A
 
 	// For each plugin in the registry index, look for new ones to be released, and publish them.
 	for _, plugin := range reg.Plugins {
-		pa, ra, err := handleArtifact(ctx, cfg, &plugin, s3Client, ociClient)
+		pa, ra, err := handleArtifact(ctx, cfg, &plugin, s3Client, ociRegistry)
 		if err != nil {
 			return artifacts, err
 		}
@@ -328,14 +345,14 @@ func DoUpdateOCIRegistry(ctx context.Context, registryFile string) ([]registry.A
 	return artifacts, nil
 }
 
-func listObjects(ctx context.Context, client *s3.Client, prefix string) ([]string, error) {
-	prefix = filepath.Join(pluginPrefix, prefix)
+func listObjects(ctx context.Context, log *slog.Logger, client *s3.Client, prefix string) ([]string, error) {
+	prefix = path.Join(pluginPrefix, prefix)
 	params := &s3.ListObjectsV2Input{
 		Bucket: &bucketName,
 		Prefix: &prefix,
 	}
 
-	klog.Infof("listing objects for plugin from s3 bucket with prefix %q", prefix)
+	log.Info("listing objects from s3 bucket", "prefix", prefix)
 
 	// Create the Paginator for the ListObjectsV2 operation.
 	p := s3.NewListObjectsV2Paginator(client, params, func(o *s3.ListObjectsV2PaginatorOptions) {
@@ -364,13 +381,13 @@ func listObjects(ctx context.Context, client *s3.Client, prefix string) ([]strin
 		}
 	}
 
-	klog.V(5).Infof("objects found for prefix %q: %s", prefix, keys)
+	log.Debug("objects found in s3 bucket", "prefix", prefix, "keys", keys)
 	return keys, nil
 }
 
 func downloadToFile(downloader *manager.Downloader, targetDirectory, bucket, key string) error {
 	// Create the directories in the path
-	file := filepath.Join(targetDirectory, key)
+	file := filepath.Join(targetDirectory, filepath.FromSlash(key))
 	if err := os.MkdirAll(filepath.Dir(file), 0775); err != nil {
 		return err
 	}
@@ -388,6 +405,7 @@ func downloadToFile(downloader *manager.Downloader, targetDirectory, bucket, key
 	return err
 }
 
+// tagsFromVersion returns the tags a release with the given version is pushed with, see ReleaseTags.
 func tagsFromVersion(version *semver.Version) []string {
 	var tags []string
 
@@ -410,18 +428,15 @@ func tagsFromVersion(version *semver.Version) []string {
 // For each new release version, it pushes the plugin and rule set, downloading the content from the official Falco
 // distribution.
 func handleArtifact(ctx context.Context, cfg *config, plugin *registry.Plugin,
-	s3Client *s3.Client, ociClient remote.Client) ([]registry.ArtifactPushMetadata, []registry.ArtifactPushMetadata, error) {
+	s3Client *s3.Client, ociRegistry Registry) ([]registry.ArtifactPushMetadata, []registry.ArtifactPushMetadata, error) {
 	// Filter out plugins that are not owned by falcosecurity.
 	if plugin.Authors != falcoAuthors {
-		sepString := strings.Repeat("#", 15)
-		klog.Infof("%s %s %s", sepString, plugin.Name, sepString)
-		klog.Infof("skipping plugin %q with authors %q: it is not maintained by %q",
-			plugin.Name, plugin.Authors, falcoAuthors)
+		cfg.push.log().Info("skipping plugin not maintained by "+falcoAuthors, "artifact", plugin.Name, "authors", plugin.Authors)
 		return nil, nil, nil
 	}
 
 	// Handle the plugin.
-	newPluginArtifacts, err := handlePlugin(ctx, cfg, plugin, s3Client, ociClient)
+	newPluginArtifacts, err := handlePlugin(ctx, cfg, plugin, s3Client, ociRegistry)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -430,7 +445,7 @@ func handleArtifact(ctx context.Context, cfg *config, plugin *registry.Plugin,
 	newRuleArtifacts := []registry.ArtifactPushMetadata{}
 
 	if plugin.RulesURL != "" {
-		newRuleArtifacts, err = handleRule(ctx, cfg, plugin, s3Client, ociClient)
+		newRuleArtifacts, err = handleRule(ctx, cfg, plugin, s3Client, ociRegistry)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -444,39 +459,37 @@ func handleArtifact(ctx context.Context, cfg *config, plugin *registry.Plugin,
 // For each new release version, it pushes the plugin with as tag the new release version, and as content the one
 // downloaded from the official Falco distribution.
 func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
-	s3Client *s3.Client, ociClient remote.Client) ([]registry.ArtifactPushMetadata, error) {
+	s3Client *s3.Client, ociRegistry Registry) ([]registry.ArtifactPushMetadata, error) {
 	var s3Keys []string
-	var configLayer *oci.ArtifactConfig
 	var err error
 
-	sepString := strings.Repeat("#", 15)
-	klog.Infof("%s %s %s", sepString, plugin.Name, sepString)
+	cfg.push.log().Info("handling plugin", "artifact", plugin.Name)
 
 	ref := refFromPluginEntry(cfg, plugin, false)
 	// Get all the tags for the given artifact in the remote repository.
-	remoteVersion, err := latestVersionArtifact(ctx, ref, ociClient)
+	remoteVersion, err := latestVersionArtifact(ctx, cfg.push.log(), ref, ociRegistry)
 	if err != nil {
 		return nil, err
 	}
 
 	if remoteVersion != "" {
-		klog.Infof("latest version found in the OCI registry is: %q", remoteVersion)
+		cfg.push.log().Info("latest version found in the OCI registry", "ref", ref, "version", remoteVersion)
 	} else {
-		klog.Info("no versions found in the OCI registry")
+		cfg.push.log().Info("no versions found in the OCI registry", "ref", ref)
 	}
 
 	// New releases to be published.
-	releases, err := newReleases(plugin.Name, remoteVersion)
+	releases, err := newReleases(cfg.push.log(), plugin.Name, remoteVersion)
 	if err != nil {
 		return nil, err
 	}
 
 	// If there are no new releases then return.
 	if len(releases) == 0 {
-		klog.Info("no new releases found in the local git repo. Nothing to be done")
+		cfg.push.log().Info("no new releases found in the local git repo, nothing to be done", "artifact", plugin.Name)
 		return nil, nil
 	} else {
-		klog.Infof("new releases found in local git repo: %q", releases)
+		cfg.push.log().Info("new releases found in the local git repo", "artifact", plugin.Name, "versions", releases)
 	}
 
 	// Create s3 downloader.
@@ -489,14 +502,14 @@ func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
 	for _, v := range releases {
 		prefixKey := s3ArtifactNamePrefix(plugin, v.String(), false)
 		// Get the s3 keys.
-		if s3Keys, err = listObjects(ctx, s3Client, prefixKey); err != nil {
+		if s3Keys, err = listObjects(ctx, cfg.push.log(), s3Client, prefixKey); err != nil {
 			return nil, fmt.Errorf("an error occurred while listing objects for prefix %q: %v", prefixKey, err)
 		}
 
 		// It could happen if we tagged a new version in the git repo but the CI has not processed it.
 		// It means that no binaries have been produced and uploaded in the s3 bucket.
 		if len(s3Keys) == 0 {
-			klog.Warningf("no archives found on s3 bucket for prefix %q", prefixKey)
+			cfg.push.log().Warn("no archives found in s3 bucket", "artifact", plugin.Name, "prefix", prefixKey)
 			continue
 		}
 
@@ -504,7 +517,7 @@ func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
 
 		// Download the tarballs for each key.
 		for _, key := range s3Keys {
-			klog.Infof("downloading tarball with key %q", key)
+			cfg.push.log().Info("downloading tarball", "artifact", plugin.Name, "key", key)
 			if err := downloadToFile(downloader, plugin.Name, bucketName, key); err != nil {
 				return nil, fmt.Errorf("an error occurred while downloading tarball %q from bucket %q: %w",
 					key, bucketName, err)
@@ -514,18 +527,17 @@ func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
 			platforms = append(platforms, platformFromS3Key(key))
 		}
 
-		tags := tagsFromVersion(&v)
-
-		klog.Infof("generating plugin's config layer")
+		cfg.push.log().Info("generating config layer", "artifact", plugin.Name, "version", v.String())
 
 		// current platform where the CI is running.
 		platform := currentPlatform()
+		var release *ArtifactRelease
 		for i, p := range platforms {
 			// We need to get the plugin that have been built for the same platform as the one where we are loading it.
 			if p == platform {
-				configLayer, err = pluginConfig(plugin.Name, v.String(), filepaths[i])
+				release, err = PrepareRelease(plugin, &v, filepaths[i], false, cfg.push.reqOpts...)
 				if err != nil {
-					klog.Errorf("unable to generate config file: %v", err)
+					cfg.push.log().Error("unable to generate config file", "artifact", plugin.Name, "version", v.String(), "error", err)
 					return nil, err
 				}
 				break
@@ -533,22 +545,28 @@ func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
 			continue
 		}
 
-		if configLayer == nil {
-			klog.Warningf("no config layer generated for plugin %q: the plugins has not been build for the current platform %q", plugin.Name, platform)
+		if release == nil {
+			cfg.push.log().Warn("no config layer generated: the plugin has not been built for the current platform", "artifact", plugin.Name, "version", v.String(), "platform", platform)
 			return nil, nil
 		}
-
-		klog.Infof("pushing plugin to remote repo with ref %q and tags %q", ref, tags)
-		pusher := ocipusher.NewPusher(ociClient, false, nil)
-		res, err := pusher.Push(context.Background(), oci.Plugin, ref,
-			ocipusher.WithTags(tags...),
-			ocipusher.WithFilepathsAndPlatforms(filepaths, platforms),
-			ocipusher.WithArtifactConfig(*configLayer),
-			ocipusher.WithAnnotationSource(cfg.pluginsRepo))
+		configLayer, tags := release.Config, release.Tags
+
+		cfg.push.log().Info("pushing plugin", "artifact", plugin.Name, "ref", ref, "tags", tags)
+		pusher := ociRegistry.Pusher()
+		res, err := retryPush(ctx, cfg.push, ref, func(ctx context.Context) (*oci.RegistryResult, error) {
+			return pusher.Push(ctx, oci.Plugin, ref,
+				ocipusher.WithTags(tags...),
+				ocipusher.WithFilepathsAndPlatforms(filepaths, platforms),
+				ocipusher.WithArtifactConfig(*configLayer),
+				ocipusher.WithAnnotationSource(cfg.pluginsRepo))
+		})
 		if err != nil {
 			return nil, fmt.Errorf("an error occurred while pushing plugin %q: %w", plugin.Name, err)
 		}
 		if res != nil {
+			if err := signPushed(ctx, cfg, ociRegistry, ref, res.Digest); err != nil {
+				return nil, fmt.Errorf("an error occurred while signing plugin %q: %w", plugin.Name, err)
+			}
 			metadata = append(metadata, registry.ArtifactPushMetadata{
 				registry.RepositoryMetadata{
 					Ref: ref,
@@ -569,38 +587,37 @@ func handlePlugin(ctx context.Context, cfg *config, plugin *registry.Plugin,
 // For each new release version, it pushes the rule set with as tag the new release version, and as content the one
 // downloaded from the official Falco distribution.
 func handleRule(ctx context.Context, cfg *config, plugin *registry.Plugin,
-	s3Client *s3.Client, ociClient remote.Client) ([]registry.ArtifactPushMetadata, error) {
+	s3Client *s3.Client, ociRegistry Registry) ([]registry.ArtifactPushMetadata, error) {
 	var s3Keys []string
 	var err error
 
-	sepString := strings.Repeat("#", 15)
-	klog.Infof("%s %s %s", sepString, rulesfileNameFromPlugin(plugin.Name), sepString)
+	cfg.push.log().Info("handling rulesfile", "artifact", rulesfileNameFromPlugin(plugin.Name))
 
 	ref := refFromPluginEntry(cfg, plugin, true)
 	// Get all the tags for the given artifact in the remote repository.
-	remoteVersion, err := latestVersionArtifact(ctx, ref, ociClient)
+	remoteVersion, err := latestVersionArtifact(ctx, cfg.push.log(), ref, ociRegistry)
 	if err != nil {
 		return nil, err
 	}
 
 	if remoteVersion != "" {
-		klog.Infof("latest version found in the OCI registry is: %q", remoteVersion)
+		cfg.push.log().Info("latest version found in the OCI registry", "ref", ref, "version", remoteVersion)
 	} else {
-		klog.Info("no versions found in the OCI registry")
+		cfg.push.log().Info("no versions found in the OCI registry", "ref", ref)
 	}
 
 	// New releases to be published.
-	releases, err := newReleases(plugin.Name, remoteVersion)
+	releases, err := newReleases(cfg.push.log(), plugin.Name, remoteVersion)
 	if err != nil {
 		return nil, err
 	}
 
 	// If there are no new releases then return.
 	if len(releases) == 0 {
-		klog.Info("no new releases found in the local git repo. Nothing to be done")
+		cfg.push.log().Info("no new releases found in the local git repo, nothing to be done", "artifact", plugin.Name)
 		return nil, nil
 	} else {
-		klog.Infof("new releases found in local git repo: %q", releases)
+		cfg.push.log().Info("new releases found in the local git repo", "artifact", plugin.Name, "versions", releases)
 	}
 
 	// Create s3 downloader.
@@ -609,59 +626,81 @@ func handleRule(ctx context.Context, cfg *config, plugin *registry.Plugin,
 	// Metadata of the rules OCI artifacts push.
 	metadata := []registry.ArtifactPushMetadata{}
 
+	// Requirements of the previous release, to check that the engine version does not decrease.
+	var previousReqs []oci.ArtifactRequirement
+	if remoteVersion != "" {
+		if previousReqs, err = previousRulesfileRequirements(ctx, cfg.push, ociRegistry, plugin, ref, remoteVersion); err != nil {
+			return nil, err
+		}
+	}
+
 	// For each new version we download the archives from s3 bucket
 	for _, v := range releases {
 		prefixKey := s3ArtifactNamePrefix(plugin, v.String(), true)
 		// Get the s3 keys.
-		if s3Keys, err = listObjects(ctx, s3Client, prefixKey); err != nil {
+		if s3Keys, err = listObjects(ctx, cfg.push.log(), s3Client, prefixKey); err != nil {
 			return nil, fmt.Errorf("an error occurred while listing objects for prefix %q: %v", prefixKey, err)
 		}
 
 		// It could happen if we tagged a new version in the git repo but the CI has not processed it.
 		// It means that no binaries have been produced and uploaded in the s3 bucket.
 		if len(s3Keys) == 0 {
-			klog.Warningf("no archives found on s3 bucket for prefix %q", prefixKey)
+			cfg.push.log().Warn("no archives found in s3 bucket", "artifact", plugin.Name, "prefix", prefixKey)
 			continue
 		}
 
 		// For a given release of a rulesfile there should be only one archive in the s3 bucket.
 		if len(s3Keys) > 1 {
 			err := fmt.Errorf("multiple archives found for rulesfiles with prefix %q: %s", prefixKey, s3Keys)
-			klog.Error(err)
+			cfg.push.log().Error("multiple archives found in s3 bucket", "artifact", plugin.Name, "prefix", prefixKey, "keys", s3Keys)
 			return nil, err
 		}
 
 		var filepaths []string
 
 		key := s3Keys[0]
-		klog.Infof("downloading tarball with key %q", key)
+		cfg.push.log().Info("downloading tarball", "artifact", plugin.Name, "key", key)
 		if err := downloadToFile(downloader, plugin.Name, bucketName, key); err != nil {
 			return nil, fmt.Errorf("an error occurred while downloading tarball %q from bucket %q: %w",
 				key, bucketName, err)
 		}
 		filepaths = append(filepaths, filepath.Join(plugin.Name, key))
 
-		tags := tagsFromVersion(&v)
+		cfg.push.log().Info("generating config layer", "artifact", plugin.Name, "version", v.String())
 
-		klog.Infof("generating rulesfile's config layer")
-
-		configLayer, err := rulesfileConfig(rulesfileNameFromPlugin(plugin.Name), v.String(), filepaths[0])
+		release, err := PrepareRelease(plugin, &v, filepaths[0], true, cfg.push.reqOpts...)
 		if err != nil {
-			klog.Errorf("unable to generate config file: %v", err)
+			cfg.push.log().Error("unable to generate config file", "artifact", plugin.Name, "version", v.String(), "error", err)
 			return nil, err
 		}
-		klog.Infof("pushing rulesfile to remote repo with ref %q and tags %q", ref, tags)
-		pusher := ocipusher.NewPusher(ociClient, false, nil)
-		res, err := pusher.Push(context.Background(), oci.Rulesfile, ref,
-			ocipusher.WithTags(tags...),
-			ocipusher.WithFilepaths(filepaths),
-			ocipusher.WithArtifactConfig(*configLayer),
-			ocipusher.WithAnnotationSource(cfg.pluginsRepo))
+		configLayer, tags := release.Config, release.Tags
+
+		if err := CheckEngineRequirementMonotonic(previousReqs, configLayer.Requirements); err != nil {
+			if cfg.push.failOnEngineDowngrade {
+				return nil, fmt.Errorf("rulesfile %q version %q: %w", plugin.Name, v.String(), err)
+			}
+			cfg.push.log().Warn("engine version requirement decreased", "artifact", plugin.Name, "version", v.String(), "error", err)
+			cfg.push.warn(rulesfileNameFromPlugin(plugin.Name), fmt.Errorf("rulesfile %q version %q: %w", plugin.Name, v.String(), err))
+		}
+		previousReqs = configLayer.Requirements
+
+		cfg.push.log().Info("pushing rulesfile", "artifact", plugin.Name, "ref", ref, "tags", tags)
+		pusher := ociRegistry.Pusher()
+		res, err := retryPush(ctx, cfg.push, ref, func(ctx context.Context) (*oci.RegistryResult, error) {
+			return pusher.Push(ctx, oci.Rulesfile, ref,
+				ocipusher.WithTags(tags...),
+				ocipusher.WithFilepaths(filepaths),
+				ocipusher.WithArtifactConfig(*configLayer),
+				ocipusher.WithAnnotationSource(cfg.pluginsRepo))
+		})
 
 		if err != nil {
 			return nil, fmt.Errorf("an error occurred while pushing rulesfile %q: %w", plugin.Name, err)
 		}
 		if res != nil {
+			if err := signPushed(ctx, cfg, ociRegistry, ref, res.Digest); err != nil {
+				return nil, fmt.Errorf("an error occurred while signing rulesfile %q: %w", plugin.Name, err)
+			}
 			metadata = append(metadata, registry.ArtifactPushMetadata{
 				registry.RepositoryMetadata{
 					Ref: ref,
@@ -677,6 +716,53 @@ func handleRule(ctx context.Context, cfg *config, plugin *registry.Plugin,
 	return metadata, nil
 }
 
+// signPushed signs the artifact pushed to the repository of the given reference with the given digest, if a signing
+// key has been configured.
+func signPushed(ctx context.Context, cfg *config, ociRegistry Registry, ref, digest string) error {
+	if cfg.signingKey == nil {
+		return nil
+	}
+
+	repo, err := ociRegistry.Repository(ref)
+	if err != nil {
+		return err
+	}
+
+	cfg.push.log().Info("signing artifact", "ref", ref, "digest", digest)
+	return signArtifact(ctx, repo, ref+"@"+digest, cfg.signingKey)
+}
+
+// publishedRequirements returns the requirements in the config of the artifact with the given reference and version
+// published in the remote repository.
+func publishedRequirements(ctx context.Context, ociRegistry Registry, ref, version string) ([]oci.ArtifactRequirement, error) {
+	repo, err := ociRegistry.Repository(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifactConfigRequirements(ctx, repo, ref+":"+version)
+}
+
+// previousRulesfileRequirements returns the requirements of the rulesfile published as the given version, the engine
+// version required by the new releases is checked against. If they can not be retrieved, an error is returned when
+// the check is required to pass, see WithFailOnEngineDowngrade, otherwise a warning is raised and the check skipped.
+func previousRulesfileRequirements(ctx context.Context, push *pushOptions, ociRegistry Registry, plugin *registry.Plugin,
+	ref, version string) ([]oci.ArtifactRequirement, error) {
+	reqs, err := publishedRequirements(ctx, ociRegistry, ref, version)
+	if err == nil {
+		return reqs, nil
+	}
+
+	err = fmt.Errorf("unable to get the requirements of rulesfile %q version %q published as %q: %w", plugin.Name, version, ref, err)
+	if push.failOnEngineDowngrade {
+		return nil, err
+	}
+	push.log().Warn("unable to check the engine version against the published one", "ref", ref, "version", version, "error", err)
+	push.warn(rulesfileNameFromPlugin(plugin.Name), err)
+
+	return nil, nil
+}
+
 func rulesfileNameFromPlugin(name string) string {
 	return fmt.Sprintf("%s%s", name, common.RulesArtifactSuffix)
 }
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci/repository"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// unreachableRegistry is a Registry whose repositories can not be reached.
+type unreachableRegistry struct{}
+
+func (unreachableRegistry) Repository(ref string) (*repository.Repository, error) {
+	return nil, fmt.Errorf("repository %q unreachable", ref)
+}
+
+func (unreachableRegistry) Pusher() ArtifactPusher {
+	return nil
+}
+
+func TestPreviousRulesfileRequirements(t *testing.T) {
+	t.Parallel()
+
+	collector := &WarningCollector{}
+	push := newPushOptions([]PushOption{WithPushRequirementOptions(WithWarningCollector(collector))})
+
+	plugin := &registry.Plugin{Name: "k8saudit"}
+	ref := "ghcr.io/falcosecurity/rules/k8saudit-rules"
+
+	// The check is skipped with a warning, unless it is required to pass.
+	reqs, err := previousRulesfileRequirements(context.Background(), push, unreachableRegistry{}, plugin, ref, "0.7.0")
+	if err != nil || reqs != nil {
+		t.Fatalf("expected no requirements and no error, got %v and %v", reqs, err)
+	}
+	if warnings := collector.Warnings(); len(warnings) != 1 || warnings[0].File != rulesfileNameFromPlugin(plugin.Name) {
+		t.Fatalf("expected a warning for the rulesfile, got %v", warnings)
+	}
+
+	push.failOnEngineDowngrade = true
+	_, err = previousRulesfileRequirements(context.Background(), push, unreachableRegistry{}, plugin, ref, "0.7.0")
+	if err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Fatalf("expected the registry error, got %v", err)
+	}
+	if len(collector.Warnings()) != 1 {
+		t.Fatalf("expected no more warnings, got %v", collector.Warnings())
+	}
+}
+
+func TestRefFromPluginEntry(t *testing.T) {
+	t.Parallel()
+
+	// References are slash-separated whatever the platform, as they are not local paths.
+	cfg := &config{registryHost: "ghcr.io", registryUser: "falcosecurity"}
+	plugin := &registry.Plugin{Name: "k8saudit"}
+	if ref := refFromPluginEntry(cfg, plugin, false); ref != "ghcr.io/falcosecurity/plugins/plugin/k8saudit" {
+		t.Fatalf("unexpected plugin reference %q", ref)
+	}
+	if ref := refFromPluginEntry(cfg, plugin, true); ref != "ghcr.io/falcosecurity/plugins/ruleset/k8saudit" {
+		t.Fatalf("unexpected rulesfile reference %q", ref)
+	}
+}
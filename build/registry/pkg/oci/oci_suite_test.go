@@ -18,6 +18,11 @@ limitations under the License.
 package oci_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -34,3 +39,44 @@ func TestOCI(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "OCI Suite")
 }
+
+// writeRulesfile writes a rulesfile holding the given content to a temporary directory, returning its path.
+func writeRulesfile(content string) string {
+	filePath := filepath.Join(GinkgoT().TempDir(), "rules.yaml")
+	Expect(os.WriteFile(filePath, []byte(content), 0o600)).To(Succeed())
+	return filePath
+}
+
+// gzipData returns the given data compressed with gzip.
+func gzipData(data []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(data)
+	Expect(err).To(BeNil())
+	Expect(gw.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+// writeFiles writes the given files, keyed by their slash-separated path relative to root, creating their directories.
+func writeFiles(root string, files map[string]string) {
+	for name, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(name))
+		Expect(os.MkdirAll(filepath.Dir(filePath), 0o700)).To(Succeed())
+		Expect(os.WriteFile(filePath, []byte(content), 0o600)).To(Succeed())
+	}
+}
+
+// writeTarGz writes a tar.gz archive holding the given files, keyed by name, to the given path.
+func writeTarGz(filePath string, files map[string]string) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		Expect(err).To(BeNil())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gw.Close()).To(Succeed())
+	Expect(os.WriteFile(filePath, buf.Bytes(), 0o600)).To(Succeed())
+}
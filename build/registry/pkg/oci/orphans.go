@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// RulesfilesConsistency lists the inconsistencies between the rulesfiles in the source tree of the plugins and the
+// entries of the registry.
+type RulesfilesConsistency struct {
+	// Orphaned are the rulesfiles found in the source tree that no registry entry refers to, relative to the root
+	// of the tree. Their requirements are never validated nor published.
+	Orphaned []string
+	// Missing are the names of the registry entries declaring rulesfiles, none of which is found in the tree.
+	Missing []string
+}
+
+// CheckRulesfilesConsistency given the registry and the directory containing the source tree of the plugins, as the
+// "plugins" directory of this repository, it lists the rulesfiles in the tree that are not referred to by the registry.
+// As for RegistrySummary, the rulesfiles of a registry entry are expected in "<pluginsDir>/<name>/rules", and any
+// rulesfile found by WalkRequirements in a "rules" directory is expected to belong to an entry with a rules url.
+// If checkMissing is true, the registry entries declaring rulesfiles not found in the tree are listed too.
+func CheckRulesfilesConsistency(reg *registry.Registry, pluginsDir string, checkMissing bool) (*RulesfilesConsistency, error) {
+	files, err := walkFiles(pluginsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rules directories of the entries declaring rulesfiles, relative to the plugins directory and slash-separated
+	// as the paths returned by walkFiles.
+	referenced := make(map[string]bool)
+	for _, p := range reg.Plugins {
+		if !p.Reserved && p.RulesURL != "" {
+			referenced[path.Join(p.Name, "rules")] = true
+		}
+	}
+
+	result := &RulesfilesConsistency{}
+	found := make(map[string]bool)
+	for _, f := range files {
+		dir := path.Dir(f)
+		if !isRulesfileName(f) || path.Base(dir) != "rules" {
+			continue
+		}
+		found[dir] = true
+		if !referenced[dir] {
+			result.Orphaned = append(result.Orphaned, f)
+		}
+	}
+	sort.Strings(result.Orphaned)
+
+	if checkMissing {
+		for _, p := range reg.Plugins {
+			dir := path.Join(p.Name, "rules")
+			if !referenced[dir] || found[dir] {
+				continue
+			}
+			result.Missing = append(result.Missing, p.Name)
+		}
+		sort.Strings(result.Missing)
+	}
+
+	return result, nil
+}
+
+// Empty returns true if no inconsistency has been found.
+func (c *RulesfilesConsistency) Empty() bool {
+	return len(c.Orphaned) == 0 && len(c.Missing) == 0
+}
+
+// PrintRulesfilesConsistency writes a line for each orphaned rulesfile and for each registry entry whose rulesfiles
+// are missing.
+func PrintRulesfilesConsistency(c *RulesfilesConsistency, output io.Writer) error {
+	var b strings.Builder
+	for _, f := range c.Orphaned {
+		fmt.Fprintf(&b, "orphaned rulesfile %q: not referred to by any registry entry\n", f)
+	}
+	for _, name := range c.Missing {
+		fmt.Fprintf(&b, "missing rulesfiles for registry entry %q\n", name)
+	}
+
+	_, err := io.WriteString(output, b.String())
+	return err
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Check rulesfiles consistency", func() {
+	var pluginsDir string
+	reg := &registry.Registry{Plugins: []registry.Plugin{
+		{Name: "k8saudit", RulesURL: "https://example.com/rules"},
+		{Name: "cloudtrail", RulesURL: "https://example.com/rules"},
+		{Name: "okta"},
+	}}
+
+	BeforeEach(func() {
+		pluginsDir = GinkgoT().TempDir()
+		writeFiles(pluginsDir, map[string]string{
+			"k8saudit/rules/k8s_audit_rules.yaml": "- rule: open\n",
+			"okta/rules/okta_rules.yaml":          "- rule: open\n",
+			// Only the rulesfiles in the rules directories are expected in the registry.
+			"okta/config.yaml": "- rule: open\n",
+		})
+	})
+
+	It("should report the orphaned rulesfiles", func() {
+		consistency, err := oci.CheckRulesfilesConsistency(reg, pluginsDir, false)
+		Expect(err).To(BeNil())
+		Expect(consistency).To(Equal(&oci.RulesfilesConsistency{Orphaned: []string{"okta/rules/okta_rules.yaml"}}))
+	})
+
+	It("should report the missing rulesfiles if requested", func() {
+		consistency, err := oci.CheckRulesfilesConsistency(reg, pluginsDir, true)
+		Expect(err).To(BeNil())
+		Expect(consistency).To(Equal(&oci.RulesfilesConsistency{
+			Orphaned: []string{"okta/rules/okta_rules.yaml"},
+			Missing:  []string{"cloudtrail"},
+		}))
+	})
+})
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	"gopkg.in/yaml.v3"
+)
+
+// RequirementsOverrideSuffix is the suffix of the optional sidecar overriding the requirements extracted from a file
+// bundled in an artifact, e.g. "libfoo.so.requirements.yaml" for "libfoo.so", when the extracted ones are not the
+// ones to be published, e.g. since the api version declared by a plugin is too permissive:
+//
+//	requirements:
+//	  - name: plugin_api_version
+//	    version: 3.2.0
+//
+// Each requirement of the sidecar replaces the extracted one with the same name, or is added if there is none.
+// Sidecars are not rulesfiles, even though their extension is the same.
+const RequirementsOverrideSuffix = ".requirements.yaml"
+
+// requirementsOverride is the content of the sidecar overriding the requirements of a file, see
+// RequirementsOverrideSuffix.
+type requirementsOverride struct {
+	Requirements []oci.ArtifactRequirement `yaml:"requirements"`
+}
+
+// isRequirementsOverride reports whether the given file is the sidecar overriding the requirements of another file.
+func isRequirementsOverride(path string) bool {
+	return strings.HasSuffix(path, RequirementsOverrideSuffix)
+}
+
+// applyRequirementsOverride given the requirements extracted from a file, it returns them with the ones of its
+// sidecar applied, see RequirementsOverrideSuffix, or as they are if it has none. Each override is logged, so that
+// the published requirements differing from the extracted ones can be audited, with the logger of the given options.
+// Errors are *RequirementError values wrapping ErrOpenFailed or ErrParseFailed.
+func applyRequirementsOverride(filePath string, reqs []oci.ArtifactRequirement, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	sidecarPath := filePath + RequirementsOverrideSuffix
+	data, err := os.ReadFile(sidecarPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return reqs, nil
+	}
+	if err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("unable to read sidecar %q of file %q: %w: %w", sidecarPath, filePath, ErrOpenFailed, err))
+	}
+
+	var override requirementsOverride
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&override); err != nil {
+		return nil, newRequirementError(filePath, StageParse, fmt.Errorf("unable to parse sidecar %q of file %q: %w: %w", sidecarPath, filePath, ErrParseFailed, err))
+	}
+
+	log := newRequirementOptions(opts).log()
+	// The extracted requirements are not modified, since they could be shared by the caller.
+	result := append([]oci.ArtifactRequirement(nil), reqs...)
+	for _, req := range override.Requirements {
+		if req.Name == "" || strings.TrimSpace(req.Version) == "" {
+			return nil, newRequirementError(filePath, StageParse, fmt.Errorf("sidecar %q of file %q: requirement %q has no name or version: %w",
+				sidecarPath, filePath, req.Name, ErrParseFailed))
+		}
+		if _, err := semver.ParseRange(req.Version); err != nil {
+			return nil, newRequirementError(filePath, StageParse, fmt.Errorf("sidecar %q of file %q: unable to parse version %q of requirement %q: %w: %w",
+				sidecarPath, filePath, req.Version, req.Name, ErrParseFailed, err))
+		}
+
+		i := slices.IndexFunc(result, func(r oci.ArtifactRequirement) bool { return r.Name == req.Name })
+		if i < 0 {
+			log.Info("requirement added by sidecar", "file", filePath, "sidecar", sidecarPath, "name", req.Name, "version", req.Version)
+			result = append(result, req)
+			continue
+		}
+		log.Info("requirement overridden by sidecar", "file", filePath, "sidecar", sidecarPath, "name", req.Name,
+			"extracted", result[i].Version, "version", req.Version)
+		result[i] = req
+	}
+
+	return result, nil
+}
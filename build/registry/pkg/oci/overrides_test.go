@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Requirements override", func() {
+	var rulesfile string
+
+	BeforeEach(func() {
+		rulesfile = writeRulesfile("- required_engine_version: 10\n")
+	})
+
+	It("should override and add the requirements of the rulesfile", func() {
+		var buf bytes.Buffer
+		log := oci.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+		Expect(os.WriteFile(rulesfile+oci.RequirementsOverrideSuffix, []byte("requirements:\n  - name: "+common.EngineVersionKey+"\n    version: 0.11.0\n"+
+			"  - name: "+common.PluginAPIVersion+"\n    version: 3.2.0\n"), 0o600)).To(Succeed())
+
+		// The sidecar is not handled as a rulesfile.
+		reqs, err := oci.ArtifactRequirements(filepath.Dir(rulesfile), log)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{
+			{Name: common.EngineVersionKey, Version: "0.11.0"},
+			{Name: common.PluginAPIVersion, Version: "3.2.0"},
+		}))
+		Expect(buf.String()).To(ContainSubstring(`"msg":"requirement overridden by sidecar"`))
+		Expect(buf.String()).To(ContainSubstring(`"extracted":"0.10.0"`))
+		Expect(buf.String()).To(ContainSubstring(`"msg":"requirement added by sidecar"`))
+	})
+
+	DescribeTable("should fail for the invalid sidecars",
+		func(content string) {
+			Expect(os.WriteFile(rulesfile+oci.RequirementsOverrideSuffix, []byte(content), 0o600)).To(Succeed())
+
+			_, err := oci.ExtractRequirements(rulesfile)
+			var reqErr *oci.RequirementError
+			Expect(errors.As(err, &reqErr)).To(BeTrue())
+			Expect(reqErr.Stage).To(Equal(oci.StageParse))
+			Expect(err).To(MatchError(oci.ErrParseFailed))
+		},
+		Entry("no version", "requirements:\n  - name: "+common.PluginAPIVersion+"\n"),
+		Entry("invalid version", "requirements:\n  - name: "+common.PluginAPIVersion+"\n    version: latest\n"),
+		Entry("unknown key", "requirement:\n  - name: "+common.PluginAPIVersion+"\n    version: 3.2.0\n"),
+	)
+})
@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// ErrNothingToPack error when neither plugins nor rulesfiles are given to be packed.
+var ErrNothingToPack = errors.New("nothing to pack")
+
+// PackOptions describes the content of an artifact bundling a plugin and its rulesfiles.
+type PackOptions struct {
+	// Target is the storage where the blobs and manifests of the artifact are pushed.
+	Target content.Pusher
+	// Name of the artifact.
+	Name string
+	// Version of the artifact.
+	Version string
+	// Plugins maps each platform, in the "os/arch" format, to the archive containing the plugin built for it.
+	Plugins map[string]string
+	// Rulesfiles are the archives containing the rulesfiles, shared by all the platforms.
+	Rulesfiles []string
+	// Requirements are embedded in the config blob of the artifact.
+	Requirements []oci.ArtifactRequirement
+	// Dependencies are embedded in the config blob of the artifact.
+	Dependencies []oci.ArtifactDependency
+	// AnnotationSource is set as the source annotation of the manifests, if not empty.
+	AnnotationSource string
+}
+
+// PackArtifact packs a plugin and its rulesfiles as an OCI artifact, embedding the requirements and dependencies in its
+// config blob. A manifest is packed for each platform of the plugin and the descriptor of the index referencing them
+// is returned. If no plugin is given, the rulesfiles are packed in a single manifest and its descriptor is returned.
+// Packing the same content always produces the same descriptors, the creation time of the manifests being the one
+// set with SOURCE_DATE_EPOCH, see packCreated.
+func PackArtifact(ctx context.Context, opts PackOptions) (ocispec.Descriptor, error) {
+	if len(opts.Plugins) == 0 && len(opts.Rulesfiles) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("artifact %q: %w", opts.Name, ErrNothingToPack)
+	}
+
+	configMediaType := PluginConfigMediaType
+	if len(opts.Plugins) == 0 {
+		configMediaType = RulesfileConfigMediaType
+	}
+
+	cfg := oci.ArtifactConfig{
+		Name:         opts.Name,
+		Version:      opts.Version,
+		Dependencies: opts.Dependencies,
+		Requirements: slices.Clone(opts.Requirements),
+	}
+	// Sort the requirements to always produce the same config blob.
+	SortRequirements(cfg.Requirements)
+
+	configDesc, err := pushJSON(ctx, opts.Target, string(configMediaType), cfg)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	// Layers shared by all the manifests.
+	var rulesfileLayers []ocispec.Descriptor
+	for _, r := range opts.Rulesfiles {
+		desc, err := pushFile(ctx, opts.Target, oci.FalcoRulesfileLayerMediaType, r)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		rulesfileLayers = append(rulesfileLayers, desc)
+	}
+
+	created, err := packCreated()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	// Otherwise oras sets the creation time to the current time, changing the digests at each pack.
+	manifestAnnotations := map[string]string{ocispec.AnnotationCreated: created}
+	if opts.AnnotationSource != "" {
+		manifestAnnotations[ocispec.AnnotationSource] = opts.AnnotationSource
+	}
+
+	packOptions := oras.PackOptions{
+		ConfigDescriptor:    &configDesc,
+		ManifestAnnotations: manifestAnnotations,
+		PackImageManifest:   true,
+	}
+
+	// Rulesfiles only artifacts are packed in a single manifest.
+	if len(opts.Plugins) == 0 {
+		desc, err := oras.Pack(ctx, opts.Target, "", rulesfileLayers, packOptions)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("unable to pack manifest for artifact %q: %w", opts.Name, err)
+		}
+		return desc, nil
+	}
+
+	// Sort the platforms to always produce the same index.
+	platforms := make([]string, 0, len(opts.Plugins))
+	for p := range opts.Plugins {
+		platforms = append(platforms, p)
+	}
+	sort.Strings(platforms)
+
+	index := ocispec.Index{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageIndex,
+		Annotations: manifestAnnotations,
+	}
+
+	for _, p := range platforms {
+		tokens := strings.Split(p, "/")
+		if len(tokens) != 2 {
+			return ocispec.Descriptor{}, fmt.Errorf("invalid platform %q for artifact %q: expected format \"os/arch\"", p, opts.Name)
+		}
+
+		pluginLayer, err := pushFile(ctx, opts.Target, oci.FalcoPluginLayerMediaType, opts.Plugins[p])
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+
+		layers := append([]ocispec.Descriptor{pluginLayer}, rulesfileLayers...)
+		desc, err := oras.Pack(ctx, opts.Target, "", layers, packOptions)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("unable to pack manifest for artifact %q and platform %q: %w", opts.Name, p, err)
+		}
+		desc.Platform = &ocispec.Platform{
+			OS:           tokens[0],
+			Architecture: tokens[1],
+		}
+
+		index.Manifests = append(index.Manifests, desc)
+	}
+
+	return pushJSON(ctx, opts.Target, ocispec.MediaTypeImageIndex, index)
+}
+
+// sourceDateEpochEnv is the environment variable setting the timestamp of reproducible builds, as seconds since the
+// Unix epoch, see https://reproducible-builds.org/specs/source-date-epoch.
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// packCreated returns the creation time annotated on the packed manifests, in RFC 3339 format: the one set in
+// SOURCE_DATE_EPOCH, if any, the Unix epoch otherwise.
+func packCreated() (string, error) {
+	var seconds int64
+	if epoch := os.Getenv(sourceDateEpochEnv); epoch != "" {
+		var err error
+		if seconds, err = strconv.ParseInt(epoch, 10, 64); err != nil {
+			return "", fmt.Errorf("unable to parse %s %q: %w", sourceDateEpochEnv, epoch, err)
+		}
+	}
+
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+}
+
+// pushJSON marshals the given data and pushes it to the target as a blob of the given media type.
+func pushJSON(ctx context.Context, target content.Pusher, mediaType string, data interface{}) (ocispec.Descriptor, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("unable to marshal data of media type %q: %w", mediaType, err)
+	}
+
+	desc := content.NewDescriptorFromBytes(mediaType, dataBytes)
+	if err := pushBytes(ctx, target, desc, dataBytes); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return desc, nil
+}
+
+// pushBytes pushes the given data to the target as a blob with the given descriptor.
+func pushBytes(ctx context.Context, target content.Pusher, desc ocispec.Descriptor, data []byte) error {
+	// Identical blobs could already be present in the target.
+	if err := target.Push(ctx, desc, bytes.NewReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return fmt.Errorf("unable to push data of media type %q: %w", desc.MediaType, err)
+	}
+
+	return nil
+}
+
+// pushFile pushes the content of the given file to the target as a layer of the given media type.
+func pushFile(ctx context.Context, target content.Pusher, mediaType, filePath string) (ocispec.Descriptor, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("unable to read file %q: %w", filePath, err)
+	}
+
+	desc := content.NewDescriptorFromBytes(mediaType, data)
+	desc.Annotations = map[string]string{ocispec.AnnotationTitle: filepath.Base(filePath)}
+	if err := target.Push(ctx, desc, bytes.NewReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, fmt.Errorf("unable to push file %q: %w", filePath, err)
+	}
+
+	return desc, nil
+}
+
+// PackRulesfile given some rulesfiles it bundles them in a gzip compressed tarball, to be used as the rulesfile layer
+// of an artifact, and returns it together with the requirements to be embedded in the config blob, which are the
+// highest engine version required by the rulesfiles, see PackEngineRequirement. The tarball is reproducible: the
+// rulesfiles are stored by base name in lexical order, with zeroed ownership and modification times. The requirements
+// are extracted with the given options.
+func PackRulesfile(files []string, opts ...RequirementOption) ([]byte, []oci.ArtifactRequirement, error) {
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("rulesfile layer: %w", ErrNothingToPack)
+	}
+
+	req, err := PackEngineRequirement(files, WithPackRequirementOptions(opts...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sorted := slices.Clone(files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return filepath.Base(sorted[i]) < filepath.Base(sorted[j])
+	})
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for i, file := range sorted {
+		name := filepath.Base(file)
+		if i > 0 && name == filepath.Base(sorted[i-1]) {
+			return nil, nil, fmt.Errorf("rulesfiles %q and %q have the same name", sorted[i-1], file)
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read rulesfile %q: %w", file, err)
+		}
+
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(data)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, nil, fmt.Errorf("unable to write header of rulesfile %q: %w", file, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, nil, fmt.Errorf("unable to write rulesfile %q: %w", file, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("unable to close tarball: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("unable to close gzip stream: %w", err)
+	}
+
+	return buf.Bytes(), []oci.ArtifactRequirement{*req}, nil
+}
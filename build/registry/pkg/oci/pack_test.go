@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Pack artifact", func() {
+	var (
+		ctx   context.Context
+		store *memory.Store
+		opts  oci.PackOptions
+		desc  ocispec.Descriptor
+		err   error
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = memory.New()
+		dir := GinkgoT().TempDir()
+		for _, name := range []string{"plugin-amd64.tar.gz", "plugin-arm64.tar.gz", "rules.tar.gz"} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)).To(Succeed())
+		}
+		opts = oci.PackOptions{
+			Target:  store,
+			Name:    "k8saudit",
+			Version: "1.0.0",
+			Plugins: map[string]string{
+				"linux/amd64": filepath.Join(dir, "plugin-amd64.tar.gz"),
+				"linux/arm64": filepath.Join(dir, "plugin-arm64.tar.gz"),
+			},
+			Rulesfiles: []string{filepath.Join(dir, "rules.tar.gz")},
+			Requirements: []falcoctloci.ArtifactRequirement{
+				{Name: "plugin_api_version", Version: "3.0.0"},
+			},
+		}
+	})
+
+	When("plugins for multiple platforms are given", func() {
+		BeforeEach(func() {
+			desc, err = oci.PackArtifact(ctx, opts)
+		})
+
+		It("should not fail", func() {
+			Expect(err).To(BeNil())
+		})
+		It("should return an index with a manifest for each platform", func() {
+			Expect(desc.MediaType).To(Equal(ocispec.MediaTypeImageIndex))
+			data, err := content.FetchAll(ctx, store, desc)
+			Expect(err).To(BeNil())
+			var index ocispec.Index
+			Expect(json.Unmarshal(data, &index)).To(Succeed())
+			Expect(index.Manifests).To(HaveLen(2))
+			Expect(index.Manifests[0].Platform.Architecture).To(Equal("amd64"))
+			Expect(index.Manifests[1].Platform.Architecture).To(Equal("arm64"))
+		})
+		It("should embed the requirements in the config blob", func() {
+			data, err := content.FetchAll(ctx, store, desc)
+			Expect(err).To(BeNil())
+			var index ocispec.Index
+			Expect(json.Unmarshal(data, &index)).To(Succeed())
+			data, err = content.FetchAll(ctx, store, index.Manifests[0])
+			Expect(err).To(BeNil())
+			var manifest ocispec.Manifest
+			Expect(json.Unmarshal(data, &manifest)).To(Succeed())
+			Expect(manifest.Config.MediaType).To(Equal(falcoctloci.FalcoPluginConfigMediaType))
+			Expect(manifest.Layers).To(HaveLen(2))
+			data, err = content.FetchAll(ctx, store, manifest.Config)
+			Expect(err).To(BeNil())
+			var cfg falcoctloci.ArtifactConfig
+			Expect(json.Unmarshal(data, &cfg)).To(Succeed())
+			Expect(cfg.Requirements).To(Equal(opts.Requirements))
+		})
+	})
+
+	When("the same content is packed twice", func() {
+		It("should produce the same descriptors", func() {
+			desc, err = oci.PackArtifact(ctx, opts)
+			Expect(err).To(BeNil())
+			// The creation time of the manifests does not depend on when they are packed.
+			time.Sleep(1100 * time.Millisecond)
+			opts.Target = memory.New()
+			again, err := oci.PackArtifact(ctx, opts)
+			Expect(err).To(BeNil())
+			Expect(again).To(Equal(desc))
+		})
+
+		It("should annotate the creation time set with SOURCE_DATE_EPOCH", func() {
+			prev, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+			Expect(os.Setenv("SOURCE_DATE_EPOCH", "1700000000")).To(Succeed())
+			DeferCleanup(func() {
+				if ok {
+					os.Setenv("SOURCE_DATE_EPOCH", prev)
+				} else {
+					os.Unsetenv("SOURCE_DATE_EPOCH")
+				}
+			})
+
+			opts.Plugins = nil
+			desc, err = oci.PackArtifact(ctx, opts)
+			Expect(err).To(BeNil())
+			data, err := content.FetchAll(ctx, store, desc)
+			Expect(err).To(BeNil())
+			var manifest ocispec.Manifest
+			Expect(json.Unmarshal(data, &manifest)).To(Succeed())
+			Expect(manifest.Annotations).To(HaveKeyWithValue(ocispec.AnnotationCreated, "2023-11-14T22:13:20Z"))
+		})
+	})
+
+	When("nothing is given", func() {
+		BeforeEach(func() {
+			opts.Plugins = nil
+			opts.Rulesfiles = nil
+			desc, err = oci.PackArtifact(ctx, opts)
+		})
+
+		It("should fail", func() {
+			Expect(err).To(MatchError(oci.ErrNothingToPack))
+		})
+	})
+})
+
+var _ = Describe("Pack rulesfile", func() {
+	var files []string
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		files = nil
+		for name, version := range map[string]string{"b.yaml": "0.31.0", "a.yaml": "0.35.0", "c.yaml": "0.26.0"} {
+			filePath := filepath.Join(dir, name)
+			Expect(os.WriteFile(filePath, []byte("- required_engine_version: "+version+"\n"), 0o600)).To(Succeed())
+			files = append(files, filePath)
+		}
+	})
+
+	It("should return the highest engine requirement", func() {
+		_, reqs, err := oci.PackRulesfile(files)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{{Name: "engine_version_semver", Version: "0.35.0"}}))
+	})
+
+	It("should pack the rulesfiles sorted by name", func() {
+		layer, _, err := oci.PackRulesfile(files)
+		Expect(err).To(BeNil())
+		gr, err := gzip.NewReader(bytes.NewReader(layer))
+		Expect(err).To(BeNil())
+		tr := tar.NewReader(gr)
+		var names []string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).To(BeNil())
+			names = append(names, hdr.Name)
+		}
+		Expect(names).To(Equal([]string{"a.yaml", "b.yaml", "c.yaml"}))
+	})
+
+	It("should produce the same layer regardless of the order and times of the files", func() {
+		first, _, err := oci.PackRulesfile(files)
+		Expect(err).To(BeNil())
+		Expect(os.Chtimes(files[0], time.Now(), time.Now().Add(-time.Hour))).To(Succeed())
+		second, _, err := oci.PackRulesfile([]string{files[2], files[0], files[1]})
+		Expect(err).To(BeNil())
+		Expect(second).To(Equal(first))
+	})
+})
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/falcosecurity/plugin-sdk-go/pkg/loader"
+)
+
+// pluginCacheKey identifies a plugin loaded from the filesystem. The modification time is part of the key
+// so that a plugin rewritten at the same path is loaded again. The digest is part of it too, so that a plugin loaded
+// without verification is never returned to a caller expecting a digest, see WithExpectedDigest.
+type pluginCacheKey struct {
+	path    string
+	modTime time.Time
+	// digest is the verified sha256 digest of the plugin, empty if it has been loaded without verification.
+	digest string
+}
+
+var (
+	pluginCacheMu sync.Mutex
+	pluginCache   = make(map[pluginCacheKey]*loader.Plugin)
+	// stalePlugins are the plugins replaced in the cache by a newer version of the same file. They are not unloaded
+	// when replaced, since the callers they have been returned to could still be using them, but by ClearPluginCache.
+	stalePlugins []*loader.Plugin
+)
+
+// loadPlugin given a plugin as a shared library it loads it, or returns the already loaded one if the same
+// file has been loaded before. This way each plugin is loaded only once per invocation of the tool. The returned
+// plugin stays loaded until ClearPluginCache is called, even once replaced in the cache by a rebuilt version.
+// If a digest is expected, see WithExpectedDigest, the plugin is verified and loaded from the same private copy, see
+// verifiedPluginCopy. The only options having an effect are WithExpectedDigest and WithPluginTempDir.
+//
+// The plugin is never initialized: loader.NewPlugin only opens the shared library and reads its static
+// info, such as the required api version, without invoking plugin_init. There is no lighter way to get
+// the required api version, since it is returned by a function exported by the library, which must be opened to call it.
+func loadPlugin(filePath string, opts ...RequirementOption) (*loader.Plugin, error) {
+	// Plugins reached through different symbolic links are loaded once.
+	absPath, err := realPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open plugin %q: %w", filePath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat plugin %q: %w", filePath, err)
+	}
+
+	key := pluginCacheKey{
+		path:    absPath,
+		modTime: info.ModTime(),
+	}
+	loadPath := absPath
+
+	o := newRequirementOptions(opts)
+	if o.expectedDigest != "" {
+		key.digest = normalizeDigest(o.expectedDigest)
+
+		pluginCacheMu.Lock()
+		plugin, ok := pluginCache[key]
+		pluginCacheMu.Unlock()
+		if ok {
+			return plugin, nil
+		}
+
+		if loadPath, err = verifiedPluginCopy(filePath, file, o); err != nil {
+			return nil, err
+		}
+		defer os.Remove(loadPath)
+	}
+
+	pluginCacheMu.Lock()
+	defer pluginCacheMu.Unlock()
+
+	if plugin, ok := pluginCache[key]; ok {
+		return plugin, nil
+	}
+
+	plugin, err := newPlugin(loadPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evict stale versions of the same plugin, without unloading them.
+	for k, p := range pluginCache {
+		if k.path == absPath && k.modTime != key.modTime {
+			stalePlugins = append(stalePlugins, p)
+			delete(pluginCache, k)
+		}
+	}
+
+	pluginCache[key] = plugin
+
+	return plugin, nil
+}
+
+// loadPluginError is the same as loadPlugin, but the errors are *RequirementError values wrapping ErrOpenFailed.
+func loadPluginError(filePath string, opts []RequirementOption) (*loader.Plugin, error) {
+	plugin, err := loadPlugin(filePath, opts...)
+	var reqErr *RequirementError
+	if errors.As(err, &reqErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("unable to open plugin %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+
+	return plugin, nil
+}
+
+// verifiedPluginCopy copies the given opened plugin to a private temporary file, see WithPluginTempDir, computing the
+// digest of the copy while writing it, and returns the path of the copy if the digest is the expected one. The caller
+// loads the copy and removes it: since the copy is the very content that has been verified, the plugin can not be
+// swapped in between. An error wrapping both ErrOpenFailed and ErrDigestMismatch is returned on mismatch.
+func verifiedPluginCopy(filePath string, file *os.File, o *requirementOptions) (string, error) {
+	tmp, err := os.CreateTemp(o.pluginTempDir, "registry-plugin-*.so")
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary file for plugin %q: %w", filePath, err)
+	}
+
+	dr := newDigestReader(filePath, file)
+	_, err = io.Copy(tmp, dr)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", newRequirementError(filePath, StageOpen, fmt.Errorf("unable to copy plugin %q to temporary file %q: %w: %w", filePath, tmp.Name(), ErrOpenFailed, err))
+	}
+	if err := checkDigest(filePath, dr.digest(), o.expectedDigest); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// newPlugin loads a plugin through loader.NewPlugin, converting any panic occurred while loading it into an error.
+// This way a single plugin built against an incompatible SDK does not take down the whole process. Note that
+// crashes occurred in the native code of the plugin, such as segmentation faults, can not be recovered.
+func newPlugin(filePath string) (plugin *loader.Plugin, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			plugin = nil
+			err = fmt.Errorf("panic while loading plugin %q, likely an ABI mismatch with the plugin SDK: %v", filePath, r)
+		}
+	}()
+
+	return loader.NewPlugin(filePath)
+}
+
+// ClearPluginCache unloads all the cached plugins, including the stale versions evicted from the cache, and empties
+// the cache. It must not be called while the extraction of plugin requirements or info is in progress.
+func ClearPluginCache() {
+	pluginCacheMu.Lock()
+	defer pluginCacheMu.Unlock()
+
+	for k, p := range pluginCache {
+		p.Unload()
+		delete(pluginCache, k)
+	}
+	for _, p := range stalePlugins {
+		p.Unload()
+	}
+	stalePlugins = nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifiedPluginCopy(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("not a shared library")
+	filePath := filepath.Join(t.TempDir(), "libfake.so")
+	if err := os.WriteFile(filePath, content, 0o600); err != nil {
+		t.Fatalf("unable to write plugin: %v", err)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatalf("unable to open plugin: %v", err)
+	}
+	defer file.Close()
+	sum := sha256.Sum256(content)
+
+	copyPath, err := verifiedPluginCopy(filePath, file, newRequirementOptions([]RequirementOption{
+		WithExpectedDigest(hex.EncodeToString(sum[:])), WithPluginTempDir(t.TempDir()),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Rewriting the plugin after the verification does not change the copy being loaded.
+	if err := os.WriteFile(filePath, []byte("tampered"), 0o600); err != nil {
+		t.Fatalf("unable to write plugin: %v", err)
+	}
+	if data, err := os.ReadFile(copyPath); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("expected the verified content, got %q and %v", data, err)
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// ErrCapabilitiesMismatch error when the capabilities declared in the registry differ from the ones of the plugin.
+var ErrCapabilitiesMismatch = errors.New("capabilities mismatch")
+
+// PluginCapabilities describes the capabilities a plugin reports about itself.
+type PluginCapabilities struct {
+	// Sourcing is true if the plugin has the event sourcing capability.
+	Sourcing bool
+	// ID is the id of the events produced by the plugin, if it has the event sourcing capability.
+	ID uint32
+	// EventSource is the source of the events produced by the plugin, if it has the event sourcing capability.
+	EventSource string
+	// Extraction is true if the plugin has the field extraction capability.
+	Extraction bool
+	// ExtractEventSources are the event sources the plugin extracts fields from, empty if it is compatible with all
+	// of them or if it has not the field extraction capability.
+	ExtractEventSources []string
+	// Fields are the names of the fields the plugin extracts.
+	Fields []string
+}
+
+// LoadPluginCapabilities given a plugin as a shared library it loads it and returns its capabilities, e.g. to compare
+// them with the ones in the registry. Plugins are loaded only once, hence calling it together with the requirements
+// extraction does not load the shared library again. The only options having an effect are WithExpectedDigest and
+// WithPluginTempDir.
+func LoadPluginCapabilities(filePath string, opts ...RequirementOption) (*PluginCapabilities, error) {
+	plugin, err := loadPluginError(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info := plugin.Info()
+	caps := &PluginCapabilities{
+		Sourcing:   plugin.HasCapSourcing(),
+		Extraction: plugin.HasCapExtraction(),
+	}
+	if caps.Sourcing {
+		caps.ID = info.ID
+		caps.EventSource = info.EventSource
+	}
+	if caps.Extraction {
+		caps.ExtractEventSources = info.ExtractEventSources
+		for _, f := range plugin.Fields() {
+			caps.Fields = append(caps.Fields, f.Name)
+		}
+	}
+
+	return caps, nil
+}
+
+// CheckPluginCapabilities compares the capabilities of a plugin with the ones declared by its registry entry, and
+// returns an error wrapping ErrCapabilitiesMismatch for each difference. The extraction sources are compared only
+// if both declare them, since an empty list has different meanings depending on the sourcing capability.
+func CheckPluginCapabilities(caps *PluginCapabilities, plugin *registry.Plugin) error {
+	var errs []error
+	mismatch := func(what string, declared, found interface{}) {
+		errs = append(errs, fmt.Errorf("plugin %q: %s declared as %v in the registry, found %v: %w",
+			plugin.Name, what, declared, found, ErrCapabilitiesMismatch))
+	}
+
+	sourcing := plugin.Capabilities.Sourcing
+	if sourcing.Supported != caps.Sourcing {
+		mismatch("sourcing capability", sourcing.Supported, caps.Sourcing)
+	} else if caps.Sourcing {
+		if sourcing.ID != uint(caps.ID) {
+			mismatch("event source id", sourcing.ID, caps.ID)
+		}
+		if sourcing.Source != caps.EventSource {
+			mismatch("event source", sourcing.Source, caps.EventSource)
+		}
+	}
+
+	extraction := plugin.Capabilities.Extraction
+	if extraction.Supported != caps.Extraction {
+		mismatch("extraction capability", extraction.Supported, caps.Extraction)
+	} else if caps.Extraction && len(extraction.Sources) > 0 && len(caps.ExtractEventSources) > 0 {
+		declared := slices.Clone(extraction.Sources)
+		found := slices.Clone(caps.ExtractEventSources)
+		slices.Sort(declared)
+		slices.Sort(found)
+		if !slices.Equal(declared, found) {
+			mismatch("extraction sources", declared, found)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// EventSources returns all the event sources the plugin declares, i.e. the one of the events it produces, if it has
+// the event sourcing capability, and the ones it extracts fields from, sorted and without duplicates.
+func (c *PluginCapabilities) EventSources() []string {
+	var sources []string
+	if c.Sourcing && c.EventSource != "" {
+		sources = append(sources, c.EventSource)
+	}
+	if c.Extraction {
+		sources = append(sources, c.ExtractEventSources...)
+	}
+	slices.Sort(sources)
+
+	return slices.Compact(sources)
+}
+
+// CheckPluginSources checks that the event sources declared by the registry entry of a plugin, both for sourcing and
+// extraction, are a subset of the ones reported by the plugin, as returned by EventSources. It returns an error
+// wrapping ErrCapabilitiesMismatch listing the sources the plugin does not report. As in CheckPluginCapabilities, an
+// empty list of extraction sources means the plugin only extracts fields from its own source if it can source events,
+// and from all the event sources otherwise, in which case the declared extraction sources are not checked.
+func CheckPluginSources(caps *PluginCapabilities, plugin *registry.Plugin) error {
+	var declared []string
+	if plugin.Capabilities.Sourcing.Supported && plugin.Capabilities.Sourcing.Source != "" {
+		declared = append(declared, plugin.Capabilities.Sourcing.Source)
+	}
+	extractAll := caps.Extraction && !caps.Sourcing && len(caps.ExtractEventSources) == 0
+	if plugin.Capabilities.Extraction.Supported && !extractAll {
+		declared = append(declared, plugin.Capabilities.Extraction.Sources...)
+	}
+
+	found := caps.EventSources()
+	var mismatched []string
+	for _, s := range declared {
+		if !slices.Contains(found, s) && !slices.Contains(mismatched, s) {
+			mismatched = append(mismatched, s)
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	slices.Sort(mismatched)
+
+	return fmt.Errorf("plugin %q: sources %v declared in the registry are not reported by the plugin, found %v: %w",
+		plugin.Name, mismatched, found, ErrCapabilitiesMismatch)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Plugin capabilities", func() {
+	It("should report all the differences with the registry", func() {
+		plugin := &registry.Plugin{Name: "k8saudit"}
+		plugin.Capabilities.Sourcing = registry.SourcingCapability{Supported: true, ID: 1, Source: "k8s_audit"}
+		plugin.Capabilities.Extraction = registry.ExtractionCapability{Supported: true, Sources: []string{"k8s_audit", "aws_cloudtrail"}}
+
+		caps := &oci.PluginCapabilities{
+			Sourcing:            true,
+			ID:                  1,
+			EventSource:         "k8s_audit",
+			Extraction:          true,
+			ExtractEventSources: []string{"aws_cloudtrail", "k8s_audit"},
+		}
+		Expect(oci.CheckPluginCapabilities(caps, plugin)).To(Succeed())
+
+		caps.ID = 2
+		caps.ExtractEventSources = []string{"k8s_audit"}
+		err := oci.CheckPluginCapabilities(caps, plugin)
+		Expect(err).To(MatchError(oci.ErrCapabilitiesMismatch))
+		Expect(err.Error()).To(ContainSubstring("event source id"))
+		Expect(err.Error()).To(ContainSubstring("extraction sources"))
+
+		err = oci.CheckPluginCapabilities(&oci.PluginCapabilities{Extraction: true}, plugin)
+		Expect(err).To(MatchError(oci.ErrCapabilitiesMismatch))
+		Expect(err.Error()).To(ContainSubstring("sourcing capability"))
+	})
+
+	It("should check the sources declared in the registry", func() {
+		plugin := &registry.Plugin{Name: "cloudtrail"}
+		plugin.Capabilities.Sourcing = registry.SourcingCapability{Supported: true, ID: 2, Source: "aws_cloudtrail"}
+		plugin.Capabilities.Extraction = registry.ExtractionCapability{Supported: true, Sources: []string{"aws_cloudtrail", "s3"}}
+
+		caps := &oci.PluginCapabilities{
+			Sourcing:            true,
+			ID:                  2,
+			EventSource:         "aws_cloudtrail",
+			Extraction:          true,
+			ExtractEventSources: []string{"s3", "aws_cloudtrail", "k8s_audit"},
+		}
+		Expect(caps.EventSources()).To(Equal([]string{"aws_cloudtrail", "k8s_audit", "s3"}))
+		// The registry can declare a subset of the sources of the plugin.
+		Expect(oci.CheckPluginSources(caps, plugin)).To(Succeed())
+
+		plugin.Capabilities.Extraction.Sources = []string{"s3", "gcp_audit", "azure"}
+		err := oci.CheckPluginSources(caps, plugin)
+		Expect(err).To(MatchError(oci.ErrCapabilitiesMismatch))
+		Expect(err.Error()).To(ContainSubstring("[azure gcp_audit]"))
+
+		// Sourcing plugins without extraction sources only extract from their own source.
+		caps.ExtractEventSources = nil
+		Expect(oci.CheckPluginSources(caps, plugin)).To(MatchError(oci.ErrCapabilitiesMismatch))
+
+		// Plugins only extracting from all the sources are compatible with any of them, but can not declare sourcing.
+		caps = &oci.PluginCapabilities{Extraction: true}
+		plugin.Capabilities.Sourcing = registry.SourcingCapability{}
+		Expect(oci.CheckPluginSources(caps, plugin)).To(Succeed())
+		plugin.Capabilities.Sourcing = registry.SourcingCapability{Supported: true, ID: 2, Source: "aws_cloudtrail"}
+		Expect(oci.CheckPluginSources(caps, plugin)).To(MatchError(oci.ErrCapabilitiesMismatch))
+	})
+})
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	// ErrInvalidInitSchema error when the init config schema of a plugin is not a valid JSON Schema.
+	ErrInvalidInitSchema = errors.New("invalid init config schema")
+	// ErrInvalidOpenParams error when the open params suggested by a plugin are not valid json.
+	ErrInvalidOpenParams = errors.New("invalid open params")
+	// ErrUncheckedOpenParams warning when the open params of a plugin can not be checked, since it can not be
+	// initialized with the empty config.
+	ErrUncheckedOpenParams = errors.New("open params not checked")
+)
+
+// ValidateSchemasOption is a functional option for ValidatePluginSchemas.
+type ValidateSchemasOption func(*validateSchemasOptions)
+
+type validateSchemasOptions struct {
+	// openParams initializes the plugin to check the open params it suggests.
+	openParams bool
+	// reqOpts are the options providing the logger and the warning collector, see WithLogger and
+	// WithWarningCollector.
+	reqOpts []RequirementOption
+}
+
+// WithOpenParamsCheck makes ValidatePluginSchemas also check that the open params suggested by the plugin can be
+// parsed, returning an error wrapping ErrInvalidOpenParams otherwise. Plugins only report the open params once
+// initialized, hence the plugin is initialized with the empty config, which runs its init code: if that fails, e.g.
+// because a config is required, the open params are not checked and a warning wrapping ErrUncheckedOpenParams is
+// raised. Only the plugins with the event sourcing capability are initialized.
+func WithOpenParamsCheck() ValidateSchemasOption {
+	return func(o *validateSchemasOptions) {
+		o.openParams = true
+	}
+}
+
+// WithSchemasRequirementOptions validates the schemas with the given options, of which only WithLogger and
+// WithWarningCollector have an effect.
+func WithSchemasRequirementOptions(opts ...RequirementOption) ValidateSchemasOption {
+	return func(o *validateSchemasOptions) {
+		o.reqOpts = append(o.reqOpts, opts...)
+	}
+}
+
+// ValidatePluginSchemas given a plugin as a shared library it loads it and checks that its init config schema is a
+// valid JSON Schema, returning an error wrapping ErrInvalidInitSchema otherwise. The plugin is not initialized, unless
+// the open params are checked too, see WithOpenParamsCheck. The plugin is loaded on its own and unloaded before
+// returning, instead of being cached.
+func ValidatePluginSchemas(filePath string, opts ...ValidateSchemasOption) error {
+	o := &validateSchemasOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	plugin, err := newPlugin(filePath)
+	if err != nil {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to open plugin %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+	defer plugin.Unload()
+
+	if schema := plugin.InitSchema(); schema != nil {
+		if err := validateInitSchema(schema.Schema); err != nil {
+			return fmt.Errorf("plugin %q: %w", filePath, err)
+		}
+	}
+
+	if !o.openParams || !plugin.HasCapSourcing() {
+		return nil
+	}
+
+	if err := plugin.Init(""); err != nil {
+		reqOpts := newRequirementOptions(o.reqOpts)
+		reqOpts.log().Warn("open params not checked, unable to initialize the plugin with the empty config", "file", filePath, "error", err)
+		reqOpts.warn(filePath, fmt.Errorf("plugin %q: %w: %w", filePath, ErrUncheckedOpenParams, err))
+		return nil
+	}
+	if _, err := plugin.OpenParams(); err != nil {
+		return fmt.Errorf("plugin %q: %w: %w", filePath, ErrInvalidOpenParams, err)
+	}
+
+	return nil
+}
+
+// validateInitSchema returns an error wrapping ErrInvalidInitSchema if the given schema is not a valid JSON Schema.
+// An empty schema is valid, since it means the plugin does not declare one.
+func validateInitSchema(schema string) error {
+	if schema == "" {
+		return nil
+	}
+
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema)); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInitSchema, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateInitSchema(t *testing.T) {
+	t.Parallel()
+
+	valid := `{"type": "object", "properties": {"maxEventSize": {"type": "integer"}}}`
+	for _, schema := range []string{"", valid} {
+		if err := validateInitSchema(schema); err != nil {
+			t.Fatalf("unexpected error for schema %q: %v", schema, err)
+		}
+	}
+
+	for _, schema := range []string{`{"type": "object"`, `{"type": "not-a-type"}`} {
+		if err := validateInitSchema(schema); !errors.Is(err, ErrInvalidInitSchema) {
+			t.Fatalf("expected error %v for schema %q, got %v", ErrInvalidInitSchema, schema, err)
+		}
+	}
+}
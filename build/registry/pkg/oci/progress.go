@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import "sync"
+
+// ProgressFunc is called each time a file or an artifact of a batch or registry-wide operation has been processed,
+// with the number of the ones processed so far, their total and the path of the one just processed. Calls are
+// serialized, even when the files are processed concurrently, hence the function needs not be safe for concurrent
+// use. It should return quickly, since it blocks the processing.
+type ProgressFunc func(completed, total int, currentPath string)
+
+// BatchOption is a functional option for the batch and registry-wide operations, such as BatchRequirements.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	progress ProgressFunc
+	// reqOpts are the options the requirements of each file are extracted with.
+	reqOpts []RequirementOption
+}
+
+// WithProgress reports the progress of the operation to the given function, e.g. to render a progress bar.
+func WithProgress(fn ProgressFunc) BatchOption {
+	return func(o *batchOptions) {
+		o.progress = fn
+	}
+}
+
+// WithBatchRequirementOptions extracts the requirements of each file with the given options.
+func WithBatchRequirementOptions(opts ...RequirementOption) BatchOption {
+	return func(o *batchOptions) {
+		o.reqOpts = append(o.reqOpts, opts...)
+	}
+}
+
+// newBatchOptions returns the batchOptions resulting from applying opts to the defaults.
+func newBatchOptions(opts []BatchOption) *batchOptions {
+	o := &batchOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	return o
+}
+
+// progressTracker counts the processed items of an operation, reporting them to the ProgressFunc, if any.
+type progressTracker struct {
+	mu        sync.Mutex
+	fn        ProgressFunc
+	completed int
+	total     int
+}
+
+// newProgressTracker returns the tracker of an operation processing total items, with the given options.
+func newProgressTracker(opts []BatchOption, total int) *progressTracker {
+	return &progressTracker{fn: newBatchOptions(opts).progress, total: total}
+}
+
+// done reports that the item with the given path has been processed. It is safe for concurrent use.
+func (p *progressTracker) done(path string) {
+	if p.fn == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.completed++
+	p.fn(p.completed, p.total, path)
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+)
+
+// PulledArtifactRequirements given the directory where an artifact has been pulled, e.g. by "falcoctl artifact
+// install" or "falcoctl artifact pull", it re-derives the requirements from the plugin as a shared library and the
+// rulesfiles found in it, to validate artifacts not built by this tool. Layers left compressed, as *.tar.gz archives,
+// are extracted beforehand. Artifacts containing only rulesfiles, or only a plugin, are supported. Requirements are
+// deduplicated by name keeping the highest version, and returned sorted by name. The requirements are extracted with
+// the given options.
+func PulledArtifactRequirements(root string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error) {
+	tmpDir, err := os.MkdirTemp("", "registry-pulled-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary dir while preparing to extract the layers in %q: %w", root, err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Extract each compressed layer in its own directory, since layers could contain files with the same name.
+	var layers int
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (!strings.HasSuffix(d.Name(), archive_suffix) && filepath.Ext(d.Name()) != ".tgz") {
+			return nil
+		}
+
+		layers++
+		layerDir := filepath.Join(tmpDir, fmt.Sprintf("layer-%d", layers))
+		if err := os.Mkdir(layerDir, 0o700); err != nil {
+			return fmt.Errorf("unable to create dir to extract layer %q: %w", path, err)
+		}
+		if _, err := common.ExtractTarGz(path, layerDir); err != nil {
+			return fmt.Errorf("unable to extract layer %q: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var requirements []oci.ArtifactRequirement
+	for _, dir := range []string{root, tmpDir} {
+		reqs, err := WalkRequirements(dir, WithWalkRequirementOptions(opts...))
+		if err != nil {
+			return nil, err
+		}
+
+		// Merge the requirements in a deterministic order.
+		paths := make([]string, 0, len(reqs))
+		for p := range reqs {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if requirements, err = mergeRequirement(requirements, reqs[p], filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(requirements) == 0 {
+		return nil, fmt.Errorf("requirements for pulled artifact %q: %w", root, ErrReqNotFound)
+	}
+
+	SortRequirements(requirements)
+
+	return requirements, nil
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"path/filepath"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Pulled artifact requirements", func() {
+	It("should extract the requirements of the installed and of the compressed layers", func() {
+		// A rulesfiles only artifact, installed in the rules directory.
+		root := GinkgoT().TempDir()
+		writeFiles(root, map[string]string{"rules/k8s_audit_rules.yaml": "- required_engine_version: 0.31.0\n"})
+
+		reqs, err := oci.PulledArtifactRequirements(root)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{{Name: "engine_version_semver", Version: "0.31.0"}}))
+
+		// Compressed layers are extracted, the highest requirement is kept.
+		writeTarGz(filepath.Join(root, "k8saudit-rules-0.7.0.tar.gz"), map[string]string{
+			"k8s_audit_rules.yaml": "- required_engine_version: 0.35.0\n",
+			"README.md":            "not a rulesfile\n",
+		})
+		reqs, err = oci.PulledArtifactRequirements(root)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].Version).To(Equal("0.35.0"))
+	})
+
+	It("should fail for the artifacts without requirements", func() {
+		_, err := oci.PulledArtifactRequirements(GinkgoT().TempDir())
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+	})
+})
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	ocipusher "github.com/falcosecurity/falcoctl/pkg/oci/pusher"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+)
+
+// testRegistry is an in-memory OCI registry implementing the subset of the distribution API used to push and pull
+// artifacts: monolithic blob uploads, manifests and tags.
+type testRegistry struct {
+	mu sync.Mutex
+	// blobs and manifests are keyed by repository and digest.
+	blobs     map[string][]byte
+	manifests map[string]testManifest
+	// tags are keyed by repository and tag, and map to the digest of the tagged manifest.
+	tags    map[string]string
+	uploads map[string]bool
+}
+
+type testManifest struct {
+	mediaType string
+	content   []byte
+}
+
+// newTestRegistry starts a testRegistry, stopped at the end of the test, and returns its host.
+func newTestRegistry(t *testing.T) string {
+	r := &testRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]testManifest),
+		tags:      make(map[string]string),
+		uploads:   make(map[string]bool),
+	}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(path, "/blobs/uploads/") && req.Method == http.MethodPost:
+		id := randomID()
+		r.uploads[id] = true
+		w.Header().Set("Location", "/v2/"+path+id)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/uploads/") && req.Method == http.MethodPut:
+		repo, id, _ := strings.Cut(path, "/blobs/uploads/")
+		data, err := io.ReadAll(req.Body)
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		if !r.uploads[id] || err != nil || dgst.Validate() != nil || digest.FromBytes(data) != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delete(r.uploads, id)
+		r.blobs[repo+"@"+dgst.String()] = data
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/"+dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		repo, dgst, _ := strings.Cut(path, "/blobs/")
+		data, ok := r.blobs[repo+"@"+dgst]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.write(w, req, "application/octet-stream", dgst, data)
+	case strings.Contains(path, "/manifests/"):
+		r.serveManifest(w, req, path)
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags := []string{}
+		for k := range r.tags {
+			if tagRepo, tag, _ := strings.Cut(k, ":"); tagRepo == repo {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		data, _ := json.Marshal(map[string]interface{}{"name": repo, "tags": tags})
+		r.write(w, req, "application/json", "", data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveManifest serves the manifests endpoints, given the path of the request without the "/v2/" prefix.
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, path string) {
+	repo, reference, _ := strings.Cut(path, "/manifests/")
+	dgst := reference
+	if _, err := digest.Parse(reference); err != nil {
+		dgst = r.tags[repo+":"+reference]
+	}
+
+	if req.Method != http.MethodPut {
+		manifest, ok := r.manifests[repo+"@"+dgst]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.write(w, req, manifest.mediaType, dgst, manifest.content)
+		return
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	dgst = digest.FromBytes(data).String()
+	r.manifests[repo+"@"+dgst] = testManifest{mediaType: req.Header.Get("Content-Type"), content: data}
+	if dgst != reference {
+		r.tags[repo+":"+reference] = dgst
+	}
+	w.Header().Set("Docker-Content-Digest", dgst)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// write writes the given content, or only its headers for HEAD requests.
+func (r *testRegistry) write(w http.ResponseWriter, req *http.Request, mediaType, dgst string, data []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	if dgst != "" {
+		w.Header().Set("Docker-Content-Digest", dgst)
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func TestPushAndPullRequirements(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ociRegistry := NewRegistry(http.DefaultClient, true)
+	ref := newTestRegistry(t) + "/falcosecurity/plugins/ruleset/k8saudit-rules"
+
+	// No version has been published yet.
+	version, err := latestVersionArtifact(ctx, logger(), ref, ociRegistry)
+	if err != nil || version != "" {
+		t.Fatalf("expected no version and no error, got %q and %v", version, err)
+	}
+
+	layer, reqs, err := PackRulesfile([]string{writeRulesfile(t, "- required_engine_version: 0.31.0\n- rule: open\n")})
+	if err != nil {
+		t.Fatalf("unable to pack rulesfile: %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "k8saudit-rules.tar.gz")
+	if err := os.WriteFile(archive, layer, 0o600); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+
+	res, err := ociRegistry.Pusher().Push(ctx, oci.Rulesfile, ref,
+		ocipusher.WithTags("0.1.0", "latest"),
+		ocipusher.WithFilepaths([]string{archive}),
+		ocipusher.WithArtifactConfig(oci.ArtifactConfig{Name: "k8saudit-rules", Version: "0.1.0", Requirements: reqs}))
+	if err != nil {
+		t.Fatalf("unable to push artifact: %v", err)
+	}
+	if res.Digest == "" {
+		t.Fatalf("expected the digest of the pushed artifact")
+	}
+
+	if version, err = latestVersionArtifact(ctx, logger(), ref, ociRegistry); err != nil || version != "0.1.0" {
+		t.Fatalf("expected version %q and no error, got %q and %v", "0.1.0", version, err)
+	}
+
+	pulled, err := publishedRequirements(ctx, ociRegistry, ref, version)
+	if err != nil {
+		t.Fatalf("unable to pull requirements: %v", err)
+	}
+	if !reflect.DeepEqual(pulled, reqs) {
+		t.Fatalf("expected requirements %v, got %v", reqs, pulled)
+	}
+}
+
+func TestRegenerateArtifactConfigs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ociRegistry := NewRegistry(http.DefaultClient, true)
+	ref := newTestRegistry(t) + "/falcosecurity/plugins/ruleset/k8saudit-rules"
+
+	layer, reqs, err := PackRulesfile([]string{writeRulesfile(t, "- required_engine_version: 0.31.0\n- rule: open\n")})
+	if err != nil {
+		t.Fatalf("unable to pack rulesfile: %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "k8saudit-rules.tar.gz")
+	if err := os.WriteFile(archive, layer, 0o600); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+
+	// The config has been published by an older version of the extraction.
+	stale := []oci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.20.0"}}
+	res, err := ociRegistry.Pusher().Push(ctx, oci.Rulesfile, ref,
+		ocipusher.WithTags("0.1.0", "latest"),
+		ocipusher.WithFilepaths([]string{archive}),
+		ocipusher.WithArtifactConfig(oci.ArtifactConfig{Name: "k8saudit-rules", Version: "0.1.0", Requirements: stale}))
+	if err != nil {
+		t.Fatalf("unable to push artifact: %v", err)
+	}
+
+	repo, err := ociRegistry.Repository(ref)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before, err := repo.Resolve(ctx, ref+":latest")
+	if err != nil {
+		t.Fatalf("unable to resolve artifact: %v", err)
+	}
+	var beforeManifest ocispec.Manifest
+	if err := fetchJSON(ctx, repo, before, &beforeManifest); err != nil {
+		t.Fatalf("unable to fetch manifest: %v", err)
+	}
+
+	// Nothing is pushed in dry-run mode.
+	regenerated, err := RegenerateArtifactConfigs(ctx, repo, ref, []string{"0.1.0", "latest"}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(regenerated) != 1 || !reflect.DeepEqual(regenerated[0].Tags, []string{"0.1.0", "latest"}) {
+		t.Fatalf("expected a single artifact with both the tags, got %+v", regenerated)
+	}
+	want := []RequirementChange{{Name: common.EngineVersionKey, Kind: RequirementChanged, OldVersion: "0.20.0", NewVersion: "0.31.0"}}
+	if !reflect.DeepEqual(regenerated[0].Changes, want) {
+		t.Fatalf("expected changes %v, got %v", want, regenerated[0].Changes)
+	}
+	if regenerated[0].Digest != res.Digest || regenerated[0].NewDigest == res.Digest {
+		t.Fatalf("expected a new digest, got %+v", regenerated[0])
+	}
+	if pulled, err := publishedRequirements(ctx, ociRegistry, ref, "0.1.0"); err != nil || !reflect.DeepEqual(pulled, stale) {
+		t.Fatalf("expected requirements %v to be left untouched, got %v and %v", stale, pulled, err)
+	}
+
+	dryRunDigest := regenerated[0].NewDigest
+	if regenerated, err = RegenerateArtifactConfigs(ctx, repo, ref, []string{"0.1.0", "latest"}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if regenerated[0].NewDigest != dryRunDigest {
+		t.Fatalf("expected digest %q as in dry-run mode, got %q", dryRunDigest, regenerated[0].NewDigest)
+	}
+	for _, tag := range []string{"0.1.0", "latest"} {
+		pulled, err := publishedRequirements(ctx, ociRegistry, ref, tag)
+		if err != nil || !reflect.DeepEqual(pulled, reqs) {
+			t.Fatalf("expected requirements %v for tag %q, got %v and %v", reqs, tag, pulled, err)
+		}
+	}
+
+	// Layers are left untouched.
+	after, err := repo.Resolve(ctx, ref+":latest")
+	if err != nil {
+		t.Fatalf("unable to resolve artifact: %v", err)
+	}
+	var afterManifest ocispec.Manifest
+	if err := fetchJSON(ctx, repo, after, &afterManifest); err != nil {
+		t.Fatalf("unable to fetch manifest: %v", err)
+	}
+	if after.Digest.String() != dryRunDigest || !reflect.DeepEqual(afterManifest.Layers, beforeManifest.Layers) {
+		t.Fatalf("expected the same layers, got %v and %v", beforeManifest.Layers, afterManifest.Layers)
+	}
+
+	// Up to date artifacts are not changed.
+	if regenerated, err = RegenerateArtifactConfigs(ctx, repo, ref, []string{"0.1.0", "latest"}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(regenerated[0].Changes) != 0 || regenerated[0].NewDigest != regenerated[0].Digest {
+		t.Fatalf("expected no changes, got %+v", regenerated[0])
+	}
+}
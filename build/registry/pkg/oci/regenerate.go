@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	"github.com/falcosecurity/falcoctl/pkg/oci/authn"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote/auth"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// RegeneratedConfig describes the regeneration of the config blob of a published artifact, see
+// RegenerateArtifactConfigs.
+type RegeneratedConfig struct {
+	// Ref is the reference of the repository of the artifact.
+	Ref string
+	// Tags are the tags pointing to the artifact, e.g. its version and "latest".
+	Tags []string
+	// Digest is the digest of the manifest, or of the index, of the artifact before the regeneration.
+	Digest string
+	// NewDigest is the digest after the regeneration, the same as Digest if the requirements did not change.
+	NewDigest string
+	// Changes are the changes from the published requirements to the re-derived ones, empty if they match.
+	Changes []RequirementChange
+}
+
+// DoRegenerateConfigs regenerates the config blobs of all the published versions of the plugins and rulesfiles in the
+// registry file, see RegenerateArtifactConfigs. The registry is reached with the credentials in the environment
+// variables used by DoUpdateOCIRegistry, unless another one is given with WithRegistry. If dryRun is true, nothing is
+// pushed and the changes that would be made are returned.
+func DoRegenerateConfigs(ctx context.Context, registryFile string, dryRun bool, opts ...PushOption) ([]RegeneratedConfig, error) {
+	cfg, err := lookupConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.push = newPushOptions(opts)
+
+	ociRegistry := cfg.push.registry
+	if ociRegistry == nil {
+		ociRegistry = NewRegistry(authn.NewClient(authn.WithCredentials(&auth.Credential{
+			Username: cfg.registryUser,
+			Password: cfg.registryToken,
+		})), false)
+	}
+
+	reg, err := registry.LoadRegistryFromFile(registryFile)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred while loading registry entries from file %q: %v", registryFile, err)
+	}
+
+	var regenerated []RegeneratedConfig
+	for _, plugin := range reg.Plugins {
+		// Only the artifacts published by DoUpdateOCIRegistry are regenerated.
+		if plugin.Authors != falcoAuthors {
+			continue
+		}
+
+		refs := []string{refFromPluginEntry(cfg, &plugin, false)}
+		if plugin.RulesURL != "" {
+			refs = append(refs, refFromPluginEntry(cfg, &plugin, true))
+		}
+
+		for _, ref := range refs {
+			repo, err := ociRegistry.Repository(ref)
+			if err != nil {
+				return regenerated, err
+			}
+
+			tags, err := repo.Tags(ctx)
+			// Only way to know if the repo does not exist is to check the content of the error.
+			if err != nil && strings.Contains(err.Error(), "unexpected status code 404") {
+				cfg.push.log().Info("no versions found in the OCI registry", "ref", ref)
+				continue
+			}
+			if err != nil {
+				return regenerated, fmt.Errorf("unable to list tags of %q: %w", ref, err)
+			}
+
+			r, err := RegenerateArtifactConfigs(ctx, repo, ref, tags, dryRun, cfg.push.reqOpts...)
+			regenerated = append(regenerated, r...)
+			if err != nil {
+				return regenerated, err
+			}
+		}
+	}
+
+	return regenerated, nil
+}
+
+// RegenerateArtifactConfigs re-derives the requirements of the artifacts with the given tags in the repository of the
+// given reference from the plugins and rulesfiles bundled in their layers, see PulledArtifactRequirements. For the
+// artifacts whose requirements changed, only the config blob and the manifest, or the index and its manifests, are
+// pushed again, leaving the layers untouched, and all the tags are moved to the new manifest. Signature tags are
+// ignored: the regenerated artifacts have a new digest and must be signed again, see SignArtifact. If dryRun is true,
+// nothing is pushed and the changes that would be made are returned. The requirements are extracted with the given
+// options.
+func RegenerateArtifactConfigs(ctx context.Context, target oras.Target, ref string, tags []string, dryRun bool, opts ...RequirementOption) ([]RegeneratedConfig, error) {
+	// Group the tags by the manifest they point to, e.g. the version and "latest", to regenerate each one once.
+	var regenerated []RegeneratedConfig
+	descs := make(map[string]ocispec.Descriptor)
+	for _, tag := range tags {
+		if strings.HasSuffix(tag, signatureTagSuffix) {
+			continue
+		}
+
+		desc, err := target.Resolve(ctx, ref+":"+tag)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve reference %q: %w", ref+":"+tag, err)
+		}
+
+		digest := desc.Digest.String()
+		i := slices.IndexFunc(regenerated, func(r RegeneratedConfig) bool { return r.Digest == digest })
+		if i >= 0 {
+			regenerated[i].Tags = append(regenerated[i].Tags, tag)
+			continue
+		}
+		regenerated = append(regenerated, RegeneratedConfig{Ref: ref, Tags: []string{tag}, Digest: digest})
+		descs[digest] = desc
+	}
+
+	var pusher content.Pusher = target
+	if dryRun {
+		pusher = discardPusher{}
+	}
+
+	for i := range regenerated {
+		r := &regenerated[i]
+		newDesc, changes, err := regenerateConfig(ctx, target, pusher, ref, descs[r.Digest], opts)
+		if err != nil {
+			return regenerated[:i], err
+		}
+		r.NewDigest = newDesc.Digest.String()
+		r.Changes = changes
+
+		if len(changes) == 0 || dryRun {
+			continue
+		}
+		newRequirementOptions(opts).log().Info("regenerated config", "ref", ref, "tags", r.Tags, "digest", r.NewDigest)
+		for _, tag := range r.Tags {
+			if err := target.Tag(ctx, newDesc, ref+":"+tag); err != nil {
+				return regenerated[:i], fmt.Errorf("unable to tag %q: %w", ref+":"+tag, err)
+			}
+		}
+	}
+
+	return regenerated, nil
+}
+
+// regenerateConfig regenerates the config of the artifact with the given descriptor, pushing the new blobs to pusher,
+// and returns the descriptor of the new manifest or index together with the changes of the requirements. The given
+// descriptor is returned if the requirements did not change.
+func regenerateConfig(ctx context.Context, fetcher content.Fetcher, pusher content.Pusher,
+	ref string, desc ocispec.Descriptor, opts []RequirementOption) (ocispec.Descriptor, []RequirementChange, error) {
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		reqs, err := layersRequirements(ctx, fetcher, ref, desc, opts)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		return rewriteManifestConfig(ctx, fetcher, pusher, ref, desc, reqs)
+	}
+
+	// Unknown fields are preserved by decoding the index as a map.
+	var index map[string]json.RawMessage
+	if err := fetchJSON(ctx, fetcher, desc, &index); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to fetch index of %q: %w", ref, err)
+	}
+	var manifests []ocispec.Descriptor
+	if err := json.Unmarshal(index["manifests"], &manifests); err != nil || len(manifests) == 0 {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("index of %q has no manifests", ref)
+	}
+
+	// Plugins can only be loaded on their own platform, hence the requirements are derived from the manifest of the
+	// current platform, if any, and applied to all the manifests since they are the same for all the platforms.
+	source := manifests[0]
+	for _, m := range manifests {
+		if m.Platform != nil && m.Platform.OS+"/"+m.Platform.Architecture == currentPlatform() {
+			source = m
+			break
+		}
+	}
+	reqs, err := layersRequirements(ctx, fetcher, ref, source, opts)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+
+	var changes []RequirementChange
+	for i, m := range manifests {
+		newDesc, c, err := rewriteManifestConfig(ctx, fetcher, pusher, ref, m, reqs)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		if len(changes) == 0 {
+			changes = c
+		}
+		manifests[i] = newDesc
+	}
+	if len(changes) == 0 {
+		return desc, nil, nil
+	}
+
+	if index["manifests"], err = json.Marshal(manifests); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to marshal manifests of %q: %w", ref, err)
+	}
+	newDesc, err := pushJSON(ctx, pusher, desc.MediaType, index)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+
+	return newDesc, changes, nil
+}
+
+// rewriteManifestConfig compares the requirements in the config of the manifest with the given descriptor with reqs,
+// and if they differ it pushes a new config and a new manifest referencing it and the same layers. It returns the
+// descriptor of the new manifest together with the changes, or the given descriptor if there are none.
+func rewriteManifestConfig(ctx context.Context, fetcher content.Fetcher, pusher content.Pusher,
+	ref string, desc ocispec.Descriptor, reqs []oci.ArtifactRequirement) (ocispec.Descriptor, []RequirementChange, error) {
+	// Unknown fields of the manifest and of the config are preserved by decoding them as maps.
+	var manifest map[string]json.RawMessage
+	if err := fetchJSON(ctx, fetcher, desc, &manifest); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to fetch manifest of %q: %w", ref, err)
+	}
+	var configDesc ocispec.Descriptor
+	if err := json.Unmarshal(manifest["config"], &configDesc); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("invalid config descriptor in manifest of %q: %w", ref, err)
+	}
+
+	data, err := content.FetchAll(ctx, fetcher, configDesc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to fetch config of %q: %w", ref, err)
+	}
+	published, err := ValidateArtifactConfig(data, WithAllowUnknownRequirements())
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("config of %q: %w", ref, err)
+	}
+
+	changes := DiffRequirements(published, reqs)
+	if len(changes) == 0 {
+		return desc, nil, nil
+	}
+
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("config of %q: %w", ref, err)
+	}
+	sorted := slices.Clone(reqs)
+	SortRequirements(sorted)
+	if cfg["requirements"], err = json.Marshal(sorted); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to marshal requirements of %q: %w", ref, err)
+	}
+	newConfig, err := pushJSON(ctx, pusher, configDesc.MediaType, cfg)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+
+	if manifest["config"], err = json.Marshal(newConfig); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to marshal config descriptor of %q: %w", ref, err)
+	}
+	newDesc, err := pushJSON(ctx, pusher, desc.MediaType, manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	// Keep the platform and the annotations of the manifests referenced by an index.
+	newDesc.ArtifactType = desc.ArtifactType
+	newDesc.Platform = desc.Platform
+	newDesc.Annotations = desc.Annotations
+
+	return newDesc, changes, nil
+}
+
+// layersRequirements fetches the layers of the manifest with the given descriptor and re-derives the requirements
+// from the plugins and rulesfiles they bundle, with the given options.
+func layersRequirements(ctx context.Context, fetcher content.Fetcher, ref string, desc ocispec.Descriptor, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	var manifest ocispec.Manifest
+	if err := fetchJSON(ctx, fetcher, desc, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to fetch manifest of %q: %w", ref, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "registry-regenerate-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary dir while preparing to fetch the layers of %q: %w", ref, err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for i, layer := range manifest.Layers {
+		data, err := content.FetchAll(ctx, fetcher, layer)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch layer %q of %q: %w", layer.Digest, ref, err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("layer-%d%s", i, archive_suffix)), data, 0o600); err != nil {
+			return nil, fmt.Errorf("unable to write layer %q of %q: %w", layer.Digest, ref, err)
+		}
+	}
+
+	return PulledArtifactRequirements(tmpDir, opts...)
+}
+
+// discardPusher is a content.Pusher discarding the pushed content, to compute the regenerated configs in dry-run mode.
+type discardPusher struct{}
+
+// Push implements the content.Pusher interface.
+func (discardPusher) Push(_ context.Context, _ ocispec.Descriptor, r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+// PrintRegeneratedConfigs writes the regenerated configs as a table with a row for each artifact, listing the changes
+// of its requirements.
+func PrintRegeneratedConfigs(regenerated []RegeneratedConfig, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REFERENCE\tTAGS\tCHANGES\tNEW DIGEST")
+	for _, r := range regenerated {
+		changes := "none"
+		if len(r.Changes) > 0 {
+			var c []string
+			for _, change := range r.Changes {
+				c = append(c, fmt.Sprintf("%s %s (%q -> %q)", change.Name, change.Kind, change.OldVersion, change.NewVersion))
+			}
+			changes = strings.Join(c, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Ref, strings.Join(r.Tags, ","), changes, r.NewDigest)
+	}
+
+	return w.Flush()
+}
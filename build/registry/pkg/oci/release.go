@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// ArtifactRelease is what is needed to publish a release of the plugin, or of the rulesfiles, of a registry entry.
+type ArtifactRelease struct {
+	// Config is the config layer of the artifact, holding the requirements extracted from the archive and, for
+	// rulesfiles, their dependencies.
+	Config *oci.ArtifactConfig
+	// Tags are the tags the artifact is pushed with, see ReleaseTags.
+	Tags []string
+}
+
+// PrepareRelease given a registry entry, the version being released and the archive of the release, as downloaded
+// from the distribution, it extracts the requirements of the artifact and resolves the tags to push it with. If
+// rulesFile is true the archive holds the rulesfiles of the entry, which is then expected to declare a rules url,
+// otherwise the plugin built for the current platform. An error is returned if the plugin declares no requirement,
+// or if the rulesfiles declare neither requirements nor dependencies. The requirements are extracted with the given
+// options.
+func PrepareRelease(plugin *registry.Plugin, version *semver.Version, archivePath string, rulesFile bool, opts ...RequirementOption) (*ArtifactRelease, error) {
+	var cfg *oci.ArtifactConfig
+	var err error
+	if rulesFile {
+		if plugin.RulesURL == "" {
+			return nil, fmt.Errorf("registry entry %q does not declare rulesfiles", plugin.Name)
+		}
+		cfg, err = rulesfileConfig(rulesfileNameFromPlugin(plugin.Name), version.String(), archivePath, opts...)
+	} else {
+		cfg, err = pluginConfig(plugin.Name, version.String(), archivePath, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArtifactRelease{
+		Config: cfg,
+		Tags:   ReleaseTags(version),
+	}, nil
+}
+
+// ReleaseTags returns the tags a release with the given version is pushed with. A stable release is tagged with its
+// full version, e.g. "1.2.3", and with the floating tags "latest", "1" and "1.2", which move to each new stable
+// release. Since the releases are pushed in order, the floating tags point to the last stable release pushed. A
+// prerelease, e.g. "1.3.0-rc1", is only tagged with its full version, so that
+// it never becomes "latest" nor the target of the major and minor tags.
+func ReleaseTags(version *semver.Version) []string {
+	return tagsFromVersion(version)
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"path/filepath"
+
+	"github.com/blang/semver"
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Release", func() {
+	DescribeTable("should tag the releases",
+		func(version string, expected []string) {
+			v := semver.MustParse(version)
+			Expect(oci.ReleaseTags(&v)).To(Equal(expected))
+		},
+		// Stable releases move the floating tags.
+		Entry(nil, "0.7.0", []string{"latest", "0", "0.7", "0.7.0"}),
+		Entry(nil, "1.2.3", []string{"latest", "1", "1.2", "1.2.3"}),
+		// Prereleases are never latest.
+		Entry(nil, "1.3.0-rc1", []string{"1.3.0-rc1"}),
+	)
+
+	It("should prepare the config and the tags of a rulesfile release", func() {
+		archive := filepath.Join(GinkgoT().TempDir(), "k8saudit-rules-0.7.0.tar.gz")
+		writeTarGz(archive, map[string]string{
+			"k8s_audit_rules.yaml": "- required_engine_version: 0.31.0\n- required_plugin_versions:\n  - name: k8saudit\n    version: 0.7.0\n",
+		})
+		plugin := &registry.Plugin{Name: "k8saudit", RulesURL: "https://github.com/falcosecurity/plugins/tree/main/plugins/k8saudit/rules"}
+
+		version := semver.MustParse("0.7.0")
+		release, err := oci.PrepareRelease(plugin, &version, archive, true)
+		Expect(err).To(BeNil())
+		Expect(release.Config.Name).To(Equal("k8saudit-rules"))
+		Expect(release.Config.Version).To(Equal("0.7.0"))
+		Expect(release.Config.Requirements).To(Equal([]falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.31.0"}}))
+		Expect(release.Tags).To(Equal([]string{"latest", "0", "0.7", "0.7.0"}))
+
+		_, err = oci.PrepareRelease(&registry.Plugin{Name: "json"}, &version, archive, true)
+		Expect(err).ToNot(BeNil())
+	})
+})
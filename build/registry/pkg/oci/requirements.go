@@ -18,84 +18,2041 @@ limitations under the License.
 package oci
 
 import (
+	"archive/tar"
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/falcosecurity/falcoctl/pkg/oci"
-	"github.com/falcosecurity/plugin-sdk-go/pkg/loader"
 	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	rulesEngineAnchor = "- required_engine_version"
+	rulesPluginsKey = "required_plugin_versions"
+	// rulesPluginKey is the legacy, singular, spelling of rulesPluginsKey.
+	rulesPluginKey = "required_plugin_version"
+	// readerRulesfileName is the name used in errors for rulesfiles read from a reader.
+	readerRulesfileName = "<reader>"
 )
 
-// ErrReqNotFound error when the requirements are not found in the rulesfile.
-var ErrReqNotFound = errors.New("requirements not found")
+// RulesEngineKey is the key of the rulesfile items declaring the required engine version, as in
+// "- required_engine_version: 0.31.0". Forks using a different key in their rulesfiles can set it with
+// WithRulesEngineKey.
+const RulesEngineKey = "required_engine_version"
+
+// RulesEngineMinKey returns the key declaring the oldest engine version supported by a rulesfile, as in
+// "- required_engine_version_min: 0.31.0", as an alternative to RulesEngineKey. Together with the key returned by
+// RulesEngineMaxKey, declaring the newest supported version, it results in a range requirement, e.g.
+// ">=0.31.0 <=0.40.0". Both only cover the default RulesEngineKey: with a key set with WithRulesEngineKey the
+// extraction uses that key suffixed with "_min" and "_max" instead.
+func RulesEngineMinKey() string {
+	return engineMinKey(RulesEngineKey)
+}
+
+// RulesEngineMaxKey returns the key declaring the newest engine version supported by a rulesfile, see
+// RulesEngineMinKey. As that, it only covers the default RulesEngineKey.
+func RulesEngineMaxKey() string {
+	return engineMaxKey(RulesEngineKey)
+}
+
+// engineMinKey returns the key declaring the oldest supported engine version for the given engine requirement key.
+func engineMinKey(key string) string {
+	return key + "_min"
+}
+
+// engineMaxKey returns the key declaring the newest supported engine version for the given engine requirement key.
+func engineMaxKey(key string) string {
+	return key + "_max"
+}
+
+// SkipRequirementsMarker opts a rulesfile out of the requirements extraction, e.g. for templates. It must be the whole
+// first line of the rulesfile, only trailing whitespace being allowed, for example:
+//
+//	# falco-registry:skip-requirements
+//	- rule: template
+//
+// The content is then not decoded at all and an error wrapping ErrSkipped is returned.
+const SkipRequirementsMarker = "# falco-registry:skip-requirements"
+
+var (
+	// ErrReqNotFound error when the requirements are not found in the rulesfile.
+	ErrReqNotFound = errors.New("requirements not found")
+	// ErrOpenFailed error when the file the requirements are extracted from can not be opened or loaded.
+	ErrOpenFailed = errors.New("open failed")
+	// ErrParseFailed error when the content of the file, or the requirements it declares, can not be parsed.
+	ErrParseFailed = errors.New("parse failed")
+	// ErrUnreleasedEngineVersion warning when a rulesfile requires an engine version newer than any released one.
+	ErrUnreleasedEngineVersion = errors.New("engine version newer than the latest released one")
+	// ErrBareEngineVersion warning when a rulesfile declares its engine requirement as a bare number, e.g. "15",
+	// instead of a semver version, see BareVersionCoercion.
+	ErrBareEngineVersion = errors.New("bare numeric engine version")
+	// ErrRedundantEngineVersion warning when a rulesfile declares its engine requirement both with RulesEngineKey and
+	// with the min and max keys, see RulesEngineMinKey. The min and max keys are used.
+	ErrRedundantEngineVersion = errors.New("redundant engine version")
+	// ErrOverlay error when a rulesfile does not declare its requirements since it is an overlay, only appending to,
+	// or overriding, rules defined in other rulesfiles. It always comes together with ErrReqNotFound, hence overlays
+	// are skipped wherever missing requirements are, but callers can tell them apart from the actually missing ones.
+	ErrOverlay = errors.New("overlay rulesfile")
+	// ErrSkipped error when a rulesfile opts out of the requirements extraction with SkipRequirementsMarker. As
+	// ErrOverlay, it always comes together with ErrReqNotFound.
+	ErrSkipped = errors.New("requirements extraction skipped")
+	// ErrUnsupportedAPIVersion error when a plugin requires an api version not supported by the plugin loader.
+	ErrUnsupportedAPIVersion = errors.New("plugin api version not supported by the plugin loader")
+	// ErrFileTooLarge error when a rulesfile exceeds the maximum size, see WithMaxFileSize, or a compressed plugin
+	// exceeds the maximum size once decompressed, see WithMaxPluginSize. It always comes together with ErrOpenFailed.
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrMissingAPIVersion error when a plugin does not report the api version it requires. It always comes together
+	// with ErrReqNotFound.
+	ErrMissingAPIVersion = errors.New("plugin does not report the required api version")
+	// ErrConflictingRequirements error when requirements with the same name can not be merged, since their versions
+	// have different major versions or one of them is a range, see MergeRequirements.
+	ErrConflictingRequirements = errors.New("conflicting requirements")
+)
+
+// RequirementStage is the stage of the requirements extraction where an error occurred.
+type RequirementStage string
+
+const (
+	// StageOpen is the stage where the file is opened, or loaded in case of plugins.
+	StageOpen RequirementStage = "open"
+	// StageDecode is the stage where the content of the file is decoded.
+	StageDecode RequirementStage = "decode"
+	// StageParse is the stage where the declared requirements are parsed.
+	StageParse RequirementStage = "parse"
+	// StageLookup is the stage where the requirements are looked up in the decoded content.
+	StageLookup RequirementStage = "lookup"
+)
+
+// RequirementError is returned when the requirements can not be extracted from a file. It wraps one of
+// ErrOpenFailed, ErrParseFailed and ErrReqNotFound, together with the underlying error, if any.
+type RequirementError struct {
+	// FilePath is the file the requirements were extracted from.
+	FilePath string
+	// Stage is the stage of the extraction that failed.
+	Stage RequirementStage
+	// Err is the error occurred.
+	Err error
+}
+
+// Error returns the message of the wrapped error.
+func (e *RequirementError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *RequirementError) Unwrap() error {
+	return e.Err
+}
+
+// newRequirementError returns a RequirementError for the given file and stage.
+func newRequirementError(filePath string, stage RequirementStage, err error) error {
+	return &RequirementError{
+		FilePath: filePath,
+		Stage:    stage,
+		Err:      err,
+	}
+}
+
+// BareVersionCoercion is how an engine requirement expressed as a bare number, e.g. "15", is converted to semver.
+type BareVersionCoercion int
+
+const (
+	// CoerceToMinor converts a bare number N to 0.N.0, since the engine versions released before the adoption of
+	// semver correspond to the minor versions of the 0 major. This is the default.
+	CoerceToMinor BareVersionCoercion = iota
+	// CoerceToMajor converts a bare number N to N.0.0, for older rulesfiles using bare numbers as schema versions.
+	CoerceToMajor
+)
+
+// RequirementOption is a functional option for the extraction of the engine requirement of rulesfiles, and of the
+// plugin api version required by plugins.
+type RequirementOption func(*requirementOptions)
+
+type requirementOptions struct {
+	coercion          BareVersionCoercion
+	policy            RequirementPolicy
+	keepBuildMetadata bool
+	overlay           bool
+	maxFileSize       int64
+	maxLineLength     int
+	// prereleasePermissive marks the requirements coerced from bare numbers as satisfied by the prereleases too.
+	prereleasePermissive bool
+	// stripAPIPrerelease removes the prerelease from the plugin api version required by plugins.
+	stripAPIPrerelease bool
+	// expectedDigest is the sha256 digest plugins are verified against before being loaded, if not empty.
+	expectedDigest string
+	// pluginSidecar reads the api version required by plugins shipped as static archives from their sidecar.
+	pluginSidecar bool
+	// strictKeys rejects the rulesfiles having items with unknown keys, extraKeys being allowed too.
+	strictKeys bool
+	extraKeys  []string
+	// strictNames rejects the extracted requirements whose name is not known.
+	strictNames bool
+	// strictAPIVersion rejects the plugins requiring an api version not supported by the plugin loader.
+	strictAPIVersion bool
+	// engineKey is the key of the items declaring the engine requirement, RulesEngineKey by default.
+	engineKey string
+	// pluginTempDir is the directory compressed plugins are decompressed to, the default one if empty.
+	pluginTempDir string
+	// maxPluginSize is the size limit of compressed plugins once decompressed, no limit if zero or negative.
+	maxPluginSize int64
+	// allowUnknownNames accepts the extracted requirements whose name is not known.
+	allowUnknownNames bool
+	// logger is the logger of the extraction, see WithLogger.
+	logger *slog.Logger
+	// warnings is the collector of the warnings raised by the extraction, see WithWarningCollector.
+	warnings *WarningCollector
+}
+
+const (
+	// defaultMaxFileSize is the default size limit of rulesfiles, way bigger than any legitimate rulesfile.
+	defaultMaxFileSize = 64 << 20
+	// defaultMaxLineLength is the default length limit of the lines of rulesfiles.
+	defaultMaxLineLength = bufio.MaxScanTokenSize
+	// defaultMaxPluginSize is the default size limit of compressed plugins once decompressed, way bigger than any
+	// legitimate plugin.
+	defaultMaxPluginSize = 1 << 30
+)
+
+// WithMaxFileSize sets the maximum size in bytes of the rulesfiles, after decompression, so that pointing the
+// extraction at a huge or binary file by mistake fails fast with an error wrapping ErrFileTooLarge. The default is
+// 64MiB, zero or a negative size disables the limit.
+func WithMaxFileSize(size int64) RequirementOption {
+	return func(o *requirementOptions) {
+		o.maxFileSize = size
+	}
+}
+
+// WithMaxPluginSize sets the maximum size in bytes of the plugins compressed with gzip, once decompressed to a
+// temporary file, so that a crafted archive expanding to a huge file fails with an error wrapping ErrFileTooLarge
+// instead of filling the disk. The default is 1GiB, zero or a negative size disables the limit.
+func WithMaxPluginSize(size int64) RequirementOption {
+	return func(o *requirementOptions) {
+		o.maxPluginSize = size
+	}
+}
+
+// WithMaxLineLength sets the maximum length in bytes of the lines scanned when the engine requirement is not found,
+// for rulesfiles with legitimately long lines. The default is 64KiB, which is also used for zero or a negative length.
+func WithMaxLineLength(length int) RequirementOption {
+	return func(o *requirementOptions) {
+		if length <= 0 {
+			length = defaultMaxLineLength
+		}
+		o.maxLineLength = length
+	}
+}
+
+// WithOverlay marks the rulesfile as an overlay: if it does not declare its requirements, an error wrapping ErrOverlay
+// is returned even if it is not recognized as such, e.g. because it defines new objects too.
+func WithOverlay() RequirementOption {
+	return func(o *requirementOptions) {
+		o.overlay = true
+	}
+}
+
+// WithKeepBuildMetadata accepts engine requirements with build metadata, e.g. "0.31.0+build123", keeping it in
+// the requirement. By default they are rejected.
+func WithKeepBuildMetadata() RequirementOption {
+	return func(o *requirementOptions) {
+		o.keepBuildMetadata = true
+	}
+}
+
+// WithPrereleasePermissive expresses the engine requirements coerced from bare numbers, or from major.minor versions,
+// as the lowest prerelease of the coerced version, e.g. "0.15.0-0" instead of "0.15.0", so that they are satisfied by the prereleases of that version
+// too, such as "0.15.0-rc1". By default the coerced requirements are not satisfied by any prerelease.
+func WithPrereleasePermissive() RequirementOption {
+	return func(o *requirementOptions) {
+		o.prereleasePermissive = true
+	}
+}
+
+// WithStripAPIPrerelease removes the prerelease from the plugin api version required by plugins built against a
+// release candidate of the SDK, e.g. "3.0.0-rc1" becomes "3.0.0", for stable releases not to be satisfied by the
+// prereleases of the api. By default the version is kept as reported by the plugin. It has no effect on rulesfiles.
+func WithStripAPIPrerelease() RequirementOption {
+	return func(o *requirementOptions) {
+		o.stripAPIPrerelease = true
+	}
+}
+
+// WithRulesEngineKey sets the key of the rulesfile items declaring the required engine version, for forks using a
+// different key than RulesEngineKey. The keys declaring the bounds of the supported engine versions follow it, e.g.
+// "required_fork_version_min" for "required_fork_version", see RulesEngineMinKey. It has no effect on plugins.
+func WithRulesEngineKey(key string) RequirementOption {
+	return func(o *requirementOptions) {
+		o.engineKey = key
+	}
+}
+
+// WithPluginTempDir sets the directory where compressed plugins are decompressed before being loaded, since shared
+// libraries can only be loaded from the filesystem. The default directory for temporary files is used if empty, which
+// is the default. It has no effect on rulesfiles.
+func WithPluginTempDir(dir string) RequirementOption {
+	return func(o *requirementOptions) {
+		o.pluginTempDir = dir
+	}
+}
+
+// WithStrictAPIVersion makes the extraction fail for plugins requiring an api version not supported by the plugin
+// loader of this tool, instead of only warning about it. Loading such plugins may succeed, but their info is not
+// reliable. It has no effect on rulesfiles.
+func WithStrictAPIVersion() RequirementOption {
+	return func(o *requirementOptions) {
+		o.strictAPIVersion = true
+	}
+}
+
+// RequirementPolicy is how the engine requirement is chosen when a rulesfile declares more than one.
+type RequirementPolicy int
+
+const (
+	// PolicyFirstWins chooses the first requirement declared in the rulesfile. This is the default.
+	PolicyFirstWins RequirementPolicy = iota
+	// PolicyMax chooses the highest requirement, failing if they do not agree on the major version.
+	PolicyMax
+	// PolicyError fails if more than one requirement is declared.
+	PolicyError
+)
+
+// WithRequirementPolicy sets how the engine requirement is chosen when a rulesfile declares more than one. The default
+// is PolicyFirstWins, the first declared requirement being the authoritative one.
+func WithRequirementPolicy(policy RequirementPolicy) RequirementOption {
+	return func(o *requirementOptions) {
+		o.policy = policy
+	}
+}
+
+// WithBareVersionCoercion sets how engine requirements expressed as bare numbers are converted to semver.
+func WithBareVersionCoercion(coercion BareVersionCoercion) RequirementOption {
+	return func(o *requirementOptions) {
+		o.coercion = coercion
+	}
+}
+
+// newRequirementOptions returns the requirementOptions resulting from applying opts to the defaults.
+func newRequirementOptions(opts []RequirementOption) *requirementOptions {
+	o := &requirementOptions{
+		coercion:      CoerceToMinor,
+		policy:        PolicyFirstWins,
+		maxFileSize:   defaultMaxFileSize,
+		maxLineLength: defaultMaxLineLength,
+		engineKey:     RulesEngineKey,
+		maxPluginSize: defaultMaxPluginSize,
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	return o
+}
+
+// gzipMagic are the leading bytes of gzip compressed files.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// rulesfileItem represents an item of the list contained in a rulesfile. Only the fields
+// of interest for the requirements extraction are decoded.
+type rulesfileItem struct {
+	RequiredPluginVersions []oci.ArtifactDependency
+	// Object is true for the items defining a rule, a macro or a list.
+	Object bool
+	// Appends is true for the objects appending to, or overriding, an object defined in another rulesfile.
+	Appends bool
+	// Keys are the keys of the item, including the merged ones, checked in strict mode, see WithStrictKeys.
+	Keys []*yaml.Node
+	// Values are the values of Keys, aliases resolved. The engine requirement is looked up among them, see value,
+	// since its key is only known when extracting it, see WithRulesEngineKey.
+	Values []*yaml.Node
+}
+
+// value returns the value of the given key of the item, the last one if the key is repeated, e.g. by a merge. The
+// version could be expressed both as a number or as a string, hence it is kept as a node. Its line is the one of
+// the key, for errors to be reported there rather than at the anchor of an alias. It returns nil if the item has no
+// such key.
+func (i *rulesfileItem) value(key string) *yaml.Node {
+	for k := len(i.Keys) - 1; k >= 0; k-- {
+		if i.Keys[k].Value == key {
+			node := *i.Values[k]
+			node.Line = i.Keys[k].Line
+			return &node
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. It is needed to handle the merge keys and the legacy
+// spelling of the required plugins, and to keep all the values since the key of the engine requirement is not known
+// in advance, see WithRulesEngineKey.
+func (i *rulesfileItem) UnmarshalYAML(value *yaml.Node) error {
+	// Items that are not dictionaries do not declare any requirement.
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for k := 0; k+1 < len(value.Content); k += 2 {
+		key, val := value.Content[k], resolveAlias(value.Content[k+1])
+		// Merge keys, as in "<<: *base", add the keys of the merged mappings to the item.
+		if key.Tag == "!!merge" {
+			merged := []*yaml.Node{val}
+			if val.Kind == yaml.SequenceNode {
+				merged = val.Content
+			}
+			for _, m := range merged {
+				if err := i.UnmarshalYAML(resolveAlias(m)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		i.Keys = append(i.Keys, key)
+		i.Values = append(i.Values, val)
+		switch key.Value {
+		case rulesPluginsKey, rulesPluginKey:
+			var deps []oci.ArtifactDependency
+			// Legacy rulesfiles could declare a single plugin without wrapping it in a list.
+			if val.Kind == yaml.MappingNode {
+				deps = make([]oci.ArtifactDependency, 1)
+				if err := val.Decode(&deps[0]); err != nil {
+					return err
+				}
+			} else if err := val.Decode(&deps); err != nil {
+				return err
+			}
+			i.RequiredPluginVersions = append(i.RequiredPluginVersions, deps...)
+		case "rule", "macro", "list":
+			i.Object = true
+		case "append":
+			// Malformed values are left to Falco to report, they do not affect the requirements.
+			_ = val.Decode(&i.Appends)
+		case "override":
+			i.Appends = true
+		}
+	}
+
+	return nil
+}
+
+// resolveAlias returns the node anchored by the given alias, or the given node itself if it is not an alias.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	return node
+}
+
+// isOverlay returns true if the given items only append to, or override, objects defined in other rulesfiles.
+// Such rulesfiles are meant to be loaded on top of others, hence they do not need to declare their requirements.
+func isOverlay(items []rulesfileItem) bool {
+	var objects int
+	for _, item := range items {
+		if !item.Object {
+			continue
+		}
+		if !item.Appends {
+			return false
+		}
+		objects++
+	}
+
+	return objects > 0
+}
+
+// rulesfileReadCloser reads the, possibly decompressed, content of a rulesfile and closes the underlying file.
+type rulesfileReadCloser struct {
+	io.Reader
+	file *os.File
+}
+
+// Close closes the underlying file.
+func (r *rulesfileReadCloser) Close() error {
+	return r.file.Close()
+}
+
+// contextReader is a reader failing with the context error once the context is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader, unless the context is canceled.
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// errRecordingReader is a reader keeping the first error, other than io.EOF, returned by the underlying reader.
+type errRecordingReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read reads from the underlying reader, recording its error.
+func (r *errRecordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// sizeLimitReader is a reader failing with an error wrapping ErrFileTooLarge once more than the given number of
+// bytes have been read. Unlike io.LimitReader, exceeding the limit is an error rather than the end of the file.
+type sizeLimitReader struct {
+	name      string
+	r         io.Reader
+	remaining int64
+	// tooLarge returns the error for the file exceeding the limit, fileTooLargeError if nil.
+	tooLarge func(name string) error
+}
+
+// Read reads from the underlying reader, up to one byte past the limit to detect that it has been exceeded.
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		if r.tooLarge != nil {
+			return n, r.tooLarge(r.name)
+		}
+		return n, fileTooLargeError(r.name)
+	}
+	return n, err
+}
+
+// fileTooLargeError returns an error wrapping both ErrOpenFailed and ErrFileTooLarge for the given rulesfile.
+func fileTooLargeError(name string) error {
+	return newRequirementError(name, StageOpen, fmt.Errorf("rulesfile %q exceeds the maximum size: %w: %w", name, ErrOpenFailed, ErrFileTooLarge))
+}
+
+// pluginTooLargeError returns an error wrapping both ErrOpenFailed and ErrFileTooLarge for the given compressed plugin.
+func pluginTooLargeError(name string) error {
+	return newRequirementError(name, StageOpen, fmt.Errorf("plugin %q exceeds the maximum size once decompressed: %w: %w", name, ErrOpenFailed, ErrFileTooLarge))
+}
+
+// openRulesfile opens a rulesfile for reading. Gzip compressed rulesfiles are detected by their magic bytes,
+// regardless of the file extension, and transparently decompressed. Reading more than maxSize bytes fails, unless
+// maxSize is zero or negative.
+func openRulesfile(filePath string, maxSize int64) (io.ReadCloser, error) {
+	return openRulesfileContext(context.Background(), filePath, maxSize)
+}
+
+// openRulesfileContext is the same as openRulesfile, but reading the rulesfile fails once the context is canceled.
+func openRulesfileContext(ctx context.Context, filePath string, maxSize int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("requirements for rulesfile %q: %w", filePath, err))
+	}
 
-// rulesfileRequirement given a rulesfile in yaml format it scans it and extracts its requirements.
-func rulesfileRequirement(filePath string) (*oci.ArtifactRequirement, error) {
-	var requirement string
-	// Open the file.
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open file %q: %v", filePath, file)
+		return nil, openFileError(filePath, err)
+	}
+
+	// Fail fast for files already exceeding the limit before being decompressed.
+	if info, err := file.Stat(); err == nil && maxSize > 0 && info.Size() > maxSize {
+		file.Close()
+		return nil, fileTooLargeError(filePath)
+	}
+
+	reader, err := newRulesfileReader(filePath, &contextReader{ctx: ctx, r: file}, maxSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &rulesfileReadCloser{Reader: reader, file: file}, nil
+}
+
+// rulesfileOpener opens the, possibly decompressed, content of a rulesfile for reading. It can be called more than
+// once, each time reading the content from its beginning.
+type rulesfileOpener func() (io.ReadCloser, error)
+
+// bufferedRulesfileOpener returns a rulesfileOpener for a rulesfile read from the given reader, e.g. the body of an
+// http response. The content is read, and kept in memory, the first time it is opened, since a reader can only be
+// read once. The name of the rulesfile is only used for error reporting.
+func bufferedRulesfileOpener(name string, r io.Reader, maxSize int64) rulesfileOpener {
+	var data []byte
+	var read bool
+	return func() (io.ReadCloser, error) {
+		if !read {
+			reader, err := newRulesfileReader(name, r, maxSize)
+			if err != nil {
+				return nil, err
+			}
+			data, err = io.ReadAll(reader)
+			if errors.Is(err, ErrFileTooLarge) {
+				return nil, err
+			}
+			if err != nil {
+				return nil, newRequirementError(name, StageOpen, fmt.Errorf("unable to read rulesfile %q: %w: %w", name, ErrOpenFailed, err))
+			}
+			read = true
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// newRulesfileReader given the content of a rulesfile it returns a reader of its, possibly decompressed, content.
+// The name of the rulesfile is only used for error reporting. Reading more than maxSize bytes of decompressed content
+// fails, unless maxSize is zero or negative.
+func newRulesfileReader(name string, r io.Reader, maxSize int64) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+
+	limit := func(r io.Reader) io.Reader {
+		if maxSize <= 0 {
+			return r
+		}
+		return &sizeLimitReader{name: name, r: r, remaining: maxSize}
+	}
+
+	// Files shorter than the magic bytes are read as plain files.
+	magic, err := reader.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		return limit(reader), nil
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, newRequirementError(name, StageOpen, fmt.Errorf("unable to decompress file %q: %w: %w", name, ErrOpenFailed, err))
+	}
+
+	return limit(gzipReader), nil
+}
+
+// decodeRulesfile given a rulesfile in yaml format it decodes the list of items it contains. Rulesfiles
+// split in multiple yaml documents are supported, the items of all the documents are returned in order.
+// Rulesfiles in json format, detected by their first non-whitespace byte, are decoded as well.
+func decodeRulesfile(filePath string, maxSize int64) ([]rulesfileItem, error) {
+	// Open the file.
+	file, err := openRulesfile(filePath, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return decodeRulesfileReader(filePath, file)
+}
+
+// decodeRulesfileReader is the same as decodeRulesfile, but the already decompressed content of the rulesfile
+// is read from the given reader. The name of the rulesfile is only used for error reporting.
+func decodeRulesfileReader(name string, r io.Reader) ([]rulesfileItem, error) {
+	docs, err := decodeRulesfileDocuments(name, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []rulesfileItem
+	for _, doc := range docs {
+		var docItems []rulesfileItem
+		if err := doc.Decode(&docItems); err != nil {
+			return nil, newRequirementError(name, StageDecode, fmt.Errorf("unable to unmarshal rulesfile %q: %w: %w", name, ErrParseFailed, err))
+		}
+		items = append(items, docItems...)
+	}
+
+	return items, nil
+}
+
+// decodeRulesfileDocuments given the already decompressed content of a rulesfile it decodes the yaml documents
+// it contains as nodes, keeping the position of each value and the comments. The content of rulesfiles in json
+// format is decoded as a single document. The name of the rulesfile is only used for error reporting.
+func decodeRulesfileDocuments(name string, r io.Reader) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+
+	// The decoders do not wrap the errors of the reader, keep them to report them as such.
+	reader := &errRecordingReader{r: r}
+	decodeError := func(err error) error {
+		var reqErr *RequirementError
+		if errors.As(reader.err, &reqErr) {
+			return reader.err
+		}
+		if reader.err != nil {
+			return newRequirementError(name, StageOpen, fmt.Errorf("unable to read rulesfile %q: %w: %w", name, ErrOpenFailed, reader.err))
+		}
+		return newRequirementError(name, StageDecode, fmt.Errorf("unable to unmarshal rulesfile %q: %w: %w", name, ErrParseFailed, err))
+	}
+
+	buffered := bufio.NewReader(reader)
+	if isJSONContent(buffered) {
+		data, err := io.ReadAll(buffered)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		if doc, err := decodeJSONDocument(data); err == nil {
+			return append(docs, doc), nil
+		}
+		// Yaml rulesfiles could be written in flow style too, decode the content as yaml before giving up.
+		buffered = bufio.NewReader(bytes.NewReader(data))
+	}
+
+	decoder := yaml.NewDecoder(buffered)
+	for {
+		var doc yaml.Node
+		// An empty file is a valid rulesfile without items.
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, decodeError(err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	return docs, nil
+}
+
+// engineRequirement is an engine requirement extracted from a rulesfile.
+type engineRequirement struct {
+	oci.ArtifactRequirement
+	// Declared is the version as written in the rulesfile, before being normalized to semver.
+	Declared string
+	// Line is the line of the rulesfile the version is declared at.
+	Line int
+	// Semver is the normalized version parsed as semver, nil for ranges.
+	Semver *semver.Version
+}
+
+// rulesfileRequirements given a rulesfile in yaml format it decodes it and extracts all its engine requirements.
+// Rulesfiles concatenated from multiple sources could declare more than one requirement, each one is returned
+// in the same order as it appears in the file.
+func rulesfileRequirements(filePath string, opts ...RequirementOption) ([]engineRequirement, error) {
+	o := newRequirementOptions(opts)
+	return openedRulesfileRequirements(filePath, func() (io.ReadCloser, error) {
+		return openRulesfile(filePath, o.maxFileSize)
+	}, o)
+}
+
+// openedRulesfileRequirements is the same as rulesfileRequirements, but the rulesfile is opened with the given
+// opener, once to decode it and once more to report near misses when no requirement is found. All the ways of
+// reading a rulesfile, e.g. from a path, a reader or an archive, share it. The name of the rulesfile is only used
+// for error reporting.
+func openedRulesfileRequirements(name string, open rulesfileOpener, o *requirementOptions) ([]engineRequirement, error) {
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if hasSkipMarker(reader) {
+		return nil, skippedError(name)
+	}
+
+	items, err := decodeRulesfileReader(name, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements, err := itemsEngineRequirements(name, items, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requirements) == 0 && (o.overlay || isOverlay(items)) {
+		return nil, overlayError(name)
+	}
+
+	if len(requirements) == 0 {
+		file, err := open()
+		if err != nil {
+			return nil, newRequirementError(name, StageLookup, fmt.Errorf("requirements for rulesfile %q: %w", name, ErrReqNotFound))
+		}
+		defer file.Close()
+
+		return nil, reqNotFoundError(name, file, o.engineKey, o.maxLineLength)
+	}
+
+	return requirements, nil
+}
+
+// itemsEngineRequirements given the decoded items of a rulesfile it extracts all the engine requirements they
+// declare, in order. In strict mode the keys of the items are checked first, see WithStrictKeys. The name of the
+// rulesfile is only used for error reporting.
+func itemsEngineRequirements(name string, items []rulesfileItem, o *requirementOptions) ([]engineRequirement, error) {
+	if o.strictKeys {
+		if err := checkRulesfileKeys(name, items, o.engineKey, o.extraKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	var requirements []engineRequirement
+	// The bounds are usually declared in different items, as in "- required_engine_version_min: 0.31.0" followed by
+	// "- required_engine_version_max: 0.40.0", and are combined in a single requirement.
+	var minNode, maxNode *yaml.Node
+
+	for i := range items {
+		for _, bound := range []struct {
+			key  string
+			dest **yaml.Node
+		}{
+			{engineMinKey(o.engineKey), &minNode},
+			{engineMaxKey(o.engineKey), &maxNode},
+		} {
+			node := items[i].value(bound.key)
+			if node == nil {
+				continue
+			}
+			if *bound.dest != nil {
+				return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %s already declared at line %d: %w",
+					name, node.Line, bound.key, (*bound.dest).Line, ErrParseFailed))
+			}
+			*bound.dest = node
+		}
+
+		// Skip the items that do not declare the engine version.
+		node := items[i].value(o.engineKey)
+		if node == nil {
+			continue
+		}
+
+		// A key without a value, e.g. "- required_engine_version:", is decoded as an empty scalar.
+		if node.Kind != yaml.ScalarNode || strings.TrimSpace(node.Value) == "" {
+			return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %s has no value: %w",
+				name, node.Line, o.engineKey, ErrParseFailed))
+		}
+
+		version, err := normalizeEngineRequirement(node.Value, o)
+		if err != nil {
+			return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %w", name, node.Line, err))
+		}
+		if isBareEngineRequirement(node.Value) {
+			o.warn(name, fmt.Errorf("rulesfile %q, line %d: engine version %q coerced to %q: %w",
+				name, node.Line, node.Value, version, ErrBareEngineVersion))
+		}
+
+		var reqVer *semver.Version
+		if !isVersionRange(version) {
+			parsed, err := semver.Parse(version)
+			if err != nil {
+				return nil, newRequirementError(name, StageParse, fmt.Errorf("unable to parse requirement %q: %w: %w", version, ErrParseFailed, err))
+			}
+			reqVer = &parsed
+		}
+
+		requirements = append(requirements, engineRequirement{
+			ArtifactRequirement: oci.ArtifactRequirement{
+				Name:    common.EngineVersionKey,
+				Version: version,
+			},
+			Declared: node.Value,
+			Line:     node.Line,
+			Semver:   reqVer,
+		})
+	}
+
+	// The explicit bounds are preferred over the single version.
+	if minNode != nil || maxNode != nil {
+		bounded, err := boundedEngineRequirement(name, minNode, maxNode, o)
+		if err != nil {
+			return nil, err
+		}
+		if len(requirements) > 0 {
+			err := fmt.Errorf("rulesfile %q, line %d: %s is ignored since %s and %s are declared: %w",
+				name, requirements[0].Line, o.engineKey, engineMinKey(o.engineKey), engineMaxKey(o.engineKey), ErrRedundantEngineVersion)
+			o.log().Warn("redundant engine version", "file", name, "error", err)
+			o.warn(name, err)
+		}
+		return []engineRequirement{*bounded}, nil
+	}
+
+	return requirements, nil
+}
+
+// boundedEngineRequirement given the min and max engine versions declared by a rulesfile, see RulesEngineMinKey, it
+// returns the range of engine versions it requires. One of the bounds can be nil if not declared. Each bound must be
+// a single version, normalized as the one declared with the engine requirement key.
+func boundedEngineRequirement(name string, minNode, maxNode *yaml.Node, o *requirementOptions) (*engineRequirement, error) {
+	var constraints []string
+	line := 0
+	for _, bound := range []struct {
+		key      string
+		node     *yaml.Node
+		operator string
+	}{
+		{engineMinKey(o.engineKey), minNode, ">="},
+		{engineMaxKey(o.engineKey), maxNode, "<="},
+	} {
+		if bound.node == nil {
+			continue
+		}
+		if line == 0 {
+			line = bound.node.Line
+		}
+		if bound.node.Kind != yaml.ScalarNode || strings.TrimSpace(bound.node.Value) == "" {
+			return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %s has no value: %w",
+				name, bound.node.Line, bound.key, ErrParseFailed))
+		}
+		if isVersionRange(bound.node.Value) {
+			return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %s must be a single version, got %q: %w",
+				name, bound.node.Line, bound.key, bound.node.Value, ErrParseFailed))
+		}
+
+		version, err := normalizeEngineRequirement(bound.node.Value, o)
+		if err != nil {
+			return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %w", name, bound.node.Line, err))
+		}
+		if isBareEngineRequirement(bound.node.Value) {
+			o.warn(name, fmt.Errorf("rulesfile %q, line %d: engine version %q coerced to %q: %w",
+				name, bound.node.Line, bound.node.Value, version, ErrBareEngineVersion))
+		}
+		constraints = append(constraints, bound.operator+version)
+	}
+
+	version := strings.Join(constraints, " ")
+	if _, err := semver.ParseRange(version); err != nil {
+		return nil, newRequirementError(name, StageParse, fmt.Errorf("unable to parse requirement range %q: %w: %w", version, ErrParseFailed, err))
+	}
+	if err := checkSatisfiableRange(version); err != nil {
+		return nil, newRequirementError(name, StageParse, fmt.Errorf("rulesfile %q, line %d: %w", name, line, err))
+	}
+
+	return &engineRequirement{
+		ArtifactRequirement: oci.ArtifactRequirement{
+			Name:    common.EngineVersionKey,
+			Version: version,
+		},
+		Declared: version,
+		Line:     line,
+	}, nil
+}
+
+// hasSkipMarker returns true if the first line of the content read by r is SkipRequirementsMarker, without consuming
+// it.
+func hasSkipMarker(r *bufio.Reader) bool {
+	// Peek returns the available bytes when the content is shorter than the buffer.
+	data, _ := r.Peek(r.Size())
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+
+	return string(bytes.TrimRight(line, " \t\r")) == SkipRequirementsMarker
+}
+
+// skippedError returns an error wrapping both ErrSkipped and ErrReqNotFound for the given rulesfile.
+func skippedError(filePath string) error {
+	return newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for rulesfile %q: %s: %w: %w", filePath, SkipRequirementsMarker, ErrSkipped, ErrReqNotFound))
+}
+
+// overlayError returns an error wrapping both ErrOverlay and ErrReqNotFound for the given rulesfile.
+func overlayError(filePath string) error {
+	return newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for rulesfile %q: overlay rulesfile: %w: %w", filePath, ErrOverlay, ErrReqNotFound))
+}
+
+// reqNotFoundError returns an error wrapping ErrReqNotFound for the given rulesfile. It scans the content
+// line by line and reports the number of lines scanned and, if any, the first line mentioning the engine
+// requirement, with the given key, that has not been recognized as such, e.g. because of a wrong indentation or a
+// missing "- ".
+func reqNotFoundError(filePath string, r io.Reader, engineKey string, maxLineLength int) error {
+	var lines, nearMissLine int
+	var nearMiss string
+
+	fileScanner := bufio.NewScanner(r)
+	fileScanner.Split(bufio.ScanLines)
+	fileScanner.Buffer(make([]byte, 0, min(maxLineLength, defaultMaxLineLength)), maxLineLength)
+
+	// The lines are checked as they are in the buffer of the scanner, only the near miss is copied to be reported.
+	key := []byte(engineKey)
+	for fileScanner.Scan() {
+		lines++
+		if nearMiss != "" {
+			continue
+		}
+		if line := fileScanner.Bytes(); bytes.Contains(line, key) {
+			nearMiss = string(line)
+			nearMissLine = lines
+		}
+	}
+
+	// Lines longer than the limit stop the scan, the lines scanned so far are still reported.
+	if err := fileScanner.Err(); err != nil {
+		return newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for rulesfile %q (%d lines scanned, scan stopped at line %d: %v): %w",
+			filePath, lines, lines+1, err, ErrReqNotFound))
+	}
+
+	if nearMiss != "" {
+		return newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for rulesfile %q (%d lines scanned, near miss at line %d: %q): %w",
+			filePath, lines, nearMissLine, nearMiss, ErrReqNotFound))
+	}
+
+	return newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for rulesfile %q (%d lines scanned): %w", filePath, lines, ErrReqNotFound))
+}
+
+// RulesfileRequirement given a rulesfile in yaml format it extracts the engine version it requires, see
+// RequirementOption for how multiple or bare version requirements are handled. Errors are *RequirementError
+// values wrapping ErrOpenFailed, ErrParseFailed or ErrReqNotFound.
+//
+// RulesfileRequirement, PluginRequirement and the errors they return are meant to be used by other tools: their
+// signatures, the name of the returned requirements and the wrapped sentinel errors are kept backward compatible.
+func RulesfileRequirement(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return rulesfileRequirement(filePath, opts...)
+}
+
+// RulesfileRequirementVersion is the same as RulesfileRequirement, but it also returns the required version already
+// parsed as semver, so that callers comparing many requirements do not parse their versions again. The returned
+// version is nil if the rulesfile requires a range of versions.
+func RulesfileRequirementVersion(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, *semver.Version, error) {
+	return rulesfileRequirementVersion(filePath, opts...)
+}
+
+// PluginRequirement given a plugin as a shared library it loads it and extracts the plugin api version it requires.
+// Errors are *RequirementError values wrapping ErrOpenFailed. See RulesfileRequirement for the stability guarantees.
+// The only options having an effect are WithStripAPIPrerelease, WithExpectedDigest, WithPluginSidecar,
+// WithStrictRequirementNames, WithStrictAPIVersion, WithPluginTempDir and WithMaxPluginSize.
+func PluginRequirement(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return pluginRequirement(filePath, opts...)
+}
+
+// PluginRequirementFromFile is the same as PluginRequirement, but the plugin is read from the given open file, e.g. a
+// memfd, that may have no path on the filesystem. The name of the file is used in the errors. See
+// RulesfileRequirement for the stability guarantees.
+func PluginRequirementFromFile(file *os.File, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return pluginRequirementFromFile(file, opts...)
+}
+
+// rulesfileRequirement given a rulesfile in yaml format it decodes it and extracts its requirements.
+// If multiple requirements are declared, the one selected by the policy set with WithRequirementPolicy is
+// returned, the first one by default. With PolicyMax the highest (most restrictive) one is returned, and an
+// error if the requirements do not agree on the major version. The extraction is reported to the
+// hook set with SetRequirementHook.
+func rulesfileRequirement(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	req, _, err := rulesfileRequirementVersion(filePath, opts...)
+	return req, err
+}
+
+// rulesfileRequirementVersion is the same as rulesfileRequirement, but it also returns the required version parsed
+// as semver, nil for ranges.
+func rulesfileRequirementVersion(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, *semver.Version, error) {
+	return rulesfileRequirementVersionContext(context.Background(), filePath, opts...)
+}
+
+// rulesfileRequirementVersionContext is the same as rulesfileRequirementVersion, but reading the rulesfile is
+// aborted if the context is canceled.
+func rulesfileRequirementVersionContext(ctx context.Context, filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, *semver.Version, error) {
+	o := newRequirementOptions(opts)
+	return openedRulesfileRequirement(filePath, func() (io.ReadCloser, error) {
+		return openRulesfileContext(ctx, filePath, o.maxFileSize)
+	}, opts)
+}
+
+// openedRulesfileRequirement is the same as rulesfileRequirementVersion, but the rulesfile is opened with the given
+// opener, see openedRulesfileRequirements. The extraction is reported to the hook set with SetRequirementHook, and
+// the name of the requirement checked if WithStrictRequirementNames is set, whatever the rulesfile is read from.
+func openedRulesfileRequirement(name string, open rulesfileOpener, opts []RequirementOption) (req *oci.ArtifactRequirement, reqVer *semver.Version, err error) {
+	defer observeRequirement(name, time.Now(), &err)
+	defer strictRequirementName(name, opts, &req, &err)
+
+	o := newRequirementOptions(opts)
+	requirements, err := openedRulesfileRequirements(name, open, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resolveEngineRequirement(name, requirements, o)
+}
+
+// rulesfileRequirementFromReader is the same as rulesfileRequirement, but the rulesfile is read from the given
+// reader, e.g. the body of an http response. Gzip compressed content is transparently decompressed.
+func rulesfileRequirementFromReader(r io.Reader, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return readRulesfileRequirement(readerRulesfileName, r, opts)
+}
+
+// rulesfileRequirementContext is the same as rulesfileRequirement, but reading the rulesfile is aborted if the
+// context is canceled.
+func rulesfileRequirementContext(ctx context.Context, filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	req, _, err := rulesfileRequirementVersionContext(ctx, filePath, opts...)
+	return req, err
+}
+
+// rulesfileRequirementFromURL is the same as rulesfileRequirement, but the rulesfile is downloaded from the given
+// http(s) url. The whole request, including reading the body, must complete within the given timeout.
+func rulesfileRequirementFromURL(url string, timeout time.Duration, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return rulesfileRequirementFromURLContext(context.Background(), url, timeout, opts...)
+}
+
+// rulesfileRequirementFromURLContext is the same as rulesfileRequirementFromURL, but the request is aborted if
+// the context is canceled.
+func rulesfileRequirementFromURLContext(ctx context.Context, url string, timeout time.Duration, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	client := &http.Client{Timeout: timeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, newRequirementError(url, StageOpen, fmt.Errorf("unable to create request for rulesfile %q: %w: %w", url, ErrOpenFailed, err))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newRequirementError(url, StageOpen, fmt.Errorf("unable to download rulesfile %q: %w: %w", url, ErrOpenFailed, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newRequirementError(url, StageOpen, fmt.Errorf("unable to download rulesfile %q: unexpected status %q: %w", url, resp.Status, ErrOpenFailed))
+	}
+
+	return readRulesfileRequirement(url, resp.Body, opts)
+}
+
+// rulesfileRequirementFromTar is the same as rulesfileRequirement, but the rulesfile is read from the entry with
+// the given name of a tar archive, e.g. an OCI layer, without extracting it to disk. The archive is read until the
+// entry is found, hence it can not be used to look for another entry afterwards.
+func rulesfileRequirementFromTar(tr *tar.Reader, entryName string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return rulesfileRequirementFromTarContext(context.Background(), tr, entryName, opts...)
+}
+
+// rulesfileRequirementFromTarContext is the same as rulesfileRequirementFromTar, but looking for the entry, and
+// reading it, is aborted if the context is canceled.
+func rulesfileRequirementFromTarContext(ctx context.Context, tr *tar.Reader, entryName string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	entryName = path.Clean(entryName)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, newRequirementError(entryName, StageOpen, fmt.Errorf("looking for entry %q in archive: %w", entryName, err))
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, newRequirementError(entryName, StageOpen, fmt.Errorf("entry %q not found in archive: %w", entryName, ErrOpenFailed))
+		}
+		if err != nil {
+			return nil, newRequirementError(entryName, StageOpen, fmt.Errorf("unable to read archive while looking for entry %q: %w: %w", entryName, ErrOpenFailed, err))
+		}
+
+		if path.Clean(header.Name) != entryName {
+			continue
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, newRequirementError(entryName, StageOpen, fmt.Errorf("entry %q of archive is not a regular file: %w", entryName, ErrOpenFailed))
+		}
+
+		return readRulesfileRequirement(entryName, &contextReader{ctx: ctx, r: tr}, opts)
+	}
+}
+
+// readRulesfileRequirement reads a rulesfile from the given reader and extracts its requirement, see
+// bufferedRulesfileOpener. The name of the rulesfile is only used for error reporting.
+func readRulesfileRequirement(name string, r io.Reader, opts []RequirementOption) (*oci.ArtifactRequirement, error) {
+	req, _, err := openedRulesfileRequirement(name, bufferedRulesfileOpener(name, r, newRequirementOptions(opts).maxFileSize), opts)
+	return req, err
+}
+
+// resolveEngineRequirement given the engine requirements declared by a rulesfile it returns the one selected by
+// the policy of the given options, together with its version parsed as semver, nil for ranges. The name of the
+// rulesfile is only used for error reporting.
+func resolveEngineRequirement(filePath string, requirements []engineRequirement, o *requirementOptions) (*oci.ArtifactRequirement, *semver.Version, error) {
+	switch {
+	case o.policy == PolicyFirstWins:
+		return &oci.ArtifactRequirement{
+			Name:    requirements[0].Name,
+			Version: requirements[0].Version,
+		}, requirements[0].Semver, nil
+	case o.policy == PolicyError && len(requirements) > 1:
+		return nil, nil, newRequirementError(filePath, StageParse, fmt.Errorf("rulesfile %q declares %d engine requirements, only one is allowed: %w",
+			filePath, len(requirements), ErrParseFailed))
+	}
+
+	return highestEngineRequirement(filePath, requirements, o)
+}
+
+// highestEngineRequirement given the engine requirements declared by a rulesfile it returns the highest (most
+// restrictive) one. An error is returned if the requirements do not agree on the major version, or if a range
+// is combined with a different requirement. The version of the returned requirement is returned parsed as semver
+// too, nil for ranges. The name of the rulesfile is only used for error reporting.
+func highestEngineRequirement(filePath string, requirements []engineRequirement, o *requirementOptions) (*oci.ArtifactRequirement, *semver.Version, error) {
+	// Ranges can not be compared with other requirements, hence all the requirements must be the same.
+	for _, req := range requirements {
+		if !isVersionRange(req.Version) {
+			continue
+		}
+		for _, other := range requirements {
+			if other.Version != req.Version {
+				return nil, nil, newRequirementError(filePath, StageParse, fmt.Errorf("conflicting requirements for rulesfile %q: range %q can not be combined with %q: %w",
+					filePath, req.Version, other.Version, ErrParseFailed))
+			}
+		}
+		return &oci.ArtifactRequirement{
+			Name:    req.Name,
+			Version: req.Version,
+		}, nil, nil
+	}
+
+	var highest semver.Version
+	for i, req := range requirements {
+		o.log().Debug("normalized engine version", "file", filePath, "declared", req.Declared, "version", req.Version)
+
+		reqVer := *req.Semver
+		if i > 0 && reqVer.Major != highest.Major {
+			return nil, nil, newRequirementError(filePath, StageParse, fmt.Errorf("conflicting requirements for rulesfile %q: %q and %q have different major versions: %w",
+				filePath, highest.String(), reqVer.String(), ErrParseFailed))
+		}
+
+		if i == 0 || reqVer.GT(highest) {
+			highest = reqVer
+		}
+	}
+
+	return &oci.ArtifactRequirement{
+		Name:    common.EngineVersionKey,
+		Version: highest.String(),
+	}, &highest, nil
+}
+
+// rulesfileRequirementWithMax is the same as rulesfileRequirement, but it also checks the requirement against
+// the latest released engine version. If the requirement exceeds it, usually because of a typo such as "31.0.0"
+// instead of "0.31.0", the requirement is returned together with a non-fatal warning wrapping
+// ErrUnreleasedEngineVersion. Ranges are not checked. The requirement is extracted with the given options.
+func rulesfileRequirementWithMax(filePath, maxEngineVersion string, opts ...RequirementOption) (req *oci.ArtifactRequirement, warning, err error) {
+	maxVer, err := semver.Parse(maxEngineVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse max engine version %q: %w", maxEngineVersion, err)
+	}
+
+	if req, err = rulesfileRequirement(filePath, opts...); err != nil {
+		return nil, nil, err
+	}
+
+	if isVersionRange(req.Version) {
+		return req, nil, nil
+	}
+
+	reqVer, err := semver.Parse(req.Version)
+	if err != nil {
+		return nil, nil, newRequirementError(filePath, StageParse, fmt.Errorf("unable to parse requirement %q: %w: %w", req.Version, ErrParseFailed, err))
+	}
+
+	if reqVer.GT(maxVer) {
+		warning = fmt.Errorf("rulesfile %q requires engine version %q, latest released is %q: %w",
+			filePath, req.Version, maxEngineVersion, ErrUnreleasedEngineVersion)
+		o := newRequirementOptions(opts)
+		o.log().Warn("engine version newer than the latest released one", "file", filePath, "version", req.Version, "latest", maxEngineVersion)
+		o.warn(filePath, warning)
+	}
+
+	return req, warning, nil
+}
+
+// PackEngineOption is a functional option for PackEngineRequirement.
+type PackEngineOption func(*packEngineOptions)
+
+type packEngineOptions struct {
+	skipMissing bool
+	// reqOpts are the options the requirement of each rulesfile is extracted with.
+	reqOpts []RequirementOption
+}
+
+// WithSkipMissing skips the rulesfiles that do not declare the engine requirement instead of failing.
+func WithSkipMissing() PackEngineOption {
+	return func(o *packEngineOptions) {
+		o.skipMissing = true
+	}
+}
+
+// WithPackRequirementOptions extracts the requirement of each rulesfile with the given options.
+func WithPackRequirementOptions(opts ...RequirementOption) PackEngineOption {
+	return func(o *packEngineOptions) {
+		o.reqOpts = append(o.reqOpts, opts...)
+	}
+}
+
+// PackEngineRequirement given the rulesfiles bundled in a pack it returns the highest engine version they require,
+// which is the minimum version an engine must have to load all of them.
+func PackEngineRequirement(files []string, opts ...PackEngineOption) (*oci.ArtifactRequirement, error) {
+	o := &packEngineOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	var highest *oci.ArtifactRequirement
+	var highestVer semver.Version
+
+	for _, file := range files {
+		req, parsed, err := rulesfileRequirementVersion(file, o.reqOpts...)
+		if o.skipMissing && errors.Is(err, ErrReqNotFound) {
+			newRequirementOptions(o.reqOpts).log().Info("skipping rulesfile without requirements", "file", file, "error", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Ranges can not be compared, hence all the rulesfiles must declare the same one.
+		if highest != nil && (isVersionRange(req.Version) || isVersionRange(highest.Version)) {
+			if req.Version != highest.Version {
+				return nil, fmt.Errorf("conflicting requirements for rulesfile %q: %q can not be combined with %q",
+					file, req.Version, highest.Version)
+			}
+			continue
+		}
+
+		var reqVer semver.Version
+		if parsed != nil {
+			reqVer = *parsed
+		}
+
+		if highest == nil || reqVer.GT(highestVer) {
+			highest = req
+			highestVer = reqVer
+		}
+	}
+
+	if highest == nil {
+		return nil, fmt.Errorf("requirements for pack: %w", ErrReqNotFound)
+	}
+
+	return highest, nil
+}
+
+// rulesfilePluginRequirements given a rulesfile in yaml format it decodes it and extracts the plugins
+// it requires, as declared in the "required_plugin_versions" sections, or in the legacy "required_plugin_version"
+// ones. A requirement is returned for each named plugin, the alternatives are not, see
+// RulesfilePluginRequirementGroups.
+func rulesfilePluginRequirements(filePath string) ([]oci.ArtifactRequirement, error) {
+	groups, err := RulesfilePluginRequirementGroups(filePath)
+	if err != nil {
+		return nil, err
 	}
 
-	defer file.Close()
+	requirements := make([]oci.ArtifactRequirement, 0, len(groups))
+	for _, g := range groups {
+		requirements = append(requirements, g.Options[0])
+	}
 
-	// Prepare the file to be read line by line.
-	fileScanner := bufio.NewScanner(file)
-	fileScanner.Split(bufio.ScanLines)
+	return requirements, nil
+}
 
-	for fileScanner.Scan() {
-		if strings.HasPrefix(fileScanner.Text(), rulesEngineAnchor) {
-			requirement = fileScanner.Text()
-			break
+// PluginRequirementGroup is a plugin required by a rulesfile together with its alternatives, as declared in the
+// "alternatives" of its "required_plugin_versions" entry. The rulesfile can be loaded with any of them, hence the
+// group is satisfied as soon as one of its options is: flattening the alternatives into separate requirements would
+// wrongly require all of them.
+type PluginRequirementGroup struct {
+	// Options are the required plugin followed by its alternatives, in declaration order.
+	Options []oci.ArtifactRequirement
+}
+
+// SatisfiedBy reports whether any option of the group is satisfied by the given plugin versions, keyed by plugin
+// name. As done by Falco, a plugin version satisfies an option if it has the same major and it is not older.
+func (g *PluginRequirementGroup) SatisfiedBy(plugins map[string]string) bool {
+	for _, opt := range g.Options {
+		provided, ok := plugins[opt.Name]
+		if !ok {
+			continue
+		}
+		providedVer, err := semver.ParseTolerant(provided)
+		if err != nil {
+			continue
+		}
+		requiredVer, err := semver.ParseTolerant(opt.Version)
+		if err != nil {
+			continue
+		}
+		if providedVer.Major == requiredVer.Major && providedVer.GTE(requiredVer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RulesfilePluginRequirementGroups given a rulesfile in yaml format it decodes it and extracts the plugins it
+// requires, as declared in the "required_plugin_versions" sections, or in the legacy "required_plugin_version" ones.
+// A group is returned for each named plugin, holding the plugin and its alternatives, see PluginRequirementGroup.
+// Errors are *RequirementError values wrapping ErrOpenFailed, ErrParseFailed or ErrReqNotFound.
+func RulesfilePluginRequirementGroups(filePath string) ([]PluginRequirementGroup, error) {
+	var groups []PluginRequirementGroup
+
+	items, err := decodeRulesfile(filePath, defaultMaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(name, version string) (oci.ArtifactRequirement, error) {
+		if _, err := semver.ParseTolerant(version); err != nil {
+			return oci.ArtifactRequirement{}, newRequirementError(filePath, StageParse, fmt.Errorf("unable to parse version %q for plugin %q: %w: %w", version, name, ErrParseFailed, err))
+		}
+		return oci.ArtifactRequirement{Name: name, Version: version}, nil
+	}
+
+	for _, item := range items {
+		for _, p := range item.RequiredPluginVersions {
+			req, err := parse(p.Name, p.Version)
+			if err != nil {
+				return nil, err
+			}
+			group := PluginRequirementGroup{Options: []oci.ArtifactRequirement{req}}
+			for _, alt := range p.Alternatives {
+				if req, err = parse(alt.Name, alt.Version); err != nil {
+					return nil, err
+				}
+				group.Options = append(group.Options, req)
+			}
+			groups = append(groups, group)
 		}
 	}
 
-	if requirement == "" {
-		return nil, fmt.Errorf("requirements for rulesfile %q: %w", filePath, ErrReqNotFound)
+	if len(groups) == 0 {
+		return nil, newRequirementError(filePath, StageLookup, fmt.Errorf("plugin requirements for rulesfile %q: %w", filePath, ErrReqNotFound))
+	}
+
+	return groups, nil
+}
+
+// isVersionRange returns true if the given version is expressed as a semver range, e.g. ">=0.31.0 <0.40.0".
+func isVersionRange(version string) bool {
+	return strings.ContainsAny(strings.TrimSpace(version), "<>=! |")
+}
+
+// normalizeEngineRequirement given the value of the engine requirement declared in a rulesfile it returns
+// the normalized requirement: the semver string for single versions, or the validated expression for ranges.
+// Pre-release versions are kept, while versions with build metadata are rejected unless allowed by the options.
+func normalizeEngineRequirement(value string, o *requirementOptions) (string, error) {
+	// Remove any leftover whitespace or quote surrounding the version. This includes the "\r" left by
+	// rulesfiles with CRLF line endings.
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+	if isVersionRange(value) {
+		if _, err := semver.ParseRange(value); err != nil {
+			return "", fmt.Errorf("unable to parse requirement range %q: %w: %w", value, ErrParseFailed, err)
+		}
+		if err := checkSatisfiableRange(value); err != nil {
+			return "", err
+		}
+		return value, nil
 	}
 
-	// Split the requirement and parse the version to semVer.
-	// In case the requirement was expressed as a numeric value,
-	// we convert it to semver and treat it as minor version.
-	tokens := strings.Split(fileScanner.Text(), ":")
-	reqVer, err := semver.Parse(tokens[1])
+	reqVer, coerced, err := parseEngineRequirement(value, o.coercion)
 	if err != nil {
-		reqVer, err = semver.ParseTolerant(tokens[1])
-		if err != nil {
-			return nil, fmt.Errorf("unable to parse requirement %q: expected a numeric value or a valid semver string", tokens[1])
+		return "", err
+	}
+
+	// The lowest prerelease of a version precedes all the other ones, as in ">=0.15.0-0".
+	if coerced && o.prereleasePermissive {
+		reqVer.Pre = []semver.PRVersion{{VersionNum: 0, IsNum: true}}
+	}
+
+	// Build metadata is usually left by mistake, and it is ignored when comparing versions anyway.
+	if len(reqVer.Build) > 0 && !o.keepBuildMetadata {
+		return "", fmt.Errorf("unable to parse requirement %q: build metadata %q is not allowed: %w", value, strings.Join(reqVer.Build, "."), ErrParseFailed)
+	}
+
+	return reqVer.String(), nil
+}
+
+// isBareEngineRequirement reports whether the given value of the engine requirement declared in a rulesfile is a
+// bare number coerced to semver, as in "required_engine_version: 10". Short versions, as in "0.31", are coerced too
+// but they are not bare numbers: they are kept as declared, see parseEngineRequirement, hence they are not reported.
+func isBareEngineRequirement(value string) bool {
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	if isVersionRange(value) || strings.Contains(value, ".") {
+		return false
+	}
+
+	_, coerced, err := parseEngineRequirement(value, CoerceToMinor)
+	return err == nil && coerced
+}
+
+// parseEngineRequirement given the value of the engine requirement declared in a rulesfile it returns the
+// required version as semver, and whether it has been coerced from a value that is not a full semver string. A
+// leading "v" or "V", as in "v0.31.0", is ignored. The value is handled according to its shape:
+//
+//	shape          example     result                                   coerced
+//	full semver    0.31.0-rc1  as declared                              no
+//	major.minor    0.31        0.31.0, the patch being 0                yes
+//	bare number    31          0.31.0, or 31.0.0 with CoerceToMajor     yes
+//	anything else  0.31.x      error wrapping ErrParseFailed
+//
+// Only bare numbers depend on the coercion, since the engine versions released before the adoption of semver are
+// bare numbers, while a major.minor version is unambiguous.
+func parseEngineRequirement(value string, coercion BareVersionCoercion) (semver.Version, bool, error) {
+	// Strip the prefix beforehand, so that the shape of the version is the one of the remaining value.
+	if len(value) > 1 && (value[0] == 'v' || value[0] == 'V') {
+		value = value[1:]
+	}
+	parseError := func() error {
+		return fmt.Errorf("unable to parse requirement %q: expected a numeric value or a valid semver string: %w", value, ErrParseFailed)
+	}
+
+	switch strings.Count(value, ".") {
+	case 0:
+		// As in semver, leading zeroes are not allowed.
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || (len(value) > 1 && value[0] == '0') {
+			return semver.Version{}, false, parseError()
+		}
+		if coercion == CoerceToMajor {
+			return semver.Version{Major: n}, true, nil
 		}
-		reqVer = semver.Version{
-			Major: 0,
-			Minor: reqVer.Major,
-			Patch: 0,
+		return semver.Version{Minor: n}, true, nil
+	case 1:
+		// A prerelease or build metadata can not be attached to a short version.
+		reqVer, err := semver.Parse(value + ".0")
+		if err != nil || len(reqVer.Pre) > 0 || len(reqVer.Build) > 0 {
+			return semver.Version{}, false, parseError()
 		}
+		return reqVer, true, nil
+	default:
+		reqVer, err := semver.Parse(value)
+		if err != nil {
+			return semver.Version{}, false, parseError()
+		}
+		return reqVer, false, nil
 	}
+}
 
-	return &oci.ArtifactRequirement{
-		Name:    common.EngineVersionKey,
-		Version: reqVer.String(),
+// PluginInfo is the static info a plugin reports about itself.
+type PluginInfo struct {
+	Name               string
+	Version            string
+	RequiredAPIVersion string
+}
+
+// LoadPluginInfo given a plugin as a shared library it loads it and returns the name, version and api version
+// the plugin reports, e.g. to compare them with the ones in the registry. Plugins are loaded only once, hence
+// calling it together with the requirements extraction does not load the shared library again. The only options
+// having an effect are WithExpectedDigest and WithPluginTempDir.
+func LoadPluginInfo(filePath string, opts ...RequirementOption) (*PluginInfo, error) {
+	plugin, err := loadPluginError(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info := plugin.Info()
+	return &PluginInfo{
+		Name:               info.Name,
+		Version:            info.Version,
+		RequiredAPIVersion: info.RequiredAPIVersion,
 	}, nil
 }
 
 // pluginRequirement given a plugin as a shared library it loads it and gets the api version
-// required by the plugin.
-func pluginRequirement(filePath string) (*oci.ArtifactRequirement, error) {
-	plugin, err := loader.NewPlugin(filePath)
+// required by the plugin. The extraction is reported to the hook set with SetRequirementHook.
+// Plugins compressed with gzip, usually named "*.so.gz", are decompressed to a temporary file, see WithPluginTempDir.
+// Static archives can not be loaded, see staticArchiveRequirement.
+func pluginRequirement(filePath string, opts ...RequirementOption) (req *oci.ArtifactRequirement, err error) {
+	defer observeRequirement(filePath, time.Now(), &err)
+
+	o := newRequirementOptions(opts)
+	static, err := isStaticArchive(filePath)
+	if err != nil {
+		return nil, openFileError(filePath, err)
+	}
+	if static {
+		// Static archives are never loaded, hence they are verified by path.
+		if digest := o.expectedDigest; digest != "" {
+			if err := verifyDigest(filePath, digest); err != nil {
+				return nil, err
+			}
+		}
+		return staticArchiveRequirement(filePath, opts...)
+	}
+
+	compressed, err := isGzipFile(filePath)
+	if err != nil {
+		return nil, openFileError(filePath, err)
+	}
+	if compressed {
+		return compressedPluginRequirement(filePath, o.pluginTempDir, opts...)
+	}
+
+	info, err := LoadPluginInfo(filePath, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open plugin %q: %w", filePath, err)
+		return nil, err
+	}
+
+	return pluginInfoRequirement(filePath, info, opts...)
+}
+
+// pluginInfoRequirement given the info reported by a plugin it returns the api version it requires. An error wrapping
+// both ErrMissingAPIVersion and ErrReqNotFound is returned if the plugin does not report it, as older plugins do.
+// The path of the plugin is only used for error reporting. All the ways of extracting the requirement of a plugin end
+// here, hence the name of the requirement is checked here if WithStrictRequirementNames is set.
+func pluginInfoRequirement(filePath string, info *PluginInfo, opts ...RequirementOption) (req *oci.ArtifactRequirement, err error) {
+	defer strictRequirementName(filePath, opts, &req, &err)
+
+	if strings.TrimSpace(info.RequiredAPIVersion) == "" {
+		return nil, newRequirementError(filePath, StageLookup, fmt.Errorf("requirements for plugin %q: %w: %w", filePath, ErrMissingAPIVersion, ErrReqNotFound))
+	}
+
+	o := newRequirementOptions(opts)
+	if err := checkLoaderAPIVersion(info.RequiredAPIVersion); err != nil {
+		err = fmt.Errorf("plugin %q: %w", filePath, err)
+		if o.strictAPIVersion {
+			return nil, newRequirementError(filePath, StageParse, err)
+		}
+		o.log().Warn("plugin api version not supported by the plugin loader", "file", filePath, "error", err)
+		o.warn(filePath, err)
+	}
+
+	version := info.RequiredAPIVersion
+	if o.stripAPIPrerelease {
+		version = stripPrerelease(version)
 	}
 
 	return &oci.ArtifactRequirement{
 		Name:    common.PluginAPIVersion,
-		Version: plugin.Info().RequiredAPIVersion,
+		Version: version,
 	}, nil
 }
+
+// stripPrerelease removes the prerelease from the given version, keeping any build metadata. Versions that are not
+// valid semver are returned as they are.
+func stripPrerelease(version string) string {
+	v, err := semver.Parse(strings.TrimSpace(version))
+	if err != nil || len(v.Pre) == 0 {
+		return version
+	}
+	v.Pre = nil
+
+	return v.String()
+}
+
+// pluginRequirementFromBytes is the same as pluginRequirement, but the shared library is given as a byte buffer,
+// e.g. downloaded from a cache. Since shared libraries can only be loaded from the filesystem, the buffer is written
+// to a temporary file in tmpDir, or in the default directory for temporary files if tmpDir is empty. The temporary
+// file is always removed, and the plugin unloaded, before returning.
+func pluginRequirementFromBytes(data []byte, tmpDir string) (*oci.ArtifactRequirement, error) {
+	return pluginRequirementFromReader("", bytes.NewReader(data), tmpDir)
+}
+
+// compressedPluginRequirement is the same as pluginRequirement, but the shared library is compressed with gzip. It is
+// decompressed to a temporary file in tmpDir, as done by pluginRequirementFromBytes. If a digest is expected, see
+// WithExpectedDigest, the compressed file is verified while being decompressed, and the decompressed plugin is not
+// loaded on mismatch. Decompressing more than the size set with WithMaxPluginSize fails.
+func compressedPluginRequirement(filePath, tmpDir string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("unable to open plugin %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+	defer file.Close()
+
+	dr := newDigestReader(filePath, file)
+	gzipReader, err := gzip.NewReader(dr)
+	if err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("unable to decompress plugin %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+
+	o := newRequirementOptions(opts)
+	var r io.Reader = gzipReader
+	if o.maxPluginSize > 0 {
+		r = &sizeLimitReader{name: filePath, r: r, remaining: o.maxPluginSize, tooLarge: pluginTooLargeError}
+	}
+	if expected := o.expectedDigest; expected != "" {
+		r = &eofCheckReader{r: r, check: func() error { return dr.check(expected) }}
+	}
+
+	return pluginRequirementFromReader(filePath, r, tmpDir, opts...)
+}
+
+// pluginRequirementFromReader writes the shared library read from r to a temporary file in tmpDir, and extracts
+// the requirement of the plugin from it. The temporary file is always removed, and the plugin unloaded, before
+// returning. The name of the plugin is only used for error reporting, the temporary file is reported if empty.
+func pluginRequirementFromReader(name string, r io.Reader, tmpDir string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	file, err := os.CreateTemp(tmpDir, "registry-plugin-*.so")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file for plugin: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if name == "" {
+		name = file.Name()
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return nil, newRequirementError(name, StageOpen, fmt.Errorf("unable to write plugin %q to temporary file %q: %w: %w", name, file.Name(), ErrOpenFailed, err))
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write plugin %q to temporary file %q: %w", name, file.Name(), err)
+	}
+
+	// The plugin is not cached since the temporary file is removed right away.
+	plugin, err := newPlugin(file.Name())
+	if err != nil {
+		return nil, newRequirementError(name, StageOpen, fmt.Errorf("unable to open plugin %q: %w: %w", name, ErrOpenFailed, err))
+	}
+	defer plugin.Unload()
+
+	return pluginInfoRequirement(name, &PluginInfo{RequiredAPIVersion: plugin.Info().RequiredAPIVersion}, opts...)
+}
+
+// isGzipFile reports whether the given file is compressed with gzip, as detected by its leading magic bytes.
+func isGzipFile(filePath string) (bool, error) {
+	return hasMagic(filePath, gzipMagic)
+}
+
+// hasMagic reports whether the given file starts with the given magic bytes.
+func hasMagic(filePath string, expected []byte) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(expected))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		// Files shorter than the magic bytes do not start with them.
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return bytes.Equal(magic, expected), nil
+}
+
+// checkLoaderAPIVersion returns an error wrapping ErrUnsupportedAPIVersion if the given api version, required by a
+// plugin, is not supported by the plugin loader of this tool. As the loader does, the major versions must be the
+// same and the required version must not be newer than the supported one.
+func checkLoaderAPIVersion(required string) error {
+	supportedVer := semver.MustParse(loaderAPIVersion)
+
+	requiredVer, err := semver.ParseTolerant(required)
+	if err != nil {
+		return fmt.Errorf("unable to parse required api version %q: %w: %w", required, ErrParseFailed, err)
+	}
+
+	if requiredVer.Major != supportedVer.Major || requiredVer.GT(supportedVer) {
+		return fmt.Errorf("required api version %q, supported %q: %w", required, loaderAPIVersion, ErrUnsupportedAPIVersion)
+	}
+
+	return nil
+}
+
+// multiPlatformPluginRequirement given a plugin built for multiple platforms, as a map of platforms to shared
+// libraries, it loads each one and gets the api version required by the plugin. An error enumerating the api
+// versions found for each platform is returned if they are not the same across all the platforms.
+func multiPlatformPluginRequirement(filePaths map[string]string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	if len(filePaths) == 0 {
+		return nil, fmt.Errorf("no plugins given: %w", ErrReqNotFound)
+	}
+
+	platforms := make([]string, 0, len(filePaths))
+	for p := range filePaths {
+		platforms = append(platforms, p)
+	}
+	sort.Strings(platforms)
+
+	var req *oci.ArtifactRequirement
+	var mismatch bool
+	found := make([]string, 0, len(platforms))
+
+	for _, p := range platforms {
+		r, err := pluginRequirement(filePaths[p], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("platform %q: %w", p, err)
+		}
+
+		if req == nil {
+			req = r
+		} else if r.Version != req.Version {
+			mismatch = true
+		}
+		found = append(found, fmt.Sprintf("%s: %s", p, r.Version))
+	}
+
+	if mismatch {
+		return nil, fmt.Errorf("plugin api version differs across platforms: %s", strings.Join(found, ", "))
+	}
+
+	return req, nil
+}
+
+// VerifyPluginAPIVersion given a plugin as a shared library it loads it and checks that the api version
+// required by the plugin matches the expected one, e.g. the one recorded in the registry metadata.
+func VerifyPluginAPIVersion(filePath, expected string, opts ...RequirementOption) error {
+	req, err := pluginRequirement(filePath, opts...)
+	if err != nil {
+		return err
+	}
+
+	expectedVer, err := semver.ParseTolerant(expected)
+	if err != nil {
+		return fmt.Errorf("unable to parse expected plugin api version %q: %w", expected, err)
+	}
+
+	reqVer, err := semver.ParseTolerant(req.Version)
+	if err != nil {
+		return fmt.Errorf("unable to parse plugin api version %q required by plugin %q: %w", req.Version, filePath, err)
+	}
+
+	if !reqVer.EQ(expectedVer) {
+		return fmt.Errorf("plugin api version mismatch for plugin %q: expected %q, found %q", filePath, expected, req.Version)
+	}
+
+	return nil
+}
+
+// fileRequirement given a file bundled in an artifact, such as a plugin as a shared library or a rulesfile, it
+// extracts its requirement with the registered extractor handling it, see RegisterRequirementExtractor. The files no
+// extractor handles are handled as rulesfiles, e.g. the ones in json format. The requirement is overridden by the
+// sidecar of the file, if any, see RequirementsOverrideSuffix. An error wrapping ErrMultipleRequirements is returned
+// if the extractor, or the sidecar, returns more than one requirement.
+func fileRequirement(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	return fileRequirementContext(context.Background(), filePath, opts...)
+}
+
+// fileRequirementContext is the same as fileRequirement, but it fails right away if the context is canceled.
+// Reading rulesfiles is aborted on cancellation, while loading plugins can not be interrupted.
+func fileRequirementContext(ctx context.Context, filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	var reqs []oci.ArtifactRequirement
+	if extractorFor(filePath) == nil {
+		req, err := rulesfileRequirementContext(ctx, filePath, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if reqs, err = overriddenRequirements(filePath, []oci.ArtifactRequirement{*req}, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if reqs, err = fileRequirements(ctx, filePath, opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(reqs) != 1 {
+		return nil, fmt.Errorf("file %q has %d requirements: %w", filePath, len(reqs), ErrMultipleRequirements)
+	}
+
+	return &reqs[0], nil
+}
+
+// pluginRequirementContext is the same as pluginRequirement, but it fails right away if the context is canceled.
+// Loading plugins can not be interrupted.
+func pluginRequirementContext(ctx context.Context, filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("requirements for plugin %q: %w", filePath, err))
+	}
+
+	return pluginRequirement(filePath, opts...)
+}
+
+// RequirementWithDigest given a plugin as a shared library or a rulesfile it extracts its requirement and
+// returns it together with the hex encoded sha256 digest of the file, to pin the file the requirement
+// has been derived from. Symbolic links are followed, the digest being the one of their target.
+func RequirementWithDigest(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, string, error) {
+	req, err := fileRequirement(filePath, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest, err := fileDigest(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return req, digest, nil
+}
+
+// ArtifactRequirements given a directory containing a plugin as a shared library and/or its rulesfiles, it extracts
+// the plugin api version and the engine version they require, together with the requirements of any other file
+// handled by a registered extractor, see RegisterRequirementExtractor. The requirements of each file are overridden
+// by its sidecar, if any, see RequirementsOverrideSuffix. Requirements are deduplicated by name keeping the highest
+// version, and returned sorted by name. Requirements that are not known fail the extraction, see
+// RegisterRequirementName.
+func ArtifactRequirements(dir string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %q: %w", dir, err)
+	}
+
+	var requirements []oci.ArtifactRequirement
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		filePath := filepath.Join(dir, entry.Name())
+
+		// Skip the files no extractor can handle, such as README files.
+		if extractorFor(filePath) == nil {
+			continue
+		}
+
+		reqs, err := fileRequirements(context.Background(), filePath, opts)
+		if errors.Is(err, ErrReqNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range reqs {
+			if requirements, err = mergeRequirement(requirements, req, filePath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(requirements) == 0 {
+		return nil, fmt.Errorf("requirements for directory %q: %w", dir, ErrReqNotFound)
+	}
+
+	SortRequirements(requirements)
+
+	return requirements, nil
+}
+
+// EffectiveRequirements given a plugin as a shared library and the rulesfiles bundled with it in an artifact, it
+// returns the requirements a consumer must satisfy to use the whole artifact: the plugin api version required by the
+// plugin and the highest engine version required by the rulesfiles, see PackEngineRequirement. Either the plugin or
+// the rulesfiles can be omitted. The requirements are deduplicated and sorted, ready to be embedded in the config blob.
+func EffectiveRequirements(pluginPath string, rulesfilePaths []string, opts ...RequirementOption) ([]oci.ArtifactRequirement, error) {
+	if pluginPath == "" && len(rulesfilePaths) == 0 {
+		return nil, fmt.Errorf("requirements for artifact: %w", ErrReqNotFound)
+	}
+
+	var requirements []oci.ArtifactRequirement
+
+	if pluginPath != "" {
+		req, err := pluginRequirement(pluginPath, opts...)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, *req)
+	}
+
+	if len(rulesfilePaths) > 0 {
+		req, err := PackEngineRequirement(rulesfilePaths, WithPackRequirementOptions(opts...))
+		if err != nil {
+			return nil, err
+		}
+		if requirements, err = mergeRequirement(requirements, *req, "rulesfiles"); err != nil {
+			return nil, err
+		}
+	}
+
+	SortRequirements(requirements)
+
+	return requirements, nil
+}
+
+// SortRequirements sorts the given requirements by name, and then by version, so that they are always serialized
+// in the same order, e.g. in the config blobs of the artifacts. Versions are compared as semver, the ones that are
+// not valid semver, such as ranges, are sorted after the valid ones, lexically.
+func SortRequirements(reqs []oci.ArtifactRequirement) {
+	sort.SliceStable(reqs, func(i, j int) bool {
+		if reqs[i].Name != reqs[j].Name {
+			return reqs[i].Name < reqs[j].Name
+		}
+
+		vi, erri := semver.ParseTolerant(reqs[i].Version)
+		vj, errj := semver.ParseTolerant(reqs[j].Version)
+		switch {
+		case erri == nil && errj == nil:
+			return vi.LT(vj)
+		case erri == nil || errj == nil:
+			return erri == nil
+		default:
+			return reqs[i].Version < reqs[j].Version
+		}
+	})
+}
+
+// MergeRequirements merges the given sets of requirements, e.g. the ones of a plugin and of its rulesfiles, into a
+// single one holding a requirement for each name. When the same requirement is in more than one set the highest
+// version is kept, failing with an error wrapping ErrConflictingRequirements if the versions have different major
+// versions, hence can not be satisfied together, or if one of them is a range different from the other. The merged
+// requirements are returned sorted, see SortRequirements.
+func MergeRequirements(sets ...[]oci.ArtifactRequirement) ([]oci.ArtifactRequirement, error) {
+	var requirements []oci.ArtifactRequirement
+	for i, set := range sets {
+		for _, req := range set {
+			source := fmt.Sprintf("set %d", i)
+			if err := checkSameMajor(requirements, req, source); err != nil {
+				return nil, err
+			}
+
+			var err error
+			if requirements, err = mergeRequirement(requirements, req, source); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	SortRequirements(requirements)
+
+	return requirements, nil
+}
+
+// checkSameMajor returns an error wrapping ErrConflictingRequirements if the requirement with the same name as the
+// given one, extracted from filePath, has a different major version. Ranges, and versions that can not be parsed,
+// are left to mergeRequirement to report.
+func checkSameMajor(requirements []oci.ArtifactRequirement, req oci.ArtifactRequirement, filePath string) error {
+	i := slices.IndexFunc(requirements, func(r oci.ArtifactRequirement) bool { return r.Name == req.Name })
+	if i < 0 || isVersionRange(req.Version) || isVersionRange(requirements[i].Version) {
+		return nil
+	}
+
+	reqVer, err := semver.ParseTolerant(req.Version)
+	if err != nil {
+		return nil
+	}
+	curVer, err := semver.ParseTolerant(requirements[i].Version)
+	if err != nil {
+		return nil
+	}
+	if reqVer.Major != curVer.Major {
+		return fmt.Errorf("requirement %q for %q: %q and %q have different major versions: %w",
+			req.Name, filePath, req.Version, requirements[i].Version, ErrConflictingRequirements)
+	}
+
+	return nil
+}
+
+// mergeRequirement adds the given requirement, extracted from filePath, to the requirements, or updates the one with
+// the same name keeping the highest version. An error wrapping ErrConflictingRequirements is returned if one of the
+// versions is a range different from the other. Unlike MergeRequirements, versions with different major versions are
+// merged too.
+func mergeRequirement(requirements []oci.ArtifactRequirement, req oci.ArtifactRequirement, filePath string) ([]oci.ArtifactRequirement, error) {
+	i := slices.IndexFunc(requirements, func(r oci.ArtifactRequirement) bool { return r.Name == req.Name })
+	if i < 0 {
+		return append(requirements, req), nil
+	}
+
+	// Ranges can not be compared, hence the same requirement can not be declared with a different value.
+	if isVersionRange(req.Version) || isVersionRange(requirements[i].Version) {
+		if requirements[i].Version != req.Version {
+			return nil, fmt.Errorf("requirement %q for %q: %q can not be combined with %q: %w",
+				req.Name, filePath, req.Version, requirements[i].Version, ErrConflictingRequirements)
+		}
+		return requirements, nil
+	}
+
+	// Keep the highest version for each requirement.
+	reqVer, err := semver.ParseTolerant(req.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse requirement %q for %q: %w", req.Version, filePath, err)
+	}
+	curVer, err := semver.ParseTolerant(requirements[i].Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse requirement %q: %w", requirements[i].Version, err)
+	}
+	if reqVer.GT(curVer) {
+		requirements[i].Version = req.Version
+	}
+
+	return requirements, nil
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver"
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+)
+
+// ErrEngineVersionDecreased error when a new version of an artifact requires an older engine than the previous one.
+var ErrEngineVersionDecreased = errors.New("engine version requirement decreased")
+
+// RequirementChangeKind is the kind of change of a requirement between two versions of an artifact.
+type RequirementChangeKind string
+
+const (
+	// RequirementAdded is a requirement declared only by the new version.
+	RequirementAdded RequirementChangeKind = "added"
+	// RequirementRemoved is a requirement declared only by the old version.
+	RequirementRemoved RequirementChangeKind = "removed"
+	// RequirementChanged is a requirement declared by both versions with a different version.
+	RequirementChanged RequirementChangeKind = "changed"
+)
+
+// RequirementChange is a change of a requirement between two versions of an artifact. OldVersion is empty
+// for added requirements, NewVersion is empty for removed ones.
+type RequirementChange struct {
+	Name       string
+	Kind       RequirementChangeKind
+	OldVersion string
+	NewVersion string
+}
+
+// DiffRequirements given the requirements of two versions of an artifact it returns the requirements that have
+// been added, removed or whose version changed, matching them by name. Changes are sorted by name. If a name is
+// repeated in the same list, the last requirement declared with that name is considered.
+func DiffRequirements(old, new []oci.ArtifactRequirement) []RequirementChange {
+	oldVersions := make(map[string]string, len(old))
+	for _, req := range old {
+		oldVersions[req.Name] = req.Version
+	}
+	newVersions := make(map[string]string, len(new))
+	for _, req := range new {
+		newVersions[req.Name] = req.Version
+	}
+
+	var changes []RequirementChange
+	for name, oldVersion := range oldVersions {
+		newVersion, ok := newVersions[name]
+		switch {
+		case !ok:
+			changes = append(changes, RequirementChange{Name: name, Kind: RequirementRemoved, OldVersion: oldVersion})
+		case newVersion != oldVersion:
+			changes = append(changes, RequirementChange{Name: name, Kind: RequirementChanged, OldVersion: oldVersion, NewVersion: newVersion})
+		}
+	}
+	for name, newVersion := range newVersions {
+		if _, ok := oldVersions[name]; !ok {
+			changes = append(changes, RequirementChange{Name: name, Kind: RequirementAdded, NewVersion: newVersion})
+		}
+	}
+
+	// Names are unique across the changes, hence sorting by name is deterministic.
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// CheckEngineRequirementMonotonic given the requirements of the previously published version of an artifact and
+// the ones of its new version, it returns an error wrapping ErrEngineVersionDecreased if the engine version required
+// by the new version is lower, since that would silently widen the compatibility claimed by the artifact. Nothing is
+// checked if either version does not require an engine version, or if any of them is a range.
+func CheckEngineRequirementMonotonic(previous, current []oci.ArtifactRequirement) error {
+	engineVersion := func(reqs []oci.ArtifactRequirement) string {
+		var version string
+		for _, req := range reqs {
+			if req.Name == common.EngineVersionKey {
+				version = req.Version
+			}
+		}
+		return version
+	}
+
+	prevVersion, curVersion := engineVersion(previous), engineVersion(current)
+	if prevVersion == "" || curVersion == "" || isVersionRange(prevVersion) || isVersionRange(curVersion) {
+		return nil
+	}
+
+	prev, err := semver.ParseTolerant(prevVersion)
+	if err != nil {
+		return fmt.Errorf("unable to parse previous %s %q: %w", common.EngineVersionKey, prevVersion, err)
+	}
+	cur, err := semver.ParseTolerant(curVersion)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s %q: %w", common.EngineVersionKey, curVersion, err)
+	}
+
+	if cur.LT(prev) {
+		return fmt.Errorf("%s %q is lower than %q required by the previous version: %w",
+			common.EngineVersionKey, curVersion, prevVersion, ErrEngineVersionDecreased)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Diff requirements", func() {
+	It("should report added, removed and changed requirements sorted by name", func() {
+		old := []falcoctloci.ArtifactRequirement{
+			{Name: "plugin_api_version", Version: "2.0.0"},
+			{Name: "engine_version_semver", Version: "0.31.0"},
+			{Name: "kernel_version", Version: "5.0.0"},
+		}
+		new := []falcoctloci.ArtifactRequirement{
+			{Name: "plugin_api_version", Version: "3.0.0"},
+			{Name: "engine_version_semver", Version: "0.31.0"},
+			{Name: "arch", Version: "1.0.0"},
+		}
+
+		Expect(oci.DiffRequirements(old, new)).To(Equal([]oci.RequirementChange{
+			{Name: "arch", Kind: oci.RequirementAdded, NewVersion: "1.0.0"},
+			{Name: "kernel_version", Kind: oci.RequirementRemoved, OldVersion: "5.0.0"},
+			{Name: "plugin_api_version", Kind: oci.RequirementChanged, OldVersion: "2.0.0", NewVersion: "3.0.0"},
+		}))
+	})
+
+	It("should report nothing for the same requirements", func() {
+		reqs := []falcoctloci.ArtifactRequirement{{Name: "plugin_api_version", Version: "3.0.0"}}
+		Expect(oci.DiffRequirements(reqs, reqs)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Check engine requirement monotonicity", func() {
+	engine := func(version string) []falcoctloci.ArtifactRequirement {
+		return []falcoctloci.ArtifactRequirement{{Name: "engine_version_semver", Version: version}}
+	}
+
+	It("should accept the same or a higher engine version", func() {
+		Expect(oci.CheckEngineRequirementMonotonic(engine("0.31.0"), engine("0.31.0"))).To(Succeed())
+		Expect(oci.CheckEngineRequirementMonotonic(engine("0.31.0"), engine("0.35.0"))).To(Succeed())
+	})
+
+	It("should fail for a lower engine version", func() {
+		err := oci.CheckEngineRequirementMonotonic(engine("0.35.0"), engine("0.31.0"))
+		Expect(err).To(MatchError(oci.ErrEngineVersionDecreased))
+	})
+
+	It("should not check artifacts without a previous engine version or with ranges", func() {
+		Expect(oci.CheckEngineRequirementMonotonic(nil, engine("0.31.0"))).To(Succeed())
+		Expect(oci.CheckEngineRequirementMonotonic(engine(">=0.35.0"), engine("0.31.0"))).To(Succeed())
+	})
+})
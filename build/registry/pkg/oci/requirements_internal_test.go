@@ -0,0 +1,537 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRulesfile(t testing.TB, content string) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write rulesfile: %v", err)
+	}
+
+	return filePath
+}
+
+func TestRulesfileRequirementsDeclared(t *testing.T) {
+	t.Parallel()
+
+	filePath := writeRulesfile(t, `- required_engine_version: 10
+
+- rule: first
+  condition: evt.type = open
+
+- required_engine_version: 12
+`)
+
+	reqs, err := rulesfileRequirements(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 requirements, got %d", len(reqs))
+	}
+	if reqs[0].Declared != "10" || reqs[0].Version != "0.10.0" {
+		t.Fatalf("expected declared version %q normalized to %q, got %q and %q", "10", "0.10.0", reqs[0].Declared, reqs[0].Version)
+	}
+}
+
+func TestRulesfileRequirementWithMax(t *testing.T) {
+	t.Parallel()
+
+	req, warning, err := rulesfileRequirementWithMax(writeRulesfile(t, "- required_engine_version: 31.0.0\n"), "0.31.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "31.0.0" || !errors.Is(warning, ErrUnreleasedEngineVersion) {
+		t.Fatalf("expected requirement %q with warning %v, got %q and %v", "31.0.0", ErrUnreleasedEngineVersion, req.Version, warning)
+	}
+
+	_, warning, err = rulesfileRequirementWithMax(writeRulesfile(t, "- required_engine_version: 0.31.0\n"), "0.31.0")
+	if err != nil || warning != nil {
+		t.Fatalf("expected no error and no warning, got %v and %v", err, warning)
+	}
+
+	// The warning is collected too.
+	collector := &WarningCollector{}
+	if _, warning, err := rulesfileRequirementWithMax(writeRulesfile(t, "- required_engine_version: 31.0.0\n"), "0.40.0",
+		WithWarningCollector(collector)); err != nil || warning == nil {
+		t.Fatalf("expected a warning and no error, got %v and %v", warning, err)
+	}
+	if warnings := collector.Warnings(); len(warnings) != 1 || !errors.Is(warnings[0].Err, ErrUnreleasedEngineVersion) {
+		t.Fatalf("expected an unreleased engine version warning, got %v", warnings)
+	}
+}
+
+func TestRulesfileRequirementFromReader(t *testing.T) {
+	t.Parallel()
+
+	req, err := rulesfileRequirementFromReader(strings.NewReader("- required_engine_version: 10\n- required_engine_version: 0.12.0\n"),
+		WithRequirementPolicy(PolicyMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "0.12.0" {
+		t.Fatalf("expected version %q, got %q", "0.12.0", req.Version)
+	}
+
+	// The near miss is reported for readers too.
+	_, err = rulesfileRequirementFromReader(strings.NewReader("- rule: first\n- required_engine_versions: 10\n"))
+	if !errors.Is(err, ErrReqNotFound) {
+		t.Fatalf("expected ErrReqNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "near miss at line 2") {
+		t.Fatalf("expected near miss at line 2, got %v", err)
+	}
+}
+
+func TestRulesfileRequirementFromReaderSkipMarker(t *testing.T) {
+	t.Parallel()
+
+	_, err := rulesfileRequirementFromReader(strings.NewReader(SkipRequirementsMarker + "\n- required_engine_version: 15\n"))
+	if !errors.Is(err, ErrSkipped) || !errors.Is(err, ErrReqNotFound) {
+		t.Fatalf("expected ErrSkipped and ErrReqNotFound, got %v", err)
+	}
+
+	req, err := rulesfileRequirementFromReader(strings.NewReader("- required_engine_version: 15\n" + SkipRequirementsMarker + "\n"))
+	if err != nil || req.Version != "0.15.0" {
+		t.Fatalf("expected version %q, got %v and %v", "0.15.0", req, err)
+	}
+}
+
+func TestRulesfileRequirementFromReaderMaxSize(t *testing.T) {
+	t.Parallel()
+
+	// The limit applies to the decompressed content.
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte("- required_engine_version: 0.31.0\n" + strings.Repeat("# padding\n", 100))); err != nil {
+		t.Fatalf("unable to compress rulesfile: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("unable to compress rulesfile: %v", err)
+	}
+	if _, err := rulesfileRequirementFromReader(bytes.NewReader(buf.Bytes()), WithMaxFileSize(64)); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected error %v, got %v", ErrFileTooLarge, err)
+	}
+}
+
+func TestRulesfileRequirementFlowStyle(t *testing.T) {
+	t.Parallel()
+
+	// Yaml rulesfiles in flow style look like json ones, but are not valid json.
+	req, err := rulesfileRequirementFromReader(strings.NewReader("[{required_engine_version: 0.31.0}, {rule: open}]"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "0.31.0" {
+		t.Fatalf("expected version %q, got %q", "0.31.0", req.Version)
+	}
+
+	_, err = rulesfileRequirementFromReader(strings.NewReader(`[{"required_engine_version": "0.31.0"`))
+	if !errors.Is(err, ErrParseFailed) {
+		t.Fatalf("expected error %v, got %v", ErrParseFailed, err)
+	}
+}
+
+func TestRulesfileRequirementFromReaderJSON(t *testing.T) {
+	t.Parallel()
+
+	// Numeric values are coerced as in yaml rulesfiles.
+	req, err := rulesfileRequirementFromReader(strings.NewReader(`[{"required_engine_version": 15}]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "0.15.0" {
+		t.Fatalf("expected version %q, got %q", "0.15.0", req.Version)
+	}
+}
+
+func TestRulesfileRequirementFromURL(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rules.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("- required_engine_version: 0.31.0\n"))
+	}))
+	defer server.Close()
+
+	req, err := rulesfileRequirementFromURL(server.URL+"/rules.yaml", 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "0.31.0" {
+		t.Fatalf("expected version %q, got %q", "0.31.0", req.Version)
+	}
+
+	_, err = rulesfileRequirementFromURL(server.URL+"/missing.yaml", 5*time.Second)
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.Stage != StageOpen || !errors.Is(err, ErrOpenFailed) {
+		t.Fatalf("expected an open error, got %v", err)
+	}
+}
+
+func TestRulesfileRequirementFromTar(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "rules/", Mode: 0o700, Typeflag: tar.TypeDir}); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+	content := "- required_engine_version: 0.31.0\n"
+	if err := tw.WriteHeader(&tar.Header{Name: "rules/k8saudit_rules.yaml", Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+
+	req, err := rulesfileRequirementFromTar(tar.NewReader(bytes.NewReader(buf.Bytes())), "./rules/k8saudit_rules.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "0.31.0" {
+		t.Fatalf("expected version %q, got %q", "0.31.0", req.Version)
+	}
+
+	for _, entry := range []string{"rules", "missing.yaml"} {
+		_, err := rulesfileRequirementFromTar(tar.NewReader(bytes.NewReader(buf.Bytes())), entry)
+		var reqErr *RequirementError
+		if !errors.As(err, &reqErr) || reqErr.Stage != StageOpen || !errors.Is(err, ErrOpenFailed) {
+			t.Fatalf("expected an open error for entry %q, got %v", entry, err)
+		}
+	}
+}
+
+// cancelingReader cancels the context after the first read.
+type cancelingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	defer r.cancel()
+	return r.r.Read(p[:1])
+}
+
+func TestRequirementsContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := rulesfileRequirementFromURLContext(ctx, "http://127.0.0.1:0/rules.yaml", time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Reads in progress are aborted.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	r := &contextReader{ctx: ctx, r: &cancelingReader{r: strings.NewReader("- required_engine_version: 15\n"), cancel: cancel}}
+	if _, err := rulesfileRequirementFromReader(r); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestReaderRequirementHook is not parallel since the hook is global.
+func TestReaderRequirementHook(t *testing.T) {
+	var calls []string
+	var errs []error
+	SetRequirementHook(RequirementHookFunc(func(filePath string, duration time.Duration, err error) {
+		calls = append(calls, filePath)
+		errs = append(errs, err)
+	}))
+	defer SetRequirementHook(nil)
+
+	// Rulesfiles not read from a path are reported too.
+	if _, err := rulesfileRequirementFromReader(strings.NewReader("- rule: open\n")); !errors.Is(err, ErrReqNotFound) {
+		t.Fatalf("expected error %v, got %v", ErrReqNotFound, err)
+	}
+
+	if !slices.Equal(calls, []string{readerRulesfileName}) || !errors.Is(errs[0], ErrReqNotFound) {
+		t.Fatalf("unexpected hook calls %v and errors %v", calls, errs)
+	}
+}
+
+func TestPluginRequirementFromBytes(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	_, err := pluginRequirementFromBytes([]byte("not a shared library"), tmpDir)
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.Stage != StageOpen || !errors.Is(err, ErrOpenFailed) {
+		t.Fatalf("expected an open error, got %v", err)
+	}
+
+	// The temporary file is removed even on error.
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("unable to read temporary dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the temporary dir to be empty, found %d entries", len(entries))
+	}
+}
+
+// TestParseEngineRequirementShapes covers the decision table of parseEngineRequirement, for each shape of version.
+func TestParseEngineRequirementShapes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    string
+		coercion BareVersionCoercion
+		expected string
+		coerced  bool
+		fails    bool
+	}{
+		// Full semver versions are kept as declared, whatever the coercion.
+		{value: "0.31.0", expected: "0.31.0"},
+		{value: "0.31.0", coercion: CoerceToMajor, expected: "0.31.0"},
+		{value: "v0.31.0", expected: "0.31.0"},
+		{value: "0.31.0-rc1", expected: "0.31.0-rc1"},
+		{value: "0.31.0+build1", expected: "0.31.0+build1"},
+		// Major.minor versions get a zero patch, whatever the coercion.
+		{value: "0.31", expected: "0.31.0", coerced: true},
+		{value: "0.31", coercion: CoerceToMajor, expected: "0.31.0", coerced: true},
+		{value: "1.2", expected: "1.2.0", coerced: true},
+		{value: "V0.31", expected: "0.31.0", coerced: true},
+		// Bare numbers depend on the coercion.
+		{value: "31", expected: "0.31.0", coerced: true},
+		{value: "31", coercion: CoerceToMajor, expected: "31.0.0", coerced: true},
+		{value: "0", expected: "0.0.0", coerced: true},
+		// Anything else is rejected.
+		{value: "", fails: true},
+		{value: "031", fails: true},
+		{value: "0.31-rc1", fails: true},
+		{value: "0.31+build1", fails: true},
+		{value: "0.31.x", fails: true},
+		{value: "0.31.0.1", fails: true},
+		{value: "latest", fails: true},
+	}
+	for _, tt := range tests {
+		v, coerced, err := parseEngineRequirement(tt.value, tt.coercion)
+		if tt.fails {
+			if !errors.Is(err, ErrParseFailed) {
+				t.Fatalf("%q: expected error %v, got %v and version %q", tt.value, ErrParseFailed, err, v.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.value, err)
+		}
+		if v.String() != tt.expected || coerced != tt.coerced {
+			t.Fatalf("%q: expected %q coerced %t, got %q coerced %t", tt.value, tt.expected, tt.coerced, v.String(), coerced)
+		}
+	}
+}
+
+func TestCheckLoaderAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"3.0.0": true,
+		"2.0.0": false,
+		"4.0.0": false,
+		"3.0.1": false,
+		"3.1.0": false,
+	}
+	if loaderAPIVersion != "3.0.0" {
+		t.Skipf("test cases written for loader api version 3.0.0, found %q", loaderAPIVersion)
+	}
+
+	for version, supported := range tests {
+		err := checkLoaderAPIVersion(version)
+		if supported && err != nil {
+			t.Fatalf("unexpected error for version %q: %v", version, err)
+		}
+		if !supported && !errors.Is(err, ErrUnsupportedAPIVersion) {
+			t.Fatalf("expected ErrUnsupportedAPIVersion for version %q, got %v", version, err)
+		}
+	}
+
+	if err := checkLoaderAPIVersion("three"); !errors.Is(err, ErrParseFailed) {
+		t.Fatalf("expected ErrParseFailed, got %v", err)
+	}
+}
+
+func TestWithStrictAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	info := &PluginInfo{RequiredAPIVersion: "99.0.0"}
+
+	req, err := pluginInfoRequirement("libtest.so", info)
+	if err != nil {
+		t.Fatalf("unexpected error without strict api version: %v", err)
+	}
+	if req.Version != "99.0.0" {
+		t.Fatalf("expected version %q, got %q", "99.0.0", req.Version)
+	}
+
+	_, err = pluginInfoRequirement("libtest.so", info, WithStrictAPIVersion())
+	if !errors.Is(err, ErrUnsupportedAPIVersion) {
+		t.Fatalf("expected ErrUnsupportedAPIVersion, got %v", err)
+	}
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.Stage != StageParse {
+		t.Fatalf("expected a *RequirementError at the parse stage, got %v", err)
+	}
+}
+
+func TestPluginInfoRequirementMissingAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	_, err := pluginInfoRequirement("libold.so", &PluginInfo{Name: "old", Version: "0.1.0", RequiredAPIVersion: ""})
+	if !errors.Is(err, ErrMissingAPIVersion) || !errors.Is(err, ErrReqNotFound) {
+		t.Fatalf("expected errors %v and %v, got %v", ErrMissingAPIVersion, ErrReqNotFound, err)
+	}
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.Stage != StageLookup {
+		t.Fatalf("expected a requirement error at stage %q, got %v", StageLookup, err)
+	}
+
+	req, err := pluginInfoRequirement("libjson.so", &PluginInfo{Name: "json", Version: "0.7.0", RequiredAPIVersion: "3.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Version != "3.0.0" {
+		t.Fatalf("expected version %q, got %q", "3.0.0", req.Version)
+	}
+}
+
+func TestPluginInfoRequirementPrerelease(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		opts       []RequirementOption
+		expected   string
+	}{
+		{"kept by default", "3.0.0-rc1", nil, "3.0.0-rc1"},
+		{"stripped", "3.0.0-rc1", []RequirementOption{WithStripAPIPrerelease()}, "3.0.0"},
+		{"build metadata kept", "3.0.0-rc1+sdk", []RequirementOption{WithStripAPIPrerelease()}, "3.0.0+sdk"},
+		{"stable untouched", "3.0.0", []RequirementOption{WithStripAPIPrerelease()}, "3.0.0"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := pluginInfoRequirement("libjson.so", &PluginInfo{RequiredAPIVersion: tt.apiVersion}, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.Version != tt.expected {
+				t.Fatalf("expected version %q, got %q", tt.expected, req.Version)
+			}
+		})
+	}
+}
+
+func TestStrictRequirementNames(t *testing.T) {
+	t.Parallel()
+
+	// Strict mode is honored whatever the requirement is read from.
+	if _, err := rulesfileRequirementFromReader(strings.NewReader("- required_engine_version: 0.31.0\n"), WithStrictRequirementNames()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pluginInfoRequirement("plugin.so", &PluginInfo{RequiredAPIVersion: "3.0.0"}, WithStrictRequirementNames()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// largeRulesfile returns a rulesfile of about 4MiB, as big as the largest bundled ones, declaring its plugin
+// requirements only at its end. The engine requirement is deliberately not declared: a near miss, missing the colon,
+// is written at the end instead, so that the whole file is scanned before it is found.
+func largeRulesfile(b *testing.B) string {
+	b.Helper()
+
+	var buf strings.Builder
+	for i := 0; buf.Len() < 4<<20; i++ {
+		fmt.Fprintf(&buf, "- rule: rule_%d\n  desc: a rule checking the %d-th condition of the benchmark\n  condition: evt.type = open and fd.name = /tmp/file_%d\n  output: file opened (file=%%fd.name)\n  priority: WARNING\n", i, i, i)
+	}
+	buf.WriteString("- required_plugin_versions:\n  - name: k8saudit\n    version: 0.7.0\n")
+	buf.WriteString("-  required_engine_version 0.31.0\n")
+
+	return writeRulesfile(b, buf.String())
+}
+
+func BenchmarkReqNotFoundError(b *testing.B) {
+	data, err := os.ReadFile(largeRulesfile(b))
+	if err != nil {
+		b.Fatalf("unable to read rulesfile: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := reqNotFoundError("rules.yaml", bytes.NewReader(data), RulesEngineKey, defaultMaxLineLength); !errors.Is(err, ErrReqNotFound) {
+			b.Fatalf("expected error %v, got %v", ErrReqNotFound, err)
+		}
+	}
+}
+
+func BenchmarkRulesfileDependencies(b *testing.B) {
+	filePath := largeRulesfile(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rulesfileDependencies(filePath); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkRulesfileRequirement(b *testing.B) {
+	filePath := largeRulesfile(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rulesfileRequirement(filePath); !errors.Is(err, ErrReqNotFound) {
+			b.Fatalf("expected error %v, got %v", ErrReqNotFound, err)
+		}
+	}
+}
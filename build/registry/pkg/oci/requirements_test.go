@@ -0,0 +1,764 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blang/semver"
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Requirements", func() {
+	It("should extract the engine requirement of a rulesfile", func() {
+		req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 0.31.0\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Name).To(Equal("engine_version_semver"))
+		Expect(req.Version).To(Equal("0.31.0"))
+	})
+
+	It("should return the parsed version of the engine requirement of a rulesfile", func() {
+		filePath := writeRulesfile("- required_engine_version: 10\n- required_engine_version: 0.31.0\n")
+		req, reqVer, err := oci.RulesfileRequirementVersion(filePath, oci.WithRequirementPolicy(oci.PolicyMax))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+		Expect(reqVer).ToNot(BeNil())
+		Expect(reqVer.String()).To(Equal(req.Version))
+		Expect(reqVer.Minor).To(Equal(uint64(31)))
+
+		// Ranges have no single version.
+		req, reqVer, err = oci.RulesfileRequirementVersion(writeRulesfile("- required_engine_version: \">=0.31.0\"\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal(">=0.31.0"))
+		Expect(reqVer).To(BeNil())
+	})
+
+	It("should fail with a requirement error for missing plugins", func() {
+		_, err := oci.PluginRequirement(filepath.Join(GinkgoT().TempDir(), "libmissing.so"))
+		var reqErr *oci.RequirementError
+		Expect(errors.As(err, &reqErr)).To(BeTrue())
+		Expect(reqErr.Stage).To(Equal(oci.StageOpen))
+		Expect(errors.Is(err, oci.ErrOpenFailed)).To(BeTrue())
+	})
+
+	It("should fail with a requirement error for the info of missing plugins", func() {
+		_, err := oci.LoadPluginInfo(filepath.Join(GinkgoT().TempDir(), "missing.so"))
+		var reqErr *oci.RequirementError
+		Expect(errors.As(err, &reqErr)).To(BeTrue())
+		Expect(reqErr.Stage).To(Equal(oci.StageOpen))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+	})
+})
+
+var _ = Describe("Rulesfile requirement", func() {
+	It("should select one of multiple requirements with the policy", func() {
+		filePath := writeRulesfile(`- required_engine_version: 10
+
+- rule: first
+  condition: evt.type = open
+
+- required_engine_version: 12
+`)
+
+		req, err := oci.RulesfileRequirement(filePath)
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.10.0"))
+
+		req, err = oci.RulesfileRequirement(filePath, oci.WithRequirementPolicy(oci.PolicyMax))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.12.0"))
+	})
+
+	It("should fail for requirements with conflicting major versions", func() {
+		filePath := writeRulesfile("- required_engine_version: 10\n- required_engine_version: 1.0.0\n")
+		_, err := oci.RulesfileRequirement(filePath, oci.WithRequirementPolicy(oci.PolicyMax))
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("should ignore the requirements in comments and strings", func() {
+		filePath := writeRulesfile(`# - required_engine_version: 99
+- required_engine_version: 10
+
+- rule: first
+  desc: "mentions - required_engine_version: 42 in a string"
+  condition: evt.type = open
+`)
+
+		// A single requirement is found, otherwise the policy would fail.
+		req, err := oci.RulesfileRequirement(filePath, oci.WithRequirementPolicy(oci.PolicyError))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.10.0"))
+	})
+
+	It("should find the requirement in any document", func() {
+		req, err := oci.RulesfileRequirement(writeRulesfile(`- rule: first
+  condition: evt.type = open
+---
+- required_engine_version: 12
+---
+`))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.12.0"))
+	})
+
+	It("should detect the compression from the content", func() {
+		// The extension does not matter.
+		req, err := oci.RulesfileRequirement(writeRulesfile(string(gzipData([]byte("- required_engine_version: 10\n")))))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.10.0"))
+	})
+
+	DescribeTable("should unquote the versions",
+		func(content, expected string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile(content))
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+		},
+		Entry("unquoted", "- required_engine_version: 0.31.0\n", "0.31.0"),
+		Entry("single-quoted", "- required_engine_version: '0.31.0'\n", "0.31.0"),
+		Entry("double-quoted", "- required_engine_version: \"0.31.0\"\n", "0.31.0"),
+		Entry("quoted-spaces", "- required_engine_version: \" 0.31.0 \"\n", "0.31.0"),
+		Entry("nested-quotes", "- required_engine_version: \"'0.31.0'\"\n", "0.31.0"),
+		Entry("bare-integer", "- required_engine_version: 10\n", "0.10.0"),
+		Entry("quoted-integer", "- required_engine_version: \"10\"\n", "0.10.0"),
+	)
+
+	DescribeTable("should strip the version prefix",
+		func(value, expected string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: " + value + "\n"))
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+		},
+		Entry("lowercase", "v0.31.0", "0.31.0"),
+		Entry("uppercase", "V0.31.0", "0.31.0"),
+		Entry("bare number", "v10", "0.10.0"),
+		Entry("no prefix", "0.31.0", "0.31.0"),
+	)
+
+	DescribeTable("should handle carriage returns",
+		func(content string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile(content))
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal("0.10.0"))
+		},
+		Entry("bare", "- required_engine_version: 10\r\n- rule: first\r\n  condition: evt.type = open\r\n"),
+		Entry("semver", "- required_engine_version: 0.10.0\r\n"),
+		Entry("quoted", "- required_engine_version: \"0.10.0\"\r\n"),
+		Entry("no eol", "- rule: first\r\n- required_engine_version: 10"),
+	)
+
+	It("should report the near miss without the trailing carriage return", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_versions: 10\r\n"))
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		Expect(err.Error()).ToNot(ContainSubstring(`\r`))
+	})
+
+	DescribeTable("should fail with the stage and the file",
+		func(content string, sentinel error, stage oci.RequirementStage) {
+			filePath := filepath.Join(GinkgoT().TempDir(), "missing.yaml")
+			if content != "" {
+				filePath = writeRulesfile(content)
+			}
+
+			_, err := oci.RulesfileRequirement(filePath)
+			Expect(err).To(MatchError(sentinel))
+			var reqErr *oci.RequirementError
+			Expect(errors.As(err, &reqErr)).To(BeTrue())
+			Expect(reqErr.Stage).To(Equal(stage))
+			Expect(reqErr.FilePath).To(Equal(filePath))
+		},
+		Entry("missing file", "", oci.ErrOpenFailed, oci.StageOpen),
+		Entry("invalid yaml", "- rule: [\n", oci.ErrParseFailed, oci.StageDecode),
+		Entry("invalid version", "- required_engine_version: abc\n", oci.ErrParseFailed, oci.StageParse),
+		Entry("no requirement", "- rule: first\n", oci.ErrReqNotFound, oci.StageLookup),
+	)
+
+	It("should wrap the open error", func() {
+		filePath := filepath.Join(GinkgoT().TempDir(), "missing.yaml")
+		_, expected := os.Open(filePath)
+
+		_, err := oci.RulesfileRequirement(filePath)
+		Expect(err).To(MatchError(os.ErrNotExist))
+		Expect(err.Error()).To(ContainSubstring(expected.Error()))
+	})
+
+	It("should report the near miss with the scan context", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile(`- rule: first
+  condition: evt.type = open
+
+- required_engine_versions: 10
+`))
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		Expect(err.Error()).To(ContainSubstring("4 lines scanned"))
+		Expect(err.Error()).To(ContainSubstring("near miss at line 4"))
+	})
+
+	It("should report the first near miss after scanning all the lines", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile(`- rule: first
+- required_engine_versions: 10
+- required_engine_versions: 12
+`))
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		Expect(err.Error()).To(ContainSubstring(`(3 lines scanned, near miss at line 2: "- required_engine_versions: 10")`))
+	})
+
+	DescribeTable("should report the requirements without a value",
+		func(content string, sentinel error, line string) {
+			filePath := writeRulesfile(content)
+			_, err := oci.RulesfileRequirement(filePath)
+			Expect(err).To(MatchError(sentinel))
+			Expect(err.Error()).To(ContainSubstring(filePath))
+			Expect(err.Error()).To(ContainSubstring(line))
+		},
+		Entry("no value", "- rule: first\n- required_engine_version\n", oci.ErrReqNotFound, "near miss at line 2"),
+		Entry("empty value", "- rule: first\n- required_engine_version:\n", oci.ErrParseFailed, "line 2:"),
+		Entry("blank value", "- rule: first\n- required_engine_version: \"  \"\n", oci.ErrParseFailed, "line 2:"),
+		Entry("list value", "- rule: first\n- required_engine_version: [15]\n", oci.ErrParseFailed, "line 2:"),
+	)
+
+	It("should stop the scan for near misses at long lines", func() {
+		// A long line without any requirement stops the scan, unless the limit is raised.
+		filePath := writeRulesfile("- rule: open\n  desc: " + strings.Repeat("a", 100) + "\n  condition: evt.type = open\n- required_engine_versions: 0.31.0\n")
+
+		_, err := oci.RulesfileRequirement(filePath, oci.WithMaxLineLength(64))
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+		Expect(err.Error()).To(ContainSubstring("scan stopped at line 2"))
+
+		// Zero or a negative length falls back to the default.
+		for _, length := range []int{1024, 0, -1} {
+			_, err = oci.RulesfileRequirement(filePath, oci.WithMaxLineLength(length))
+			Expect(err).To(MatchError(oci.ErrReqNotFound))
+			Expect(err.Error()).To(ContainSubstring("near miss at line 4"), "length %d", length)
+		}
+	})
+
+	It("should fail for the rulesfiles bigger than the limit", func() {
+		content := "- required_engine_version: 0.31.0\n" + strings.Repeat("# padding\n", 100)
+		filePath := writeRulesfile(content)
+
+		_, err := oci.RulesfileRequirement(filePath, oci.WithMaxFileSize(64))
+		Expect(err).To(MatchError(oci.ErrFileTooLarge))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		_, errs := oci.BatchRequirements([]string{filePath}, 1, oci.WithBatchRequirementOptions(oci.WithMaxFileSize(64)))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0]).To(MatchError(oci.ErrFileTooLarge))
+		_, err = oci.RulesfileRequirement(filePath, oci.WithMaxFileSize(0))
+		Expect(err).To(BeNil())
+
+		// The limit applies to the decompressed content too.
+		compressed := gzipData([]byte(content))
+		gzipPath := filepath.Join(GinkgoT().TempDir(), "rules.yaml.gz")
+		Expect(os.WriteFile(gzipPath, compressed, 0o600)).To(Succeed())
+		_, err = oci.RulesfileRequirement(gzipPath, oci.WithMaxFileSize(int64(len(compressed))+1))
+		Expect(err).To(MatchError(oci.ErrFileTooLarge))
+	})
+
+	It("should extract ranges", func() {
+		req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: \">=0.31.0 <0.40.0\"\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal(">=0.31.0 <0.40.0"))
+
+		_, err = oci.RulesfileRequirement(writeRulesfile("- required_engine_version: \">=0.31.0 <<0.40.0\"\n"))
+		Expect(err).To(MatchError(oci.ErrParseFailed))
+	})
+
+	DescribeTable("should combine the engine bounds",
+		func(content, expected string, warning bool) {
+			collector := &oci.WarningCollector{}
+			req, err := oci.RulesfileRequirement(writeRulesfile(content), oci.WithStrictKeys(), oci.WithWarningCollector(collector))
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+
+			warnings := collector.Warnings()
+			if !warning {
+				Expect(warnings).To(BeEmpty())
+				return
+			}
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].Err).To(MatchError(oci.ErrRedundantEngineVersion))
+		},
+		Entry("single", "- required_engine_version: 0.31.0\n", "0.31.0", false),
+		Entry("pair", "- required_engine_version_min: 0.31.0\n- required_engine_version_max: 0.40.0\n", ">=0.31.0 <=0.40.0", false),
+		Entry("min only", "- required_engine_version_min: 0.31.0\n", ">=0.31.0", false),
+		Entry("max only", "- required_engine_version_max: 0.40.0\n", "<=0.40.0", false),
+		Entry("both", "- required_engine_version: 0.35.0\n- required_engine_version_min: 0.31.0\n- required_engine_version_max: 0.40.0\n",
+			">=0.31.0 <=0.40.0", true),
+	)
+
+	It("should reject the bounds that are not single versions", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version_min: \">=0.31.0\"\n"))
+		Expect(err).To(MatchError(oci.ErrParseFailed))
+	})
+
+	It("should extract the requirement declared with a custom key", func() {
+		filePath := writeRulesfile("- required_engine_version: 10\n- required_fork_version: 12\n")
+		req, err := oci.RulesfileRequirement(filePath, oci.WithRulesEngineKey("required_fork_version"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.12.0"))
+
+		// The default key is still used by the extractions without the option.
+		req, err = oci.RulesfileRequirement(filePath)
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.10.0"))
+
+		// The bounds follow the key, and so do the keys allowed in strict mode.
+		filePath = writeRulesfile("- required_fork_version_min: 0.31.0\n- required_fork_version_max: 0.40.0\n")
+		req, err = oci.RulesfileRequirement(filePath, oci.WithRulesEngineKey("required_fork_version"), oci.WithStrictKeys())
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal(">=0.31.0 <=0.40.0"))
+
+		// Near misses are looked for with the key too.
+		_, err = oci.RulesfileRequirement(writeRulesfile("- rule: open\n-  required_fork_version 12\n"), oci.WithRulesEngineKey("required_fork_version"))
+		Expect(err).To(MatchError(ContainSubstring("near miss at line 2")))
+	})
+
+	DescribeTable("should select the requirement with the policy",
+		func(content string, opts []oci.RequirementOption, expected string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile(content), opts...)
+			if expected == "" {
+				Expect(err).To(MatchError(oci.ErrParseFailed))
+				return
+			}
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+		},
+		Entry("default", multipleRequirements, nil, "0.12.0"),
+		Entry("max", multipleRequirements, []oci.RequirementOption{oci.WithRequirementPolicy(oci.PolicyMax)}, "0.15.0"),
+		Entry("first wins", multipleRequirements, []oci.RequirementOption{oci.WithRequirementPolicy(oci.PolicyFirstWins)}, "0.12.0"),
+		Entry("error on multiple", multipleRequirements, []oci.RequirementOption{oci.WithRequirementPolicy(oci.PolicyError)}, ""),
+		Entry("error on single", "- required_engine_version: 12\n", []oci.RequirementOption{oci.WithRequirementPolicy(oci.PolicyError)}, "0.12.0"),
+	)
+
+	// The coercions of bare numbers are pinned: changing them would silently change the requirements of all the
+	// rulesfiles using bare numbers.
+	DescribeTable("should coerce the bare versions",
+		func(opts []oci.RequirementOption, expected string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 15\n"), opts...)
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+		},
+		Entry("default", nil, "0.15.0"),
+		Entry("minor", []oci.RequirementOption{oci.WithBareVersionCoercion(oci.CoerceToMinor)}, "0.15.0"),
+		Entry("major", []oci.RequirementOption{oci.WithBareVersionCoercion(oci.CoerceToMajor)}, "15.0.0"),
+		Entry("prerelease permissive", []oci.RequirementOption{oci.WithPrereleasePermissive()}, "0.15.0-0"),
+		Entry("major prerelease permissive",
+			[]oci.RequirementOption{oci.WithBareVersionCoercion(oci.CoerceToMajor), oci.WithPrereleasePermissive()}, "15.0.0-0"),
+	)
+
+	It("should never coerce the semver versions", func() {
+		for _, opt := range []oci.RequirementOption{oci.WithBareVersionCoercion(oci.CoerceToMajor), oci.WithPrereleasePermissive()} {
+			req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 0.15.0\n"), opt)
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal("0.15.0"))
+		}
+
+		// The prereleases of the coerced version satisfy the permissive requirement only.
+		prerelease := semver.MustParse("0.15.0-rc1")
+		Expect(semver.MustParseRange(">=0.15.0")(prerelease)).To(BeFalse())
+		Expect(semver.MustParseRange(">=0.15.0-0")(prerelease)).To(BeTrue())
+	})
+
+	It("should keep the major.minor versions decoded as floats as declared", func() {
+		req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 0.31\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+	})
+
+	DescribeTable("should reject the build metadata unless kept",
+		func(version string, opts []oci.RequirementOption, expected string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: "+version+"\n"), opts...)
+			if expected == "" {
+				Expect(err).To(MatchError(oci.ErrParseFailed))
+				return
+			}
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal(expected))
+		},
+		Entry("release", "0.31.0", nil, "0.31.0"),
+		Entry("pre-release", "0.31.0-rc1", nil, "0.31.0-rc1"),
+		Entry("build", "0.31.0+build123", nil, ""),
+		Entry("pre-release build", "0.31.0-rc1+build123", nil, ""),
+		Entry("kept build", "0.31.0+build123", []oci.RequirementOption{oci.WithKeepBuildMetadata()}, "0.31.0+build123"),
+		Entry("kept pre-release build", "0.31.0-rc1+build123", []oci.RequirementOption{oci.WithKeepBuildMetadata()}, "0.31.0-rc1+build123"),
+	)
+
+	DescribeTable("should report the overlays without requirements",
+		func(content string, opts []oci.RequirementOption, overlay bool) {
+			_, err := oci.RulesfileRequirement(writeRulesfile(content), opts...)
+			Expect(err).To(MatchError(oci.ErrReqNotFound))
+			Expect(errors.Is(err, oci.ErrOverlay)).To(Equal(overlay), "unexpected error: %v", err)
+		},
+		Entry("append", `- rule: first
+  append: true
+  condition: and evt.type = open
+- list: names
+  append: true
+  items: [bash]
+`, nil, true),
+		Entry("override", `- macro: first
+  override:
+    condition: replace
+  condition: evt.type = open
+`, nil, true),
+		Entry("new rule", `- rule: first
+  append: true
+  condition: and evt.type = open
+- rule: second
+  condition: evt.type = open
+`, nil, false),
+		Entry("explicit overlay", `- rule: second
+  condition: evt.type = open
+`, []oci.RequirementOption{oci.WithOverlay()}, true),
+		Entry("no objects", `- required_plugin_versions:
+  - name: json
+    version: 0.7.0
+`, nil, false),
+	)
+
+	It("should handle the overlays declaring their requirements as any other rulesfile", func() {
+		req, err := oci.RulesfileRequirement(writeRulesfile("- required_engine_version: 15\n- rule: first\n  append: true\n"))
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.15.0"))
+	})
+
+	DescribeTable("should skip the rulesfiles with the marker",
+		func(content string, skipped bool) {
+			req, err := oci.RulesfileRequirement(writeRulesfile(content))
+			if !skipped {
+				Expect(err).To(BeNil())
+				Expect(req.Version).To(Equal("0.15.0"))
+				return
+			}
+			Expect(err).To(MatchError(oci.ErrSkipped))
+			Expect(err).To(MatchError(oci.ErrReqNotFound))
+		},
+		Entry("marker", oci.SkipRequirementsMarker+"\n- required_engine_version: 15\n", true),
+		Entry("trailing whitespace", oci.SkipRequirementsMarker+" \r\n- rule: first\n", true),
+		Entry("only marker", oci.SkipRequirementsMarker, true),
+		Entry("invalid content", oci.SkipRequirementsMarker+"\n{{ .Template }}\n", true),
+		Entry("not on the first line", "- required_engine_version: 15\n"+oci.SkipRequirementsMarker+"\n", false),
+		Entry("indented", "  "+oci.SkipRequirementsMarker+"\n- required_engine_version: 15\n", false),
+		Entry("other text", oci.SkipRequirementsMarker+" please\n- required_engine_version: 15\n", false),
+	)
+
+	DescribeTable("should resolve the anchors",
+		func(content string) {
+			req, err := oci.RulesfileRequirement(writeRulesfile(content))
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal("0.31.0"))
+		},
+		Entry("alias", `- list: versions
+  items:
+    - &base_version 0.31.0
+- required_engine_version: *base_version
+`),
+		Entry("merge", `- macro: base
+  condition: evt.type = open
+  requirements: &base
+    required_engine_version: 0.31.0
+- <<: *base
+`),
+		Entry("multiple merge", `- macro: base
+  condition: evt.type = open
+  requirements: &empty {}
+  other: &base
+    required_engine_version: 0.31.0
+- <<: [*empty, *base]
+`),
+	)
+})
+
+var _ = Describe("Rulesfile plugin requirements", func() {
+	It("should extract the plugin requirements with their alternatives", func() {
+		groups, err := oci.RulesfilePluginRequirementGroups(writeRulesfile(`- required_engine_version: 15
+
+- required_plugin_versions:
+  - name: k8saudit
+    version: 0.7.0
+    alternatives:
+      - name: k8saudit-eks
+        version: 0.4.0
+  - name: json
+    version: 0.7.0
+
+- rule: first
+  condition: evt.type = open
+`))
+		Expect(err).To(BeNil())
+		Expect(groups).To(Equal([]oci.PluginRequirementGroup{
+			{Options: []falcoctloci.ArtifactRequirement{{Name: "k8saudit", Version: "0.7.0"}, {Name: "k8saudit-eks", Version: "0.4.0"}}},
+			{Options: []falcoctloci.ArtifactRequirement{{Name: "json", Version: "0.7.0"}}},
+		}))
+	})
+
+	DescribeTable("should accept all the spellings of the key",
+		func(content string) {
+			filePath := writeRulesfile(content)
+			groups, err := oci.RulesfilePluginRequirementGroups(filePath)
+			Expect(err).To(BeNil())
+			Expect(groups).To(Equal([]oci.PluginRequirementGroup{
+				{Options: []falcoctloci.ArtifactRequirement{{Name: "json", Version: "0.7.0"}}},
+			}))
+
+			// The engine requirement is still extracted.
+			req, err := oci.RulesfileRequirement(filePath)
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal("0.15.0"))
+		},
+		Entry("plural", `- required_engine_version: 15
+- required_plugin_versions:
+  - name: json
+    version: 0.7.0
+`),
+		Entry("singular list", `- required_engine_version: 15
+- required_plugin_version:
+  - name: json
+    version: 0.7.0
+`),
+		Entry("singular mapping", `- required_engine_version: 15
+- required_plugin_version:
+    name: json
+    version: 0.7.0
+`),
+	)
+
+	It("should resolve the aliased plugin requirements", func() {
+		groups, err := oci.RulesfilePluginRequirementGroups(writeRulesfile(`- macro: base
+  condition: evt.type = open
+  plugins: &plugins
+    - name: json
+      version: 0.7.0
+- required_plugin_versions: *plugins
+`))
+		Expect(err).To(BeNil())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].Options[0].Name).To(Equal("json"))
+	})
+
+	It("should be satisfied by any of the alternatives", func() {
+		groups, err := oci.RulesfilePluginRequirementGroups(writeRulesfile(`- required_plugin_versions:
+  - name: k8saudit
+    version: 0.7.0
+    alternatives:
+      - name: k8saudit-eks
+        version: 0.2.0
+`))
+		Expect(err).To(BeNil())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].SatisfiedBy(map[string]string{"k8saudit-eks": "0.3.0"})).To(BeTrue())
+		// An older plugin and an alternative with a different major do not satisfy the group.
+		Expect(groups[0].SatisfiedBy(map[string]string{"k8saudit": "0.6.0", "k8saudit-eks": "1.0.0"})).To(BeFalse())
+	})
+})
+
+var _ = Describe("Plugin requirement", func() {
+	It("should decompress the compressed plugins before loading them", func() {
+		filePath := filepath.Join(GinkgoT().TempDir(), "libfake.so.gz")
+		Expect(os.WriteFile(filePath, gzipData([]byte("not a shared library")), 0o600)).To(Succeed())
+
+		// The plugin has an extractor, and fails to load since it is not a shared library.
+		_, err := oci.ExtractRequirements(filePath)
+		Expect(err).ToNot(MatchError(oci.ErrNoExtractor))
+
+		tmpDir := GinkgoT().TempDir()
+		_, err = oci.PluginRequirement(filePath, oci.WithPluginTempDir(tmpDir))
+		var reqErr *oci.RequirementError
+		Expect(errors.As(err, &reqErr)).To(BeTrue())
+		Expect(reqErr.FilePath).To(Equal(filePath))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		// The temporary file is removed even on error.
+		Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+
+		// The plugin is decompressed in the directory set with WithPluginTempDir.
+		missingDir := filepath.Join(GinkgoT().TempDir(), "missing")
+		_, err = oci.PluginRequirement(filePath, oci.WithPluginTempDir(missingDir))
+		Expect(err).To(MatchError(os.ErrNotExist))
+		Expect(err.Error()).To(ContainSubstring("unable to create temporary file"))
+	})
+
+	It("should detect the compressed plugins by their content", func() {
+		corrupted := filepath.Join(GinkgoT().TempDir(), "libcorrupted.so")
+		Expect(os.WriteFile(corrupted, []byte("\x1f\x8bgarbage"), 0o600)).To(Succeed())
+		_, err := oci.PluginRequirement(corrupted)
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		Expect(err.Error()).To(ContainSubstring("unable to decompress"))
+	})
+
+	It("should fail for the compressed plugins bigger than the limit", func() {
+		// A small archive expanding to a file bigger than the limit.
+		filePath := filepath.Join(GinkgoT().TempDir(), "libbomb.so.gz")
+		Expect(os.WriteFile(filePath, gzipData(make([]byte, 1<<20)), 0o600)).To(Succeed())
+
+		tmpDir := GinkgoT().TempDir()
+		_, err := oci.PluginRequirement(filePath, oci.WithPluginTempDir(tmpDir), oci.WithMaxPluginSize(1<<10))
+		Expect(err).To(MatchError(oci.ErrFileTooLarge))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+		Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+
+		// Without a limit the plugin is decompressed, and fails to load since it is not a shared library.
+		_, err = oci.PluginRequirement(filePath, oci.WithPluginTempDir(tmpDir), oci.WithMaxPluginSize(0))
+		Expect(err).ToNot(MatchError(oci.ErrFileTooLarge))
+		Expect(err).To(MatchError(oci.ErrOpenFailed))
+	})
+})
+
+var _ = Describe("Requirement with digest", func() {
+	It("should return the digest of the file", func() {
+		content := "- required_engine_version: 10\n"
+		req, digest, err := oci.RequirementWithDigest(writeRulesfile(content))
+		Expect(err).To(BeNil())
+
+		sum := sha256.Sum256([]byte(content))
+		Expect(digest).To(Equal(hex.EncodeToString(sum[:])))
+		Expect(req.Version).To(Equal("0.10.0"))
+	})
+})
+
+var _ = Describe("Artifact requirements", func() {
+	It("should keep the highest requirement of the rulesfiles", func() {
+		dir := GinkgoT().TempDir()
+		for name, content := range map[string]string{
+			"a_rules.yaml": "- required_engine_version: 10\n",
+			"b_rules.yaml": "- required_engine_version: 12\n",
+			"README.md":    "# - required_engine_version: 99\n",
+		} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)).To(Succeed())
+		}
+
+		reqs, err := oci.ArtifactRequirements(dir)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.12.0"}}))
+	})
+
+	It("should keep the highest requirement even if the majors differ", func() {
+		// Unlike MergeRequirements, the extraction of the requirements of an artifact keeps the highest version even
+		// if the files disagree on the major version, as it always did.
+		dir := GinkgoT().TempDir()
+		for name, content := range map[string]string{
+			"old_rules.yaml": "- required_engine_version: 0.31.0\n",
+			"new_rules.yaml": "- required_engine_version: 1.0.0\n",
+		} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)).To(Succeed())
+		}
+
+		expected := []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "1.0.0"}}
+		reqs, err := oci.ArtifactRequirements(dir)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal(expected))
+		reqs, err = oci.PulledArtifactRequirements(dir)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal(expected))
+	})
+})
+
+var _ = Describe("Pack engine requirement", func() {
+	It("should fail for the rulesfiles without requirements unless skipped", func() {
+		files := []string{
+			writeRulesfile("- required_engine_version: 12\n"),
+			writeRulesfile("- rule: first\n"),
+			writeRulesfile("- required_engine_version: 0.31.0\n"),
+		}
+
+		_, err := oci.PackEngineRequirement(files)
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+
+		req, err := oci.PackEngineRequirement(files, oci.WithSkipMissing())
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+	})
+})
+
+var _ = Describe("Effective requirements", func() {
+	It("should merge the requirements of the plugin and of the rulesfiles", func() {
+		files := []string{
+			writeRulesfile("- required_engine_version: 12\n"),
+			writeRulesfile("- required_engine_version: 0.31.0\n"),
+		}
+
+		reqs, err := oci.EffectiveRequirements("", files)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.31.0"}}))
+
+		_, err = oci.EffectiveRequirements("", nil)
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+
+		missing := filepath.Join(GinkgoT().TempDir(), "missing.so")
+		_, err = oci.EffectiveRequirements(missing, files)
+		var reqErr *oci.RequirementError
+		Expect(errors.As(err, &reqErr)).To(BeTrue())
+		Expect(reqErr.FilePath).To(Equal(missing))
+	})
+})
+
+var _ = Describe("Merge requirements", func() {
+	rulesfiles := []falcoctloci.ArtifactRequirement{
+		{Name: common.EngineVersionKey, Version: "0.31.0"},
+		{Name: common.PluginAPIVersion, Version: "3.2.0"},
+	}
+
+	It("should keep the highest requirement of each name", func() {
+		merged, err := oci.MergeRequirements([]falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "3.0.0"}}, rulesfiles)
+		Expect(err).To(BeNil())
+		Expect(merged).To(Equal([]falcoctloci.ArtifactRequirement{
+			{Name: common.EngineVersionKey, Version: "0.31.0"},
+			{Name: common.PluginAPIVersion, Version: "3.2.0"},
+		}))
+	})
+
+	DescribeTable("should fail for the conflicting requirements",
+		func(set []falcoctloci.ArtifactRequirement) {
+			_, err := oci.MergeRequirements(rulesfiles, set)
+			Expect(err).To(MatchError(oci.ErrConflictingRequirements))
+		},
+		Entry("different major", []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "2.0.0"}}),
+		Entry("range", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: ">=0.31.0"}}),
+	)
+})
+
+var _ = Describe("Sort requirements", func() {
+	It("should sort by name and then by version", func() {
+		reqs := []falcoctloci.ArtifactRequirement{
+			{Name: "plugin_api_version", Version: "3.0.0"},
+			{Name: "engine_version_semver", Version: ">=0.31.0"},
+			{Name: "engine_version_semver", Version: "0.10.0"},
+			{Name: "engine_version_semver", Version: "0.9.0"},
+		}
+		oci.SortRequirements(reqs)
+
+		Expect(reqs).To(Equal([]falcoctloci.ArtifactRequirement{
+			{Name: "engine_version_semver", Version: "0.9.0"},
+			{Name: "engine_version_semver", Version: "0.10.0"},
+			{Name: "engine_version_semver", Version: ">=0.31.0"},
+			{Name: "plugin_api_version", Version: "3.0.0"},
+		}))
+	})
+})
+
+// multipleRequirements is a rulesfile declaring several engine requirements, the highest being neither the first nor
+// the last.
+const multipleRequirements = "- required_engine_version: 12\n- required_engine_version: 0.15.0\n- required_engine_version: 10\n"
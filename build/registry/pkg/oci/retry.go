@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	defaultPushMaxAttempts = 5
+	defaultPushBackoff     = time.Second
+	maxPushBackoff         = 30 * time.Second
+)
+
+// PushOption is a functional option for the push of artifacts to the OCI registry.
+type PushOption func(*pushOptions)
+
+type pushOptions struct {
+	maxAttempts int
+	timeout     time.Duration
+	backoff     time.Duration
+	// failOnEngineDowngrade makes a decreased engine version requirement an error rather than a warning.
+	failOnEngineDowngrade bool
+	// signingKey is the file of the private key the pushed artifacts are signed with, if not empty.
+	signingKey string
+	// registry the artifacts are pushed to, if nil the one of the configuration is used.
+	registry Registry
+	// reqOpts are the options the requirements of the pushed artifacts are extracted with.
+	reqOpts []RequirementOption
+}
+
+// WithPushMaxAttempts sets the maximum number of attempts to push an artifact, including the first one.
+func WithPushMaxAttempts(attempts int) PushOption {
+	return func(o *pushOptions) {
+		o.maxAttempts = attempts
+	}
+}
+
+// WithPushTimeout sets the timeout of each attempt to push an artifact. No timeout is set if zero.
+func WithPushTimeout(timeout time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithPushBackoff sets the time to wait before retrying a failed push. It is doubled after each attempt.
+func WithPushBackoff(backoff time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.backoff = backoff
+	}
+}
+
+// WithFailOnEngineDowngrade makes the push of a rulesfile fail if it requires an older engine version than the
+// previously published one, see CheckEngineRequirementMonotonic. By default a warning is logged.
+func WithFailOnEngineDowngrade() PushOption {
+	return func(o *pushOptions) {
+		o.failOnEngineDowngrade = true
+	}
+}
+
+// WithSigningKey signs each pushed artifact with the private key in the given file, see SignArtifact.
+func WithSigningKey(keyRef string) PushOption {
+	return func(o *pushOptions) {
+		o.signingKey = keyRef
+	}
+}
+
+// WithRegistry pushes the artifacts to the given registry, rather than to the one reached with the credentials of
+// the configuration, e.g. to push them to a local registry in tests.
+func WithRegistry(reg Registry) PushOption {
+	return func(o *pushOptions) {
+		o.registry = reg
+	}
+}
+
+// WithPushRequirementOptions extracts the requirements of the artifacts with the given options, both when pushing
+// them and when regenerating their config blobs. The logger and the warning collector of the options, see WithLogger
+// and WithWarningCollector, are also the ones used by the push.
+func WithPushRequirementOptions(opts ...RequirementOption) PushOption {
+	return func(o *pushOptions) {
+		o.reqOpts = append(o.reqOpts, opts...)
+	}
+}
+
+// newPushOptions returns the pushOptions resulting from applying opts to the defaults.
+func newPushOptions(opts []PushOption) *pushOptions {
+	o := &pushOptions{
+		maxAttempts: defaultPushMaxAttempts,
+		backoff:     defaultPushBackoff,
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	return o
+}
+
+// log returns the logger of the requirement options of the push.
+func (o *pushOptions) log() *slog.Logger {
+	return newRequirementOptions(o.reqOpts).log()
+}
+
+// warn records a warning about the given file in the warning collector of the requirement options of the push.
+func (o *pushOptions) warn(file string, err error) {
+	newRequirementOptions(o.reqOpts).warn(file, err)
+}
+
+// retryPush calls push until it succeeds, it fails with a permanent error, or the maximum number of attempts is
+// reached, waiting an exponential backoff between the attempts. See isRetryable for the errors that are retried.
+func retryPush[T any](ctx context.Context, o *pushOptions, ref string, push func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	var err error
+	backoff := o.backoff
+
+	for attempt := 1; ; attempt++ {
+		res, err = pushAttempt(ctx, o.timeout, push)
+		if err == nil {
+			return res, nil
+		}
+
+		if attempt >= o.maxAttempts || !isRetryable(ctx, err) {
+			return res, fmt.Errorf("push of %q failed after %d attempts: %w", ref, attempt, err)
+		}
+
+		o.log().Warn("push failed, retrying", "ref", ref, "attempt", attempt, "maxAttempts", o.maxAttempts, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return res, fmt.Errorf("push of %q canceled after %d attempts: %w", ref, attempt, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, maxPushBackoff)
+	}
+}
+
+// pushAttempt calls push once, with the given timeout if not zero.
+func pushAttempt[T any](ctx context.Context, timeout time.Duration, push func(ctx context.Context) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return push(ctx)
+}
+
+// isRetryable returns true if the error returned by a push is transient: network errors, including the
+// timeout of an attempt, throttling and server side errors of the registry. Any other error, such as
+// authentication or authorization errors, is permanent since retrying would fail the same way.
+func isRetryable(ctx context.Context, err error) bool {
+	// The push has been canceled by the caller.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var respErr *errcode.ErrorResponse
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode == http.StatusTooManyRequests || respErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestRetryPush(t *testing.T) {
+	t.Parallel()
+
+	transient := fmt.Errorf("push failed: %w", &errcode.ErrorResponse{StatusCode: http.StatusServiceUnavailable})
+	permanent := fmt.Errorf("push failed: %w", &errcode.ErrorResponse{StatusCode: http.StatusUnauthorized})
+
+	tests := map[string]struct {
+		errs     []error
+		attempts int
+		fail     bool
+	}{
+		"success":          {attempts: 1},
+		"transient":        {errs: []error{transient, transient}, attempts: 3},
+		"throttled":        {errs: []error{&errcode.ErrorResponse{StatusCode: http.StatusTooManyRequests}}, attempts: 2},
+		"attempt timeout":  {errs: []error{context.DeadlineExceeded}, attempts: 2},
+		"permanent":        {errs: []error{permanent}, attempts: 1, fail: true},
+		"unknown":          {errs: []error{errors.New("invalid artifact")}, attempts: 1, fail: true},
+		"too many retries": {errs: []error{transient, transient, transient, transient}, attempts: 3, fail: true},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts int
+			o := newPushOptions([]PushOption{WithPushMaxAttempts(3), WithPushBackoff(time.Millisecond)})
+			res, err := retryPush(context.Background(), o, "ref", func(ctx context.Context) (string, error) {
+				attempts++
+				if attempts <= len(test.errs) {
+					return "", test.errs[attempts-1]
+				}
+				return "digest", nil
+			})
+
+			if attempts != test.attempts {
+				t.Fatalf("expected %d attempts, got %d", test.attempts, attempts)
+			}
+			if test.fail {
+				if !errors.Is(err, test.errs[attempts-1]) {
+					t.Fatalf("expected the last error to be wrapped, got %v", err)
+				}
+				return
+			}
+			if err != nil || res != "digest" {
+				t.Fatalf("unexpected result %q, error: %v", res, err)
+			}
+		})
+	}
+}
+
+func TestRetryPushCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	_, err := retryPush(ctx, newPushOptions([]PushOption{WithPushBackoff(time.Hour)}), "ref", func(ctx context.Context) (string, error) {
+		attempts++
+		cancel()
+		return "", &errcode.ErrorResponse{StatusCode: http.StatusBadGateway}
+	})
+
+	if attempts != 1 || err == nil {
+		t.Fatalf("expected a single failed attempt, got %d attempts, error: %v", attempts, err)
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetEngineRequirement given a rulesfile in yaml format it sets the engine version it requires, rewriting in place
+// only the values of the required_engine_version keys, so that comments, formatting and ordering of the rulesfile are
+// preserved. Every engine requirement declared by the rulesfile is updated, quoted values are kept quoted. An error
+// wrapping ErrReqNotFound is returned if the rulesfile does not declare any. Compressed rulesfiles are not supported.
+// The key of the engine requirements can be set with WithRulesEngineKey.
+func SetEngineRequirement(filePath, version string, opts ...RequirementOption) error {
+	o := newRequirementOptions(opts)
+	if _, err := normalizeEngineRequirement(version, o); err != nil {
+		return fmt.Errorf("invalid engine version %q: %w", version, err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to open file %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to read file %q: %w: %w", filePath, ErrOpenFailed, err))
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to edit compressed rulesfile %q: %w", filePath, ErrOpenFailed))
+	}
+
+	docs, err := decodeRulesfileDocuments(filePath, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	var values []*yaml.Node
+	for _, doc := range docs {
+		values = append(values, engineRequirementNodes(doc, o.engineKey)...)
+	}
+	if len(values) == 0 {
+		return reqNotFoundError(filePath, bytes.NewReader(data), o.engineKey, defaultMaxLineLength)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	// Edit the values from the last one, so that editing a line does not shift the columns of the values before it.
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Line != values[j].Line {
+			return values[i].Line > values[j].Line
+		}
+		return values[i].Column > values[j].Column
+	})
+	for _, node := range values {
+		if err := setScalarInPlace(lines, node, version); err != nil {
+			return newRequirementError(filePath, StageParse, fmt.Errorf("rulesfile %q, line %d: %s %w: %w", filePath, node.Line, o.engineKey, err, ErrParseFailed))
+		}
+	}
+
+	if err := os.WriteFile(filePath, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("unable to write file %q: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// engineRequirementNodes returns the values of the engine requirements declared with the given key by the items of
+// the given document.
+func engineRequirementNodes(doc *yaml.Node, engineKey string) []*yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var values []*yaml.Node
+	for _, item := range doc.Content[0].Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		for k := 0; k+1 < len(item.Content); k += 2 {
+			if item.Content[k].Value == engineKey {
+				values = append(values, item.Content[k+1])
+			}
+		}
+	}
+
+	return values
+}
+
+// setScalarInPlace replaces the text of the given scalar node, as found in lines, with value. The node must be a
+// single line plain, single or double quoted scalar, whose quoting style is kept.
+func setScalarInPlace(lines []string, node *yaml.Node, value string) error {
+	if node.Kind != yaml.ScalarNode || strings.TrimSpace(node.Value) == "" {
+		return errors.New("has no value that can be set in place")
+	}
+	if node.Line < 1 || node.Line > len(lines) {
+		return errors.New("value out of the rulesfile")
+	}
+
+	line := lines[node.Line-1]
+	start := node.Column - 1
+	if start < 0 || start >= len(line) {
+		return errors.New("value out of the rulesfile")
+	}
+
+	var end int
+	switch node.Style {
+	case 0:
+		end = start + len(node.Value)
+		value = strings.TrimSpace(value)
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := line[start]
+		closing := strings.IndexByte(line[start+1:], quote)
+		if closing < 0 {
+			return errors.New("value spans multiple lines")
+		}
+		end = start + 1 + closing + 1
+		value = string(quote) + value + string(quote)
+	default:
+		return errors.New("value has a style that can not be set in place")
+	}
+	if end > len(line) || (node.Style == 0 && line[start:end] != node.Value) {
+		return errors.New("value spans multiple lines")
+	}
+
+	lines[node.Line-1] = line[:start] + value + line[end:]
+	return nil
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Set engine requirement", func() {
+	DescribeTable("should edit the requirement in place",
+		func(content, expected string) {
+			filePath := writeRulesfile(content)
+			Expect(oci.SetEngineRequirement(filePath, "0.31.0")).To(Succeed())
+			data, err := os.ReadFile(filePath)
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal(expected))
+
+			// The extractor reads back the new version.
+			req, err := oci.RulesfileRequirement(filePath)
+			Expect(err).To(BeNil())
+			Expect(req.Version).To(Equal("0.31.0"))
+		},
+		Entry("plain", `# Rules for the network.
+- required_engine_version: 0.26.0 # bumped for the new fields
+
+- rule: open
+  # Keys are not reordered.
+  output: open
+  condition: evt.type = open
+`, `# Rules for the network.
+- required_engine_version: 0.31.0 # bumped for the new fields
+
+- rule: open
+  # Keys are not reordered.
+  output: open
+  condition: evt.type = open
+`),
+		Entry("quoted", "- required_engine_version: \"10\"\n", "- required_engine_version: \"0.31.0\"\n"),
+		Entry("multiple documents", "- required_engine_version: 10\n---\n- required_engine_version: '0.26.0'\n",
+			"- required_engine_version: 0.31.0\n---\n- required_engine_version: '0.31.0'\n"),
+	)
+
+	It("should keep the json format", func() {
+		filePath := filepath.Join(GinkgoT().TempDir(), "rules.json")
+		Expect(os.WriteFile(filePath, []byte(jsonRulesfile), 0o600)).To(Succeed())
+
+		Expect(oci.SetEngineRequirement(filePath, "0.32.0")).To(Succeed())
+		data, err := os.ReadFile(filePath)
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(strings.Replace(jsonRulesfile, "0.31.0", "0.32.0", 1)))
+	})
+
+	It("should fail for the rulesfiles without requirement", func() {
+		filePath := writeRulesfile("- rule: open\n  condition: evt.type = open\n")
+		Expect(oci.SetEngineRequirement(filePath, "0.31.0")).To(MatchError(oci.ErrReqNotFound))
+	})
+})
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isJSONContent reports whether the content of the given reader is in json format, that is whether its first
+// non-whitespace byte opens a json array or object. Nothing is consumed from the reader.
+func isJSONContent(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		// Whitespace longer than the buffer fails with bufio.ErrBufferFull, such content is not json.
+		peeked, err := r.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch peeked[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[', '{':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// decodeJSONDocument given the content of a rulesfile in json format it decodes it as a yaml document node, so that
+// json rulesfiles are handled as the yaml ones. Yaml is almost a superset of json, but the yaml decoder rejects some
+// valid json, such as the "\/" escape sequence. The position of each value in the content is kept.
+func decodeJSONDocument(data []byte) (*yaml.Node, error) {
+	d := &jsonNodeDecoder{
+		data:       data,
+		dec:        json.NewDecoder(bytes.NewReader(data)),
+		lineStarts: []int{0},
+	}
+	d.dec.UseNumber()
+	for i, b := range data {
+		if b == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	root, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if _, start, err := d.next(); !errors.Is(err, io.EOF) {
+		line, column := d.position(start)
+		return nil, fmt.Errorf("line %d, column %d: unexpected content after the json value", line, column)
+	}
+
+	return &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Line:    root.Line,
+		Column:  root.Column,
+		Content: []*yaml.Node{root},
+	}, nil
+}
+
+// jsonNodeDecoder decodes json values as yaml nodes, tracking the position of each token.
+type jsonNodeDecoder struct {
+	data []byte
+	dec  *json.Decoder
+	// lineStarts are the offsets at which each line of data starts.
+	lineStarts []int
+}
+
+// next returns the next json token together with the offset at which it starts.
+func (d *jsonNodeDecoder) next() (json.Token, int, error) {
+	// The decoder offset is at the end of the previous token, skip the separators before the next one.
+	start := int(d.dec.InputOffset())
+	for start < len(d.data) && strings.IndexByte(" \t\r\n,:", d.data[start]) >= 0 {
+		start++
+	}
+
+	tok, err := d.dec.Token()
+	return tok, start, err
+}
+
+// position returns the 1-based line and column of the given offset.
+func (d *jsonNodeDecoder) position(offset int) (line, column int) {
+	line = sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset })
+	return line, offset - d.lineStarts[line-1] + 1
+}
+
+// value decodes the next json value, recursively decoding the content of arrays and objects.
+func (d *jsonNodeDecoder) value() (*yaml.Node, error) {
+	tok, start, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{}
+	node.Line, node.Column = d.position(start)
+
+	switch t := tok.(type) {
+	case json.Delim:
+		node.Style = yaml.FlowStyle
+		switch t {
+		case '[':
+			node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		case '{':
+			node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		default:
+			return nil, fmt.Errorf("line %d, column %d: unexpected %q", node.Line, node.Column, t.String())
+		}
+		// Object keys are decoded as values too, the decoder guarantees they are strings.
+		for d.dec.More() {
+			child, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		// Consume the closing delimiter.
+		if _, _, err := d.next(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.Kind, node.Tag, node.Style, node.Value = yaml.ScalarNode, "!!str", yaml.DoubleQuotedStyle, t
+	case json.Number:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!int", t.String()
+		if strings.ContainsAny(node.Value, ".eE") {
+			node.Tag = "!!float"
+		}
+	case bool:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!bool", fmt.Sprint(t)
+	case nil:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!null", "null"
+	}
+
+	return node, nil
+}
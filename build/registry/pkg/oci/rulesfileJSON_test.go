@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+// jsonRulesfile is a rulesfile in json format, indented with tabs.
+const jsonRulesfile = "[\n\t{\n\t\t\"required_engine_version\": \"0.31.0\"\n\t},\n\t{\n\t\t\"required_plugin_versions\": [\n" +
+	"\t\t\t{\"name\": \"k8saudit\", \"version\": \"0.7.0\"}\n\t\t]\n\t},\n" +
+	"\t{\"rule\": \"open\", \"condition\": \"evt.type = open\", \"output\": \"file opened \\/tmp\"}\n]\n"
+
+var _ = Describe("Json rulesfiles", func() {
+	It("should extract the requirements of json rulesfiles", func() {
+		// Json rulesfiles are usually indented with tabs, which yaml does not allow outside of flow collections.
+		filePath := filepath.Join(GinkgoT().TempDir(), "rules.json")
+		Expect(os.WriteFile(filePath, []byte(jsonRulesfile), 0o600)).To(Succeed())
+
+		req, err := oci.RulesfileRequirement(filePath)
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+
+		groups, err := oci.RulesfilePluginRequirementGroups(filePath)
+		Expect(err).To(BeNil())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].Options[0].Name).To(Equal("k8saudit"))
+	})
+})
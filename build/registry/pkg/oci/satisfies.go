@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/blang/semver"
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+)
+
+// Satisfies given the versions provided by a consumer, such as a Falco instance, keyed by requirement name, e.g. its
+// engine version and plugin api version, it reports whether they satisfy all the given requirements of an artifact,
+// e.g. as a preflight check before installing it. If not, it also returns a description of each unmet requirement,
+// with the needed and the provided versions, in the same order as the requirements.
+//
+// Requirements expressed as ranges, e.g. ">=0.31.0 <0.40.0", are satisfied by the versions in the range. The plugin
+// api version is satisfied, as done by the plugin loader, by the versions with the same major that are not older than
+// the required one. The other requirements, such as the engine version, are satisfied by the versions not older than
+// the required one. Requirements not provided by the consumer, or whose versions can not be parsed, are unmet.
+func Satisfies(consumer map[string]string, reqs []oci.ArtifactRequirement) (bool, []string) {
+	var unmet []string
+	for _, req := range reqs {
+		provided, ok := consumer[req.Name]
+		if !ok {
+			unmet = append(unmet, fmt.Sprintf("%s: requires %q, not provided", req.Name, req.Version))
+			continue
+		}
+
+		if err := satisfiesRequirement(req, provided); err != nil {
+			unmet = append(unmet, fmt.Sprintf("%s: requires %q, provided %q: %v", req.Name, req.Version, provided, err))
+		}
+	}
+
+	return len(unmet) == 0, unmet
+}
+
+// satisfiesRequirement returns an error describing why the provided version does not satisfy the requirement, nil if
+// it does.
+func satisfiesRequirement(req oci.ArtifactRequirement, provided string) error {
+	providedVer, err := semver.ParseTolerant(provided)
+	if err != nil {
+		return fmt.Errorf("unable to parse provided version: %w", err)
+	}
+
+	if isVersionRange(req.Version) {
+		r, err := semver.ParseRange(req.Version)
+		if err != nil {
+			return fmt.Errorf("unable to parse required range: %w", err)
+		}
+		if !r(providedVer) {
+			return errors.New("not in range")
+		}
+		return nil
+	}
+
+	requiredVer, err := semver.ParseTolerant(req.Version)
+	if err != nil {
+		return fmt.Errorf("unable to parse required version: %w", err)
+	}
+
+	if req.Name == common.PluginAPIVersion && providedVer.Major != requiredVer.Major {
+		return errors.New("incompatible major version")
+	}
+	if providedVer.LT(requiredVer) {
+		return errors.New("older than required")
+	}
+
+	return nil
+}
+
+// ErrNotInstallable error when an artifact would be refused by falcoctl, since its requirements are not met by the
+// target Falco versions, see CheckInstallable.
+var ErrNotInstallable = errors.New("artifact not installable")
+
+// falcoctlIntVersion matches the requirement versions falcoctl compares as integers.
+var falcoctlIntVersion = regexp.MustCompile(`^(0|([1-9]\d*))$`)
+
+// CheckInstallable given the requirements of an artifact and the versions of the target Falco, keyed by requirement
+// name as the "falcoVersions" of falcoctl, e.g. common.EngineVersionKey and common.PluginAPIVersion, it reports
+// whether falcoctl would install the artifact, as a dry run of its install-time check. It returns an error wrapping
+// ErrNotInstallable for each unmet requirement, nil if the artifact is installable.
+//
+// The check reproduces the one of falcoctl v0.6, which is stricter than Satisfies: requirements whose name is not
+// among the target versions are unmet, versions made of digits only are compared as integers, and any other version
+// must be strict semver, ranges being refused, with the same major as the target and not newer than it. This applies
+// to the engine version too, hence an artifact requiring engine "0.31.0" is not installable on engine "1.0.0".
+func CheckInstallable(reqs []oci.ArtifactRequirement, falcoVersions map[string]string) error {
+	var errs []error
+	for _, req := range reqs {
+		if err := falcoctlCheckRequirement(req, falcoVersions); err != nil {
+			errs = append(errs, fmt.Errorf("requirement %q: %w: %w", req.Name, ErrNotInstallable, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// falcoctlCheckRequirement checks a single requirement as falcoctl does, returning an error with the same reason.
+func falcoctlCheckRequirement(req oci.ArtifactRequirement, falcoVersions map[string]string) error {
+	falcoVer, ok := falcoVersions[req.Name]
+	if !ok {
+		return fmt.Errorf("unrecognized key %s: Falco does not satisfy this requirement", req.Name)
+	}
+
+	if falcoctlIntVersion.MatchString(req.Version) {
+		falcoVerInt, err := strconv.Atoi(falcoVer)
+		if err != nil {
+			return fmt.Errorf("expected integer for key %s: %w", req.Name, err)
+		}
+		reqVerInt, err := strconv.Atoi(req.Version)
+		if err != nil {
+			return fmt.Errorf("expected integer for key %s: %w", req.Name, err)
+		}
+		if falcoVerInt < reqVerInt {
+			return fmt.Errorf("incompatible versions, Falco: %d, Requirement: %s:%d", falcoVerInt, req.Name, reqVerInt)
+		}
+		return nil
+	}
+
+	falcoSemver, err := semver.Parse(falcoVer)
+	if err != nil {
+		return fmt.Errorf("expected semver for key %s: %w", req.Name, err)
+	}
+	reqSemver, err := semver.Parse(req.Version)
+	if err != nil {
+		return fmt.Errorf("expected semver for key %s: %w", req.Name, err)
+	}
+	if falcoSemver.Major != reqSemver.Major {
+		return fmt.Errorf("incompatible versions, MAJOR mismatch, Falco: %s, Requirement: %s:%s", falcoSemver, req.Name, reqSemver)
+	}
+	if falcoSemver.Compare(reqSemver) < 0 {
+		return fmt.Errorf("incompatible versions, MINOR mismatch, Falco: %s, Requirement: %s:%s", falcoSemver, req.Name, reqSemver)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Satisfies", func() {
+	consumer := map[string]string{
+		common.EngineVersionKey: "0.31.0",
+		common.PluginAPIVersion: "3.1.0",
+	}
+
+	DescribeTable("should report the unmet requirements",
+		func(reqs []falcoctloci.ArtifactRequirement, unmet []string) {
+			ok, got := oci.Satisfies(consumer, reqs)
+			Expect(ok).To(Equal(len(unmet) == 0))
+			Expect(got).To(Equal(unmet))
+		},
+		Entry("satisfied", []falcoctloci.ArtifactRequirement{
+			{Name: common.EngineVersionKey, Version: "0.26.0"},
+			{Name: common.PluginAPIVersion, Version: "3.0.0"},
+		}, nil),
+		Entry("range", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: ">=0.30.0 <0.40.0"}}, nil),
+		Entry("none", nil, nil),
+		Entry("newer engine", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.35.0"}},
+			[]string{`engine_version_semver: requires "0.35.0", provided "0.31.0": older than required`}),
+		Entry("out of range", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: ">=0.20.0 <0.30.0"}},
+			[]string{`engine_version_semver: requires ">=0.20.0 <0.30.0", provided "0.31.0": not in range`}),
+		Entry("api major", []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "2.0.0"}},
+			[]string{`plugin_api_version: requires "2.0.0", provided "3.1.0": incompatible major version`}),
+		Entry("all unmet reported", []falcoctloci.ArtifactRequirement{
+			{Name: common.PluginAPIVersion, Version: "3.2.0"},
+			{Name: common.EngineVersionKey, Version: "0.26.0"},
+			{Name: "asset_bundle_version", Version: "1.0.0"},
+		}, []string{
+			`plugin_api_version: requires "3.2.0", provided "3.1.0": older than required`,
+			`asset_bundle_version: requires "1.0.0", not provided`,
+		}),
+	)
+
+	It("should not be satisfied by unparsable versions", func() {
+		ok, unmet := oci.Satisfies(map[string]string{common.EngineVersionKey: "latest"},
+			[]falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.26.0"}})
+		Expect(ok).To(BeFalse())
+		Expect(unmet).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Check installable", func() {
+	falco := map[string]string{common.EngineVersionKey: "0.37.0", common.PluginAPIVersion: "3.2.0"}
+
+	DescribeTable("should check the requirements as falcoctl does",
+		func(reqs []falcoctloci.ArtifactRequirement, installable bool) {
+			err := oci.CheckInstallable(reqs, falco)
+			if installable {
+				Expect(err).To(BeNil())
+				return
+			}
+			Expect(err).To(MatchError(oci.ErrNotInstallable))
+		},
+		Entry("met", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.31.0"}, {Name: common.PluginAPIVersion, Version: "3.0.0"}}, true),
+		Entry("newer engine", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.38.0"}}, false),
+		Entry("different api major", []falcoctloci.ArtifactRequirement{{Name: common.PluginAPIVersion, Version: "2.0.0"}}, false),
+		// Unlike Satisfies, falcoctl rejects ranges and needs strict semver.
+		Entry("range", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: ">=0.31.0"}}, false),
+		Entry("not strict semver", []falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "0.31"}}, false),
+		Entry("unknown key", []falcoctloci.ArtifactRequirement{{Name: "k8s_version", Version: "1.0.0"}}, false),
+	)
+
+	It("should compare the integer versions as integers", func() {
+		Expect(oci.CheckInstallable([]falcoctloci.ArtifactRequirement{{Name: common.EngineVersionKey, Version: "15"}},
+			map[string]string{common.EngineVersionKey: "17"})).To(Succeed())
+	})
+})
@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci/authn"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Signatures follow the conventions of cosign, so that they can be verified by "cosign verify --key" too.
+const (
+	// signatureMediaType is the media type of the layers containing the signed payload.
+	signatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// signatureAnnotation is the annotation of the layers containing the base64 encoded signature of the payload.
+	signatureAnnotation = "dev.cosignproject.cosign/signature"
+	// signatureType is the type of the signed payloads.
+	signatureType = "cosign container image signature"
+	// signatureTagSuffix is the suffix of the tag of the signature manifests, following the digest of the artifact.
+	signatureTagSuffix = ".sig"
+)
+
+// ErrInvalidSignature error when an artifact has no signature valid for the given key.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// signaturePayload is the payload signed for an artifact, in the "simple signing" format used by cosign.
+type signaturePayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]string `json:"optional"`
+}
+
+// SignArtifact signs the manifest of the artifact with the given reference with the private key in the keyRef file,
+// and pushes the signature to the same repository in the format used by cosign. Only unencrypted ECDSA keys in PEM
+// format are supported. The credentials are read from the environment variables used to push the artifacts.
+func SignArtifact(ctx context.Context, ref, keyRef string) error {
+	key, err := loadSigningKey(keyRef)
+	if err != nil {
+		return err
+	}
+
+	repo, err := remote.NewRepository(ref)
+	if err != nil {
+		return fmt.Errorf("unable to create repo for ref %q: %w", ref, err)
+	}
+	repo.Client = authn.NewClient(authn.WithCredentials(&auth.Credential{
+		Username: os.Getenv(RegistryUser),
+		Password: os.Getenv(RegistryToken),
+	}))
+
+	return signArtifact(ctx, repo, ref, key)
+}
+
+// VerifyArtifactSignature checks that the artifact with the given reference has been signed, see SignArtifact, with
+// the private key matching the public key in the keyRef file. An error wrapping ErrInvalidSignature is returned
+// if none of its signatures is valid.
+func VerifyArtifactSignature(ctx context.Context, ref, keyRef string) error {
+	pub, err := loadVerificationKey(keyRef)
+	if err != nil {
+		return err
+	}
+
+	repo, err := remote.NewRepository(ref)
+	if err != nil {
+		return fmt.Errorf("unable to create repo for ref %q: %w", ref, err)
+	}
+	repo.Client = authn.NewClient(authn.WithCredentials(&auth.EmptyCredential))
+
+	return verifyArtifactSignature(ctx, repo, ref, pub)
+}
+
+// signArtifact signs the artifact with the given reference in the target, and pushes the signature manifest tagged
+// after the digest of the artifact. As done by cosign, the signature is appended to the existing signature manifest,
+// if any, so that the signatures made by other signers, or with rotated keys, are kept.
+func signArtifact(ctx context.Context, target oras.Target, ref string, key *ecdsa.PrivateKey) error {
+	desc, identity, err := resolveSigned(ctx, target, ref)
+	if err != nil {
+		return err
+	}
+
+	var payload signaturePayload
+	payload.Critical.Identity.DockerReference = identity
+	payload.Critical.Image.DockerManifestDigest = desc.Digest.String()
+	payload.Critical.Type = signatureType
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to marshal signature payload of %q: %w", ref, err)
+	}
+
+	digest := sha256.Sum256(payloadBytes)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return fmt.Errorf("unable to sign %q: %w", ref, err)
+	}
+
+	layer := content.NewDescriptorFromBytes(signatureMediaType, payloadBytes)
+	layer.Annotations = map[string]string{signatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	if err := pushBytes(ctx, target, layer, payloadBytes); err != nil {
+		return err
+	}
+
+	layers, err := existingSignatures(ctx, target, desc)
+	if err != nil {
+		return fmt.Errorf("unable to fetch signature manifest of %q: %w", ref, err)
+	}
+	if !slices.ContainsFunc(layers, func(l ocispec.Descriptor) bool { return sameSignature(l, layer) }) {
+		layers = append(layers, layer)
+	}
+
+	configDesc, err := pushJSON(ctx, target, ocispec.MediaTypeImageConfig, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	manifestDesc, err := oras.Pack(ctx, target, "", layers, oras.PackOptions{
+		ConfigDescriptor:  &configDesc,
+		PackImageManifest: true,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to pack signature manifest of %q: %w", ref, err)
+	}
+
+	if err := target.Tag(ctx, manifestDesc, signatureTag(desc)); err != nil {
+		return fmt.Errorf("unable to tag signature manifest of %q: %w", ref, err)
+	}
+
+	return nil
+}
+
+// existingSignatures returns the layers of the signature manifest of the artifact with the given descriptor, nil if
+// it has not been signed yet.
+func existingSignatures(ctx context.Context, target oras.ReadOnlyTarget, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	sigDesc, err := target.Resolve(ctx, signatureTag(desc))
+	if errors.Is(err, errdef.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ocispec.Manifest
+	if err := fetchJSON(ctx, target, sigDesc, &manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest.Layers, nil
+}
+
+// sameSignature reports whether the given signature layers hold the same payload and the same signature.
+func sameSignature(a, b ocispec.Descriptor) bool {
+	return a.MediaType == b.MediaType && a.Digest == b.Digest && a.Annotations[signatureAnnotation] == b.Annotations[signatureAnnotation]
+}
+
+// verifyArtifactSignature checks that the artifact with the given reference in the target has at least one
+// signature valid for the given public key, signing its digest and identity.
+func verifyArtifactSignature(ctx context.Context, target oras.ReadOnlyTarget, ref string, pub *ecdsa.PublicKey) error {
+	desc, identity, err := resolveSigned(ctx, target, ref)
+	if err != nil {
+		return err
+	}
+
+	sigDesc, err := target.Resolve(ctx, signatureTag(desc))
+	if err != nil {
+		return fmt.Errorf("no signature found for %q: %w: %w", ref, ErrInvalidSignature, err)
+	}
+
+	var manifest ocispec.Manifest
+	if err := fetchJSON(ctx, target, sigDesc, &manifest); err != nil {
+		return fmt.Errorf("unable to fetch signature manifest of %q: %w", ref, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != signatureMediaType {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[signatureAnnotation])
+		if err != nil {
+			continue
+		}
+		payloadBytes, err := content.FetchAll(ctx, target, layer)
+		if err != nil {
+			return fmt.Errorf("unable to fetch signature payload of %q: %w", ref, err)
+		}
+
+		digest := sha256.Sum256(payloadBytes)
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			continue
+		}
+
+		// The signature is valid, check that it has been produced for this artifact.
+		var payload signaturePayload
+		if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+			continue
+		}
+		if payload.Critical.Image.DockerManifestDigest == desc.Digest.String() && payload.Critical.Identity.DockerReference == identity {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no signature of %q is valid for the given key: %w", ref, ErrInvalidSignature)
+}
+
+// resolveSigned resolves the artifact with the given reference, returning its descriptor and the identity its
+// signatures are bound to, that is the repository without tag or digest.
+func resolveSigned(ctx context.Context, target oras.ReadOnlyTarget, ref string) (ocispec.Descriptor, string, error) {
+	parsed, err := registry.ParseReference(ref)
+	if err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("unable to parse reference %q: %w", ref, err)
+	}
+
+	desc, err := target.Resolve(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("unable to resolve reference %q: %w", ref, err)
+	}
+
+	return desc, parsed.Registry + "/" + parsed.Repository, nil
+}
+
+// signatureTag returns the tag of the signature manifest of the artifact with the given descriptor, as in
+// "sha256-<hex>.sig".
+func signatureTag(desc ocispec.Descriptor) string {
+	return strings.Replace(desc.Digest.String(), ":", "-", 1) + signatureTagSuffix
+}
+
+// loadSigningKey reads an unencrypted ECDSA private key, in PEM format, from the given file.
+func loadSigningKey(keyRef string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(keyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key %q: %w", keyRef, err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key %q: %w", keyRef, err)
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key %q is not an ECDSA key", keyRef)
+		}
+		return ecKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key %q of type %q, expected an unencrypted ECDSA key", keyRef, block.Type)
+	}
+}
+
+// loadVerificationKey reads an ECDSA public key, in PEM format, from the given file.
+func loadVerificationKey(keyRef string) (*ecdsa.PublicKey, error) {
+	block, err := readPEM(keyRef)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unsupported public key %q of type %q", keyRef, block.Type)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key %q: %w", keyRef, err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %q is not an ECDSA key", keyRef)
+	}
+
+	return ecKey, nil
+}
+
+// readPEM reads the first PEM block of the given file.
+func readPEM(filePath string) (*pem.Block, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key %q: %w", filePath, err)
+	}
+
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in key %q", filePath)
+	}
+
+	return block, nil
+}
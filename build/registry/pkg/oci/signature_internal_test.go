@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"oras.land/oras-go/v2/content/memory"
+)
+
+const signedRef = "ghcr.io/falcosecurity/plugins/ruleset/k8saudit-rules:0.1.0"
+
+// packSignedArtifact packs a rulesfile artifact in the given store, tagged with the given reference, to be signed.
+func packSignedArtifact(t *testing.T, ctx context.Context, store *memory.Store, ref string) {
+	t.Helper()
+
+	archive := filepath.Join(t.TempDir(), "rules.tar.gz")
+	if err := os.WriteFile(archive, []byte("rules"), 0o600); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+	desc, err := PackArtifact(ctx, PackOptions{Target: store, Name: "k8saudit-rules", Version: "0.1.0", Rulesfiles: []string{archive}})
+	if err != nil {
+		t.Fatalf("unable to pack artifact: %v", err)
+	}
+	if err := store.Tag(ctx, desc, ref); err != nil {
+		t.Fatalf("unable to tag artifact: %v", err)
+	}
+}
+
+// writeSigningKeys generates an ECDSA key pair and returns it as read from PEM files, as generated by openssl.
+func writeSigningKeys(t *testing.T) (*ecdsa.PrivateKey, *ecdsa.PublicKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("unable to write key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("unable to marshal public key: %v", err)
+	}
+	pubFile := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600); err != nil {
+		t.Fatalf("unable to write public key: %v", err)
+	}
+
+	signingKey, err := loadSigningKey(keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pub, err := loadVerificationKey(pubFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return signingKey, pub
+}
+
+func TestSignArtifact(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := memory.New()
+	packSignedArtifact(t, ctx, store, signedRef)
+
+	signingKey, pub := writeSigningKeys(t)
+
+	if err := verifyArtifactSignature(ctx, store, signedRef, pub); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected error %v for an unsigned artifact, got %v", ErrInvalidSignature, err)
+	}
+
+	if err := signArtifact(ctx, store, signedRef, signingKey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := verifyArtifactSignature(ctx, store, signedRef, pub); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Signatures made with other keys are not valid.
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	if err := verifyArtifactSignature(ctx, store, signedRef, &other.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected error %v, got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestSignArtifactKeepsSignatures(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := memory.New()
+	packSignedArtifact(t, ctx, store, signedRef)
+
+	// A second signer, or a rotated key, does not remove the earlier signatures.
+	firstKey, firstPub := writeSigningKeys(t)
+	secondKey, secondPub := writeSigningKeys(t)
+	for _, key := range []*ecdsa.PrivateKey{firstKey, secondKey} {
+		if err := signArtifact(ctx, store, signedRef, key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for _, pub := range []*ecdsa.PublicKey{firstPub, secondPub} {
+		if err := verifyArtifactSignature(ctx, store, signedRef, pub); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	desc, err := store.Resolve(ctx, signedRef)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	layers, err := existingSignatures(ctx, store, desc)
+	if err != nil || len(layers) != 2 {
+		t.Fatalf("expected 2 signatures, got %d and %v", len(layers), err)
+	}
+	if !sameSignature(layers[0], layers[0]) || sameSignature(layers[0], layers[1]) {
+		t.Fatalf("expected the signatures to differ")
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+// PluginSidecarSuffix is the suffix of the sidecar of a plugin shipped as a static archive, e.g. "libfoo.a.json" for
+// "libfoo.a", a json file reporting the info the plugin would report if loaded:
+//
+//	{"name": "foo", "version": "0.1.0", "required_api_version": "3.0.0"}
+const PluginSidecarSuffix = ".json"
+
+// ErrNotSharedObject error when a plugin is not a loadable shared object, e.g. since it has been built as a static
+// archive. It always comes together with ErrOpenFailed.
+var ErrNotSharedObject = errors.New("not a loadable shared object")
+
+// arMagic are the leading bytes of static archives, as produced by ar.
+var arMagic = []byte("!<arch>\n")
+
+// WithPluginSidecar reads the api version required by the plugins shipped as static archives, which can not be
+// loaded, from their sidecar, see PluginSidecarSuffix. Without it, or if the sidecar does not exist, static archives
+// fail the extraction with an error wrapping ErrNotSharedObject. It has no effect on shared libraries and rulesfiles.
+func WithPluginSidecar() RequirementOption {
+	return func(o *requirementOptions) {
+		o.pluginSidecar = true
+	}
+}
+
+// pluginSidecar is the content of the sidecar of a plugin, see PluginSidecarSuffix.
+type pluginSidecar struct {
+	Name               string `json:"name"`
+	Version            string `json:"version"`
+	RequiredAPIVersion string `json:"required_api_version"`
+}
+
+// isStaticArchive reports whether the given file is a static archive, as detected by its leading magic bytes.
+func isStaticArchive(filePath string) (bool, error) {
+	return hasMagic(filePath, arMagic)
+}
+
+// staticArchiveRequirement given a plugin shipped as a static archive it returns the api version reported by its
+// sidecar, if enabled by WithPluginSidecar and existing. Otherwise, an error wrapping both ErrOpenFailed and
+// ErrNotSharedObject is returned, since the plugin loader only loads shared libraries.
+func staticArchiveRequirement(filePath string, opts ...RequirementOption) (*oci.ArtifactRequirement, error) {
+	notSharedErr := newRequirementError(filePath, StageOpen, fmt.Errorf("plugin %q is a static archive, build it as a shared library, e.g. with -buildmode=c-shared for plugins written in Go, or provide the %q sidecar: %w: %w",
+		filePath, filePath+PluginSidecarSuffix, ErrOpenFailed, ErrNotSharedObject))
+	if !newRequirementOptions(opts).pluginSidecar {
+		return nil, notSharedErr
+	}
+
+	sidecarPath := filePath + PluginSidecarSuffix
+	data, err := os.ReadFile(sidecarPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notSharedErr
+	}
+	if err != nil {
+		return nil, newRequirementError(filePath, StageOpen, fmt.Errorf("unable to read sidecar %q of plugin %q: %w: %w", sidecarPath, filePath, ErrOpenFailed, err))
+	}
+
+	var sidecar pluginSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, newRequirementError(filePath, StageDecode, fmt.Errorf("unable to decode sidecar %q of plugin %q: %w: %w", sidecarPath, filePath, ErrParseFailed, err))
+	}
+
+	return pluginInfoRequirement(filePath, &PluginInfo{
+		Name:               sidecar.Name,
+		Version:            sidecar.Version,
+		RequiredAPIVersion: sidecar.RequiredAPIVersion,
+	}, opts...)
+}
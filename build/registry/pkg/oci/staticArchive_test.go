@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Static archive plugins", func() {
+	var filePath string
+
+	BeforeEach(func() {
+		filePath = filepath.Join(GinkgoT().TempDir(), "libfoo.a")
+		Expect(os.WriteFile(filePath, []byte("!<arch>\nfoo.o/          0           0     0     644     4         `\n"), 0o600)).To(Succeed())
+	})
+
+	It("should fail without the sidecar", func() {
+		for _, opts := range [][]oci.RequirementOption{nil, {oci.WithPluginSidecar()}} {
+			_, err := oci.PluginRequirement(filePath, opts...)
+			Expect(err).To(MatchError(oci.ErrNotSharedObject))
+			Expect(err).To(MatchError(oci.ErrOpenFailed))
+		}
+	})
+
+	It("should read the requirement of the sidecar if requested", func() {
+		sidecar := `{"name": "foo", "version": "0.1.0", "required_api_version": "3.0.0"}`
+		Expect(os.WriteFile(filePath+oci.PluginSidecarSuffix, []byte(sidecar), 0o600)).To(Succeed())
+
+		_, err := oci.PluginRequirement(filePath)
+		Expect(err).To(MatchError(oci.ErrNotSharedObject))
+
+		req, err := oci.PluginRequirement(filePath, oci.WithPluginSidecar())
+		Expect(err).To(BeNil())
+		Expect(req.Name).To(Equal(common.PluginAPIVersion))
+		Expect(req.Version).To(Equal("3.0.0"))
+	})
+})
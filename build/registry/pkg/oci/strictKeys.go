@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKey error when an item of a rulesfile has a key that is not known, in strict mode only, see
+// WithStrictKeys. It always comes together with ErrParseFailed.
+var ErrUnknownKey = errors.New("unknown rulesfile key")
+
+// KnownRulesfileKeys are the keys the items of a rulesfile can have, as accepted by Falco: the ones declaring the
+// requirements, and the ones of the rules, macros and lists. The engine requirement keys, see RulesEngineKey and
+// RulesEngineMinKey, or the ones set with WithRulesEngineKey, are always known too.
+var KnownRulesfileKeys = []string{
+	rulesPluginsKey, rulesPluginKey,
+	"rule", "macro", "list",
+	"desc", "condition", "output", "priority", "source", "enabled", "tags", "exceptions",
+	"warn_evttypes", "skip-if-unknown-filter", "capture", "capture_duration",
+	"items", "append", "override",
+}
+
+// WithStrictKeys rejects the rulesfiles having items with keys that are not known, e.g. misspelled ones such as
+// "requierd_engine_version" that would otherwise only result in the requirement not being found. The keys allowed
+// are KnownRulesfileKeys, the engine requirement keys and the given extra ones. By default unknown keys are ignored, as Falco
+// itself accepts keys introduced by later versions.
+func WithStrictKeys(extraKeys ...string) RequirementOption {
+	return func(o *requirementOptions) {
+		o.strictKeys = true
+		o.extraKeys = append(o.extraKeys, extraKeys...)
+	}
+}
+
+// checkRulesfileKeys returns an error wrapping ErrParseFailed and ErrUnknownKey, reporting the line of the key, if
+// an item of the given rulesfile has a key neither known, including the given engine requirement key, nor in
+// extraKeys. The name of the rulesfile is only used for error reporting.
+func checkRulesfileKeys(name string, items []rulesfileItem, engineKey string, extraKeys []string) error {
+	allowed := map[string]bool{engineKey: true, engineMinKey(engineKey): true, engineMaxKey(engineKey): true}
+	for _, keys := range [][]string{KnownRulesfileKeys, extraKeys} {
+		for _, k := range keys {
+			allowed[k] = true
+		}
+	}
+
+	for _, item := range items {
+		for _, key := range item.Keys {
+			if !allowed[key.Value] {
+				return newRequirementError(name, StageDecode, fmt.Errorf("rulesfile %q, line %d: key %q: %w: %w",
+					name, key.Line, key.Value, ErrParseFailed, ErrUnknownKey))
+			}
+		}
+	}
+
+	return nil
+}
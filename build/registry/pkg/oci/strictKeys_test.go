@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Strict keys", func() {
+	It("should report the unknown keys with their line", func() {
+		filePath := writeRulesfile("- required_engine_version: 0.31.0\n- rule: shell\n  desc: shell in container\n  condition: spawned_process\n  output: shell\n  priority: WARNING\n  x_owner: security\n")
+
+		// Unknown keys are ignored by default.
+		_, err := oci.RulesfileRequirement(filePath)
+		Expect(err).To(BeNil())
+
+		_, err = oci.RulesfileRequirement(filePath, oci.WithStrictKeys())
+		Expect(err).To(MatchError(oci.ErrUnknownKey))
+		Expect(err).To(MatchError(oci.ErrParseFailed))
+		Expect(err.Error()).To(ContainSubstring(`line 7: key "x_owner"`))
+
+		_, err = oci.RulesfileRequirement(filePath, oci.WithStrictKeys("x_owner"))
+		Expect(err).To(BeNil())
+	})
+
+	It("should report the misspelled requirements as unknown keys", func() {
+		_, err := oci.RulesfileRequirement(writeRulesfile("- requierd_engine_version: 0.31.0\n"), oci.WithStrictKeys())
+		Expect(err).To(MatchError(oci.ErrUnknownKey))
+		Expect(err).ToNot(MatchError(oci.ErrReqNotFound))
+	})
+})
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/common"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+// UnknownVersion is the version reported in the summary for the artifacts whose requirement can not be found.
+const UnknownVersion = "unknown"
+
+// SummaryEntry is the requirement of a plugin or rulesfile of the registry.
+type SummaryEntry struct {
+	Name        string `json:"name"`
+	Requirement string `json:"requirement"`
+	Version     string `json:"version"`
+}
+
+// Summary lists the plugin api version required by each plugin of the registry, and the engine version required
+// by each rulesfile. Both lists are sorted by name.
+type Summary struct {
+	Plugins    []SummaryEntry `json:"plugins"`
+	Rulesfiles []SummaryEntry `json:"rulesfiles"`
+}
+
+// RegistrySummary given the registry and the directory containing the source tree of the plugins, as the "plugins"
+// directory of this repository, it extracts the requirements of all the plugins and rulesfiles of the registry. The
+// shared library of each plugin is expected at "<pluginsDir>/<name>/lib<name>.so", and its rulesfiles in
+// "<pluginsDir>/<name>/rules". Reserved entries are skipped. Requirements that can not be found, e.g. because a
+// plugin has not been built, are reported as UnknownVersion rather than being omitted. The requirements are extracted
+// with the given options.
+func RegistrySummary(reg *registry.Registry, pluginsDir string, opts ...RequirementOption) (*Summary, error) {
+	summary := &Summary{
+		Plugins:    []SummaryEntry{},
+		Rulesfiles: []SummaryEntry{},
+	}
+
+	for _, p := range reg.Plugins {
+		if p.Reserved {
+			continue
+		}
+
+		entry := SummaryEntry{Name: p.Name, Requirement: common.PluginAPIVersion, Version: UnknownVersion}
+		req, err := pluginRequirement(filepath.Join(pluginsDir, p.Name, "lib"+p.Name+".so"), opts...)
+		switch {
+		case err == nil && req.Version != "":
+			entry.Version = req.Version
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+		summary.Plugins = append(summary.Plugins, entry)
+
+		if p.RulesURL == "" {
+			continue
+		}
+
+		entry = SummaryEntry{Name: rulesfileNameFromPlugin(p.Name), Requirement: common.EngineVersionKey, Version: UnknownVersion}
+		reqs, err := rulesRequirements(filepath.Join(pluginsDir, p.Name, "rules"), opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reqs {
+			if r.Name == common.EngineVersionKey {
+				entry.Version = r.Version
+			}
+		}
+		summary.Rulesfiles = append(summary.Rulesfiles, entry)
+	}
+
+	sort.SliceStable(summary.Plugins, func(i, j int) bool {
+		return summary.Plugins[i].Name < summary.Plugins[j].Name
+	})
+	sort.SliceStable(summary.Rulesfiles, func(i, j int) bool {
+		return summary.Rulesfiles[i].Name < summary.Rulesfiles[j].Name
+	})
+
+	return summary, nil
+}
+
+// rulesRequirements returns the requirements of the rulesfiles in the given directory. No requirement is returned,
+// without errors, if the directory does not exist or the rulesfiles do not declare any.
+func rulesRequirements(dir string, opts []RequirementOption) ([]oci.ArtifactRequirement, error) {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	reqs, err := ArtifactRequirements(dir, opts...)
+	if errors.Is(err, ErrReqNotFound) {
+		return nil, nil
+	}
+
+	return reqs, err
+}
+
+// PrintSummaryMarkdown writes the summary as two markdown tables, one for the plugins and one for the rulesfiles.
+func PrintSummaryMarkdown(summary *Summary, output io.Writer) error {
+	sections := []struct {
+		title   string
+		column  string
+		entries []SummaryEntry
+	}{
+		{title: "Plugins", column: "Required API Version", entries: summary.Plugins},
+		{title: "Rulesfiles", column: "Required Engine Version", entries: summary.Rulesfiles},
+	}
+
+	for i, s := range sections {
+		if i > 0 {
+			if _, err := fmt.Fprintln(output); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(output, "## %s\n\n| Name | %s |\n| --- | --- |\n", s.title, s.column); err != nil {
+			return err
+		}
+		for _, e := range s.entries {
+			if _, err := fmt.Fprintf(output, "| %s | %s |\n", e.Name, e.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PrintSummaryJSON writes the summary as a json object.
+func PrintSummaryJSON(summary *Summary, output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+	"github.com/falcosecurity/plugins/build/registry/pkg/registry"
+)
+
+var _ = Describe("Registry summary", func() {
+	It("should summarize the requirements of the plugins and of the rulesfiles", func() {
+		pluginsDir := GinkgoT().TempDir()
+		writeFiles(pluginsDir, map[string]string{"k8saudit/rules/k8s_audit_rules.yaml": "- required_engine_version: 15\n"})
+
+		reg := &registry.Registry{Plugins: []registry.Plugin{
+			{Name: "k8saudit", RulesURL: "https://example.com/rules"},
+			{Name: "cloudtrail", RulesURL: "https://example.com/rules"},
+			{Name: "json"},
+			{Name: "reserved", Reserved: true},
+		}}
+
+		summary, err := oci.RegistrySummary(reg, pluginsDir)
+		Expect(err).To(BeNil())
+
+		// Plugins are not built, hence their requirements are unknown.
+		var names []string
+		for _, e := range summary.Plugins {
+			Expect(e.Version).To(Equal(oci.UnknownVersion), "plugin %q", e.Name)
+			names = append(names, e.Name)
+		}
+		Expect(names).To(Equal([]string{"cloudtrail", "json", "k8saudit"}))
+		Expect(summary.Rulesfiles).To(Equal([]oci.SummaryEntry{
+			{Name: "cloudtrail-rules", Requirement: "engine_version_semver", Version: oci.UnknownVersion},
+			{Name: "k8saudit-rules", Requirement: "engine_version_semver", Version: "0.15.0"},
+		}))
+
+		var buf bytes.Buffer
+		Expect(oci.PrintSummaryMarkdown(summary, &buf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("| k8saudit-rules | 0.15.0 |\n"))
+		Expect(buf.String()).To(ContainSubstring("| json | unknown |\n"))
+	})
+})
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrBrokenSymlink error when a plugin or a rulesfile is a symbolic link whose target does not exist. It always comes
+// together with ErrOpenFailed.
+var ErrBrokenSymlink = errors.New("broken symbolic link")
+
+// realPath returns the absolute path of the given file with all the symbolic links resolved, so that the same file
+// reached through different links, e.g. a rulesfile shared by several plugin directories, is identified as such.
+// An error wrapping ErrBrokenSymlink is returned if the file is a link whose target does not exist.
+func realPath(filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to get absolute path of %q: %w", filePath, err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		if brokenErr := brokenSymlinkError(filePath, err); brokenErr != nil {
+			return "", brokenErr
+		}
+		return "", fmt.Errorf("unable to resolve path %q: %w", filePath, err)
+	}
+
+	return resolved, nil
+}
+
+// brokenSymlinkError given the error occurred while accessing a file, it returns an error wrapping ErrBrokenSymlink if
+// it happened since the file is a symbolic link whose target does not exist, nil otherwise.
+func brokenSymlinkError(filePath string, err error) error {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	info, lstatErr := os.Lstat(filePath)
+	if lstatErr != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	target, _ := os.Readlink(filePath)
+	return fmt.Errorf("%q is a symbolic link to %q, which does not exist: %w", filePath, target, ErrBrokenSymlink)
+}
+
+// openFileError returns the error to be reported when a plugin or a rulesfile can not be opened, wrapping
+// ErrOpenFailed together with ErrBrokenSymlink for broken links, or with the given error otherwise.
+func openFileError(filePath string, err error) error {
+	if brokenErr := brokenSymlinkError(filePath, err); brokenErr != nil {
+		err = brokenErr
+	}
+
+	return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to open file %q: %w: %w", filePath, ErrOpenFailed, err))
+}
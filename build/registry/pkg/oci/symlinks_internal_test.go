@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRealPath(t *testing.T) {
+	t.Parallel()
+
+	// Links to the same file resolve to the same real path, as used by the plugin cache.
+	root := t.TempDir()
+	shared := filepath.Join(root, "common", "rules.yaml")
+	if err := os.MkdirAll(filepath.Dir(shared), 0o700); err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+	if err := os.WriteFile(shared, []byte("- required_engine_version: 0.31.0\n"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	for _, dir := range []string{"k8saudit", "cloudtrail"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.Symlink(filepath.Join("..", "common", "rules.yaml"), filepath.Join(root, dir, "rules.yaml")); err != nil {
+			t.Fatalf("unable to create symlink: %v", err)
+		}
+	}
+
+	linkPath, err := realPath(filepath.Join(root, "k8saudit", "rules.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targetPath, err := realPath(filepath.Join(root, "cloudtrail", "rules.yaml"))
+	if err != nil || linkPath != targetPath {
+		t.Fatalf("expected the same real path %q, got %q and %v", linkPath, targetPath, err)
+	}
+
+	broken := filepath.Join(root, "k8saudit", "broken.yaml")
+	if err := os.Symlink("missing", broken); err != nil {
+		t.Fatalf("unable to create symlink: %v", err)
+	}
+	if _, err := realPath(broken); !errors.Is(err, ErrBrokenSymlink) {
+		t.Fatalf("expected ErrBrokenSymlink, got %v", err)
+	}
+}
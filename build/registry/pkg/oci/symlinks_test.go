@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Symlinked requirements", func() {
+	var root, shared, link string
+
+	BeforeEach(func() {
+		// Rulesfiles shared across plugin directories through relative links.
+		root = GinkgoT().TempDir()
+		shared = filepath.Join(root, "common", "rules.yaml")
+		writeFiles(root, map[string]string{"common/rules.yaml": "- required_engine_version: 0.31.0\n"})
+		for _, dir := range []string{"k8saudit", "cloudtrail"} {
+			Expect(os.MkdirAll(filepath.Join(root, dir), 0o700)).To(Succeed())
+			Expect(os.Symlink(filepath.Join("..", "common", "rules.yaml"), filepath.Join(root, dir, "rules.yaml"))).To(Succeed())
+		}
+		link = filepath.Join(root, "k8saudit", "rules.yaml")
+	})
+
+	It("should extract the requirements of the target", func() {
+		req, digest, err := oci.RequirementWithDigest(link)
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+		_, targetDigest, err := oci.RequirementWithDigest(shared)
+		Expect(err).To(BeNil())
+		Expect(targetDigest).To(Equal(digest))
+
+		req, err = oci.RulesfileRequirement(link)
+		Expect(err).To(BeNil())
+		Expect(req.Version).To(Equal("0.31.0"))
+
+		reqs, err := oci.WalkRequirements(root)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(3))
+		Expect(reqs["cloudtrail/rules.yaml"].Version).To(Equal("0.31.0"))
+	})
+
+	It("should report the broken links as such", func() {
+		for _, name := range []string{"broken.yaml", "broken.so"} {
+			broken := filepath.Join(root, "k8saudit", name)
+			Expect(os.Symlink("missing", broken)).To(Succeed())
+
+			_, err := oci.ExtractRequirements(broken)
+			Expect(err).To(MatchError(oci.ErrBrokenSymlink), "file %q", name)
+			Expect(err).To(MatchError(oci.ErrOpenFailed), "file %q", name)
+			Expect(err.Error()).To(ContainSubstring(`"missing"`))
+		}
+
+		_, err := oci.RulesfileRequirement(filepath.Join(root, "k8saudit", "broken.yaml"))
+		Expect(err).To(MatchError(oci.ErrBrokenSymlink))
+		_, err = oci.WalkRequirements(root)
+		Expect(err).To(MatchError(oci.ErrBrokenSymlink))
+	})
+})
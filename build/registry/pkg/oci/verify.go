@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+	"github.com/falcosecurity/falcoctl/pkg/oci/authn"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// DoVerify pulls the config of the artifact with the given reference from the remote registry and compares the
+// requirements it contains with the ones extracted from the plugin and rulesfiles in sourceDir, see VerifyArtifact.
+func DoVerify(ctx context.Context, ref, sourceDir string, opts ...RequirementOption) ([]RequirementChange, error) {
+	repo, err := remote.NewRepository(ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create repo for ref %q: %w", ref, err)
+	}
+	repo.Client = authn.NewClient(authn.WithCredentials(&auth.EmptyCredential))
+
+	return VerifyArtifact(ctx, repo, ref, sourceDir, opts...)
+}
+
+// VerifyArtifact reads the requirements from the config of the artifact with the given reference and compares them
+// with the ones extracted from the plugin and rulesfiles in sourceDir, see ArtifactRequirements. The returned changes
+// are the drift from the published requirements to the ones declared by the sources, empty if they match. The local
+// requirements are extracted with the given options.
+func VerifyArtifact(ctx context.Context, target oras.ReadOnlyTarget, ref, sourceDir string, opts ...RequirementOption) ([]RequirementChange, error) {
+	published, err := artifactConfigRequirements(ctx, target, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := ArtifactRequirements(sourceDir, opts...)
+	if err != nil && !errors.Is(err, ErrReqNotFound) {
+		return nil, err
+	}
+
+	return DiffRequirements(published, local), nil
+}
+
+// artifactConfigRequirements returns the requirements contained in the config of the artifact with the given
+// reference. For artifacts with an index, the config of the first manifest is used since it is the same for all
+// the platforms.
+func artifactConfigRequirements(ctx context.Context, target oras.ReadOnlyTarget, ref string) ([]oci.ArtifactRequirement, error) {
+	desc, err := target.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve reference %q: %w", ref, err)
+	}
+
+	if desc.MediaType == ocispec.MediaTypeImageIndex {
+		var index ocispec.Index
+		if err := fetchJSON(ctx, target, desc, &index); err != nil {
+			return nil, fmt.Errorf("unable to fetch index of %q: %w", ref, err)
+		}
+		if len(index.Manifests) == 0 {
+			return nil, fmt.Errorf("index of %q has no manifests", ref)
+		}
+		desc = index.Manifests[0]
+	}
+
+	var manifest ocispec.Manifest
+	if err := fetchJSON(ctx, target, desc, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to fetch manifest of %q: %w", ref, err)
+	}
+
+	data, err := content.FetchAll(ctx, target, manifest.Config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config of %q: %w", ref, err)
+	}
+
+	// Requirements unknown to this tool are still compared.
+	reqs, err := ValidateArtifactConfig(data, WithAllowUnknownRequirements())
+	if err != nil {
+		return nil, fmt.Errorf("config of %q: %w", ref, err)
+	}
+
+	return reqs, nil
+}
+
+// fetchJSON fetches the content of the given descriptor and unmarshals it in v.
+func fetchJSON(ctx context.Context, target content.Fetcher, desc ocispec.Descriptor, v interface{}) error {
+	data, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// PrintRequirementChanges writes the changes of the requirements as a table with a row for each change.
+func PrintRequirementChanges(changes []RequirementChange, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUIREMENT\tCHANGE\tPUBLISHED VERSION\tSOURCE VERSION")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.Kind, c.OldVersion, c.NewVersion)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	falcoctloci "github.com/falcosecurity/falcoctl/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Verify artifact", func() {
+	const ref = "ghcr.io/falcosecurity/plugins/ruleset/k8saudit-rules:0.1.0"
+
+	var (
+		ctx       context.Context
+		store     *memory.Store
+		sourceDir string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = memory.New()
+		sourceDir = GinkgoT().TempDir()
+
+		archive := filepath.Join(GinkgoT().TempDir(), "rules.tar.gz")
+		Expect(os.WriteFile(archive, []byte("rules"), 0o600)).To(Succeed())
+		desc, err := oci.PackArtifact(ctx, oci.PackOptions{
+			Target:     store,
+			Name:       "k8saudit-rules",
+			Version:    "0.1.0",
+			Rulesfiles: []string{archive},
+			Requirements: []falcoctloci.ArtifactRequirement{
+				{Name: "engine_version_semver", Version: "0.31.0"},
+			},
+		})
+		Expect(err).To(BeNil())
+		Expect(store.Tag(ctx, desc, ref)).To(Succeed())
+	})
+
+	It("should report no drift if the sources declare the same requirements", func() {
+		Expect(os.WriteFile(filepath.Join(sourceDir, "k8saudit_rules.yaml"), []byte("- required_engine_version: 0.31.0\n"), 0o600)).To(Succeed())
+		changes, err := oci.VerifyArtifact(ctx, store, ref, sourceDir)
+		Expect(err).To(BeNil())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("should report the drift if the sources have changed", func() {
+		Expect(os.WriteFile(filepath.Join(sourceDir, "k8saudit_rules.yaml"), []byte("- required_engine_version: 0.32.0\n"), 0o600)).To(Succeed())
+		changes, err := oci.VerifyArtifact(ctx, store, ref, sourceDir)
+		Expect(err).To(BeNil())
+		Expect(changes).To(Equal([]oci.RequirementChange{
+			{Name: "engine_version_semver", Kind: oci.RequirementChanged, OldVersion: "0.31.0", NewVersion: "0.32.0"},
+		}))
+	})
+
+	It("should fail for unknown references", func() {
+		_, err := oci.VerifyArtifact(ctx, store, "ghcr.io/falcosecurity/plugins/ruleset/k8saudit-rules:0.2.0", sourceDir)
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/falcosecurity/falcoctl/pkg/oci"
+)
+
+// WalkOption is an option to customize the directory tree walk of WalkRequirements.
+type WalkOption func(*walkOptions)
+
+type walkOptions struct {
+	failOnReqNotFound bool
+	// listOnly makes the walk only list the plugins and rulesfiles found, without extracting their requirements.
+	listOnly bool
+	// reqOpts are the options the requirements of each file are extracted with.
+	reqOpts []RequirementOption
+}
+
+// WithFailOnReqNotFound makes WalkRequirements fail on the files having no requirement, instead of skipping them.
+func WithFailOnReqNotFound() WalkOption {
+	return func(o *walkOptions) {
+		o.failOnReqNotFound = true
+	}
+}
+
+// WithWalkRequirementOptions makes WalkRequirements extract the requirements of each file with the given options.
+func WithWalkRequirementOptions(opts ...RequirementOption) WalkOption {
+	return func(o *walkOptions) {
+		o.reqOpts = append(o.reqOpts, opts...)
+	}
+}
+
+// WalkRequirements given the root of a directory tree, it extracts the requirements of all the files found in it
+// handled by a registered extractor, such as plugins as shared libraries and rulesfiles, see
+// RegisterRequirementExtractor, and returns them keyed by their path relative to the root. The keys are always
+// slash-separated, so that they are the same on every platform, Windows included. The files no extractor handles are
+// ignored, and so are by default the files without requirements.
+// Symbolic links are followed, each directory being walked only once to guard against loops.
+func WalkRequirements(root string, opts ...WalkOption) (map[string]oci.ArtifactRequirement, error) {
+	o := &walkOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	w := &requirementsWalker{
+		opts:    o,
+		visited: make(map[string]bool),
+		reqs:    make(map[string]oci.ArtifactRequirement),
+	}
+	if err := w.walk(root, ""); err != nil {
+		return nil, err
+	}
+
+	return w.reqs, nil
+}
+
+type requirementsWalker struct {
+	opts *walkOptions
+	// visited holds the real paths of the directories already walked.
+	visited map[string]bool
+	reqs    map[string]oci.ArtifactRequirement
+	// files are the slash-separated paths, relative to the root, of the files handled by an extractor, in walk order.
+	files []string
+}
+
+// walk walks the given directory, whose slash-separated path relative to the root of the tree is relPath.
+func (w *requirementsWalker) walk(dir, relPath string) error {
+	realPath, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("unable to resolve directory %q: %w", dir, err)
+	}
+	if w.visited[realPath] {
+		return nil
+	}
+	w.visited[realPath] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read directory %q: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		filePath := filepath.Join(dir, entry.Name())
+		entryRelPath := path.Join(relPath, entry.Name())
+
+		// Stat follows symbolic links, so that linked files and directories are handled as their targets.
+		info, err := os.Stat(filePath)
+		if err != nil {
+			if brokenErr := brokenSymlinkError(filePath, err); brokenErr != nil {
+				return newRequirementError(filePath, StageOpen, fmt.Errorf("unable to stat %q: %w: %w", filePath, ErrOpenFailed, brokenErr))
+			}
+			return fmt.Errorf("unable to stat %q: %w", filePath, err)
+		}
+
+		if info.IsDir() {
+			if err := w.walk(filePath, entryRelPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Skip the files no extractor can handle, such as README files.
+		if extractorFor(filePath) == nil {
+			continue
+		}
+		w.files = append(w.files, entryRelPath)
+		if w.opts.listOnly {
+			continue
+		}
+
+		req, err := fileRequirement(filePath, w.opts.reqOpts...)
+
+		if errors.Is(err, ErrReqNotFound) && !w.opts.failOnReqNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		w.reqs[entryRelPath] = *req
+	}
+
+	return nil
+}
+
+// walkFiles given the root of a directory tree, it returns the slash-separated paths, relative to the root, of all the files
+// handled by a registered extractor found in it, walking it as WalkRequirements does.
+func walkFiles(root string) ([]string, error) {
+	w := &requirementsWalker{
+		opts:    &walkOptions{listOnly: true},
+		visited: make(map[string]bool),
+		reqs:    make(map[string]oci.ArtifactRequirement),
+	}
+	if err := w.walk(root, ""); err != nil {
+		return nil, err
+	}
+
+	return w.files, nil
+}
+
+// isRulesfileName reports whether the given file is a rulesfile, as detected by its extension. The sidecars overriding
+// the requirements are not, see RequirementsOverrideSuffix.
+func isRulesfileName(name string) bool {
+	if isRequirementsOverride(name) {
+		return false
+	}
+
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
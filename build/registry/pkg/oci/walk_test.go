@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Walk requirements", func() {
+	var root string
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		writeFiles(root, map[string]string{
+			"network/rules.yaml":     "- required_engine_version: 0.31.0\n",
+			"network/dns/rules.yml":  "- required_engine_version: 0.35.0\n",
+			"network/dns/README.md":  "not a rulesfile\n",
+			"container/rules.yaml":   "- required_engine_version: 0.26.0\n",
+			"container/macros.yaml":  "- macro: container\n  condition: container.id != host\n",
+			"container/overlay.yaml": "- rule: shell\n  enabled: false\n  override:\n    enabled: replace\n",
+		})
+		// A link to an ancestor directory would make the walk loop forever.
+		Expect(os.Symlink(root, filepath.Join(root, "network", "dns", "loop"))).To(Succeed())
+	})
+
+	It("should return the requirements of the rulesfiles by relative path", func() {
+		reqs, err := oci.WalkRequirements(root)
+		Expect(err).To(BeNil())
+		Expect(reqs).To(HaveLen(3))
+		Expect(reqs["network/rules.yaml"].Version).To(Equal("0.31.0"))
+		Expect(reqs["network/dns/rules.yml"].Version).To(Equal("0.35.0"))
+		Expect(reqs["container/rules.yaml"].Version).To(Equal("0.26.0"))
+	})
+
+	It("should fail for the rulesfiles without requirements if requested", func() {
+		_, err := oci.WalkRequirements(root, oci.WithFailOnReqNotFound())
+		Expect(err).To(MatchError(oci.ErrReqNotFound))
+	})
+})
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// ErrWarnings error when warnings have been raised and they are to be handled as errors, see WarningCollector.Err.
+var ErrWarnings = errors.New("warnings raised")
+
+// Warning is a problem found while extracting or publishing the requirements that does not make it fail, such as a
+// plugin requiring an api version not supported by the plugin loader.
+type Warning struct {
+	// File is the plugin or rulesfile the warning is about, or the artifact if it is not about a single file.
+	File string
+	// Err describes the warning, wrapping one of ErrBareEngineVersion, ErrUnreleasedEngineVersion,
+	// ErrUnsupportedAPIVersion, ErrUncheckedOpenParams, ErrEngineVersionDecreased and ErrRedundantEngineVersion, or
+	// the error that made a check be skipped, e.g. the engine version against the published one.
+	Err error
+}
+
+// WarningCollector accumulates the warnings raised by the extractions it is set on with WithWarningCollector, or while
+// it is set as the default one with SetWarningCollector, e.g. to fail once the extraction completed if there are any.
+// It is safe for concurrent use, since the extractions can run concurrently.
+type WarningCollector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// Warnings returns the warnings collected so far, in the order they have been raised.
+func (c *WarningCollector) Warnings() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.warnings)
+}
+
+// Err returns an error wrapping ErrWarnings together with all the collected warnings, nil if there are none.
+func (c *WarningCollector) Err() error {
+	warnings := c.Warnings()
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	errs := []error{fmt.Errorf("%d warnings: %w", len(warnings), ErrWarnings)}
+	for _, w := range warnings {
+		errs = append(errs, w.Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *WarningCollector) add(w Warning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.warnings = append(c.warnings, w)
+}
+
+var warningCollector atomic.Pointer[WarningCollector]
+
+// SetWarningCollector sets the default collector of the warnings raised from now on, used by the extractions without
+// a collector set with WithWarningCollector. A nil collector stops the collection, which is the default.
+func SetWarningCollector(c *WarningCollector) {
+	warningCollector.Store(c)
+}
+
+// WithWarningCollector sets the collector of the warnings raised by a single extraction, instead of the default one,
+// see SetWarningCollector, so that concurrent callers collect their own warnings. The other operations take it with
+// WithBatchRequirementOptions, WithPushRequirementOptions and the like.
+func WithWarningCollector(c *WarningCollector) RequirementOption {
+	return func(o *requirementOptions) {
+		o.warnings = c
+	}
+}
+
+// warn adds a warning about the given file to the collector set with WithWarningCollector, or to the default one, if
+// any.
+func (o *requirementOptions) warn(file string, err error) {
+	c := o.warnings
+	if c == nil {
+		c = warningCollector.Load()
+	}
+	if c != nil {
+		c.add(Warning{File: file, Err: err})
+	}
+}
+
+// PrintWarnings writes the warnings as a table with a row for each one.
+func PrintWarnings(warnings []Warning, output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tWARNING")
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s\t%v\n", warning.File, warning.Err)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/falcosecurity/plugins/build/registry/pkg/oci"
+)
+
+var _ = Describe("Warning collector", func() {
+	It("should collect the warnings of the extractions it is set for", func() {
+		collector := &oci.WarningCollector{}
+		Expect(collector.Err()).To(BeNil())
+
+		bare := writeRulesfile("- required_engine_version: 15\n")
+		// Short versions are coerced too, but they are not bare numbers.
+		short := writeRulesfile("- required_engine_version: 0.15\n")
+		_, errs := oci.BatchRequirements([]string{bare, short, writeRulesfile("- required_engine_version: 0.15.0\n")}, 2,
+			oci.WithBatchRequirementOptions(oci.WithWarningCollector(collector)))
+		Expect(errs).To(BeEmpty())
+
+		warnings := collector.Warnings()
+		Expect(warnings).To(HaveLen(1))
+		Expect(warnings[0].File).To(Equal(bare))
+		Expect(warnings[0].Err).To(MatchError(oci.ErrBareEngineVersion))
+		err := collector.Err()
+		Expect(err).To(MatchError(oci.ErrWarnings))
+		Expect(err).To(MatchError(oci.ErrBareEngineVersion))
+
+		// Warnings are only collected by the extractions the collector is set for.
+		_, err = oci.RulesfileRequirement(bare)
+		Expect(err).To(BeNil())
+		Expect(collector.Warnings()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrDuplicatePluginName error when multiple plugins share the same name in the registry file.
+var ErrDuplicatePluginName = errors.New("duplicate plugin names")
+
+// CheckDuplicatePluginNames loads the registry from a file on disk and returns an error listing all the plugin
+// names declared more than once, together with the position of each entry in the file.
+func CheckDuplicatePluginNames(fname string) error {
+	var reg struct {
+		Plugins []struct {
+			Name yaml.Node `yaml:"name"`
+		} `yaml:"plugins"`
+	}
+
+	file, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := yaml.NewDecoder(file).Decode(&reg); err != nil {
+		return err
+	}
+
+	// Keep the names in order of appearance to report them in a stable order.
+	var names []string
+	positions := make(map[string][]string)
+	for _, p := range reg.Plugins {
+		if _, ok := positions[p.Name.Value]; !ok {
+			names = append(names, p.Name.Value)
+		}
+		positions[p.Name.Value] = append(positions[p.Name.Value], fmt.Sprintf("%s:%d:%d", fname, p.Name.Line, p.Name.Column))
+	}
+
+	var conflicts []string
+	for _, name := range names {
+		if len(positions[name]) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("'%s' (%s)", name, strings.Join(positions[name], ", ")))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicatePluginName, strings.Join(conflicts, "; "))
+	}
+
+	return nil
+}